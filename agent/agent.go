@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"os"
 	"path/filepath"
 
 	"github.com/superfly/flyctl/helpers"
@@ -8,6 +9,12 @@ import (
 
 // TODO: deprecate
 func PathToSocket() string {
+	// FLY_AGENT_SOCKET points at a shared agent, such as one run by
+	// fly wireguard gateway
+	if path := os.Getenv("FLY_AGENT_SOCKET"); path != "" {
+		return path
+	}
+
 	dir, err := helpers.GetConfigDirectory()
 	if err != nil {
 		panic(err)
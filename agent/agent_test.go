@@ -0,0 +1,16 @@
+package agent
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathToSocket(t *testing.T) {
+	t.Setenv("FLY_AGENT_SOCKET", "")
+	assert.Equal(t, "fly-agent.sock", filepath.Base(PathToSocket()))
+
+	t.Setenv("FLY_AGENT_SOCKET", "/tmp/ci/fly-gateway.sock")
+	assert.Equal(t, "/tmp/ci/fly-gateway.sock", PathToSocket())
+}
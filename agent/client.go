@@ -8,10 +8,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -54,6 +56,16 @@ func Establish(ctx context.Context, apiClient *fly.Client) (*Client, error) {
 		return c, nil
 	}
 
+	// An agent from another build that speaks our protocol and supports
+	// every verb we use can keep running, along with its tunnels.
+	if caps, err := c.Capabilities(ctx); err == nil && caps.ProtocolVersion >= proto.Version && caps.Supports(requiredVerbs...) {
+		if logger := logger.MaybeFromContext(ctx); logger != nil {
+			logger.Debugf("reusing agent v%s (protocol v%d)", res.Version, caps.ProtocolVersion)
+		}
+
+		return c, nil
+	}
+
 	// TOOD: log this instead
 	msg := fmt.Sprintf("The running flyctl agent (v%s) is older than the current flyctl (v%s).", res.Version, buildinfo.Version())
 
@@ -130,6 +142,9 @@ type Client struct {
 	network string
 	address string
 	dialer  net.Dialer
+
+	capsMu sync.Mutex
+	caps   *Capabilities
 }
 
 var errDone = errors.New("done")
@@ -179,6 +194,45 @@ type PingResponse struct {
 	Background bool
 }
 
+// StatusResponse describes the agent and each of its tunnels.
+type StatusResponse struct {
+	PID        int
+	Version    string
+	Background bool
+	Tunnels    []TunnelStatus
+	// Metrics holds the persisted usage counters of every org the agent has
+	// opened a tunnel for, including tunnels that have since been closed.
+	Metrics map[string]TunnelMetrics `json:",omitempty"`
+}
+
+// TunnelMetrics counts how an org's tunnel has been used.
+type TunnelMetrics struct {
+	Built     int
+	Reused    int
+	Rebuilt   int
+	Evicted   int
+	Closed    int
+	LastBuilt time.Time
+}
+
+// TunnelStatus describes one organization's WireGuard tunnel.
+type TunnelStatus struct {
+	Org               string
+	Peer              string
+	PeerIP            string
+	Region            string
+	Endpoint          string
+	Transport         string
+	LastHandshake     time.Time
+	LastUsed          time.Time
+	RxBytes           uint64
+	TxBytes           uint64
+	ActiveConnections int
+	DNSQueries        uint64
+	DNSErrors         uint64
+	Error             string `json:",omitempty"`
+}
+
 type errInvalidResponse []byte
 
 func (err errInvalidResponse) Error() string {
@@ -208,6 +262,52 @@ func (c *Client) Ping(ctx context.Context) (res PingResponse, err error) {
 	return
 }
 
+// Status reports the agent's tunnels and their statistics.
+func (c *Client) Status(ctx context.Context) (res StatusResponse, err error) {
+	if c.typed(ctx) {
+		err = c.call(ctx, "status", nil, &res)
+
+		return
+	}
+
+	err = c.do(ctx, func(conn net.Conn) (err error) {
+		if err = proto.Write(conn, "status"); err != nil {
+			return
+		}
+
+		var data []byte
+		if data, err = proto.Read(conn); err != nil {
+			return
+		}
+
+		switch {
+		default:
+			err = errInvalidResponse(data)
+		case isOK(data):
+			err = unmarshal(&res, data)
+		case isError(data):
+			err = extractError(data)
+		}
+
+		return
+	})
+
+	return
+}
+
+// CloseTunnel closes the agent's tunnel to the given org.
+func (c *Client) CloseTunnel(ctx context.Context, slug string) error {
+	caps, err := c.Capabilities(ctx)
+	if err != nil {
+		return err
+	}
+	if !caps.Supports("rpc", "closeTunnel") {
+		return fmt.Errorf("the running agent (%s) can't close tunnels; restart it to upgrade", caps.Build)
+	}
+
+	return c.call(ctx, "closeTunnel", CloseTunnelRequest{Org: slug}, nil)
+}
+
 const okPrefix = "ok "
 
 func isOK(data []byte) bool {
@@ -283,6 +383,10 @@ func (c *Client) Reestablish(ctx context.Context, slug string) (res *EstablishRe
 }
 
 func (c *Client) Probe(ctx context.Context, slug string) error {
+	if c.typed(ctx) {
+		return c.call(ctx, "probe", ProbeRequest{Org: slug}, nil)
+	}
+
 	return c.do(ctx, func(conn net.Conn) (err error) {
 		if err = proto.Write(conn, "probe", slug); err != nil {
 			return
@@ -307,6 +411,13 @@ func (c *Client) Probe(ctx context.Context, slug string) error {
 }
 
 func (c *Client) Resolve(ctx context.Context, slug, host string) (addr string, err error) {
+	if c.typed(ctx) {
+		var res ResolveResponse
+		err = c.call(ctx, "resolve", ResolveRequest{Org: slug, Host: host}, &res)
+
+		return res.Addr, err
+	}
+
 	err = c.do(ctx, func(conn net.Conn) (err error) {
 		if err = proto.Write(conn, "resolve", slug, host); err != nil {
 			return
@@ -335,6 +446,12 @@ func (c *Client) Resolve(ctx context.Context, slug, host string) (addr string, e
 }
 
 func (c *Client) LookupTxt(ctx context.Context, slug, host string) (records []string, err error) {
+	if c.typed(ctx) {
+		err = c.call(ctx, "lookupTxt", LookupTxtRequest{Org: slug, Name: host}, &records)
+
+		return
+	}
+
 	err = c.do(ctx, func(conn net.Conn) (err error) {
 		if err = proto.Write(conn, "lookupTxt", slug, host); err != nil {
 			return
@@ -414,6 +531,12 @@ func (c *Client) Instances(ctx context.Context, org, app string) (instances Inst
 	gqlChan := make(chan instancesResult)
 	var agentInstances Instances
 	go func() {
+		if c.typed(ctx) {
+			agentChan <- c.call(ctx, "instances", InstancesRequest{Org: org, App: app}, &agentInstances)
+
+			return
+		}
+
 		agentChan <- c.do(ctx, func(conn net.Conn) (err error) {
 			if err = proto.Write(conn, "instances", org, app); err != nil {
 				return
@@ -642,7 +765,14 @@ func (d *dialer) Config() *wg.Config {
 	return d.config
 }
 
+// DialContext connects to addr through the org's tunnel. UDP networks are
+// supported; each Read and Write on the returned conn is a single datagram.
 func (d *dialer) DialContext(ctx context.Context, network, addr string) (conn net.Conn, err error) {
+	verb := "connect"
+	if strings.HasPrefix(network, "udp") {
+		verb = "connectudp"
+	}
+
 	if conn, err = d.client.dialContext(ctx); err != nil {
 		return
 	}
@@ -655,7 +785,7 @@ func (d *dialer) DialContext(ctx context.Context, network, addr string) (conn ne
 	c := make(chan error, 1)
 	go func() {
 		timeout := strconv.FormatInt(int64(d.timeout), 10)
-		if err := proto.Write(conn, "connect", d.slug, addr, timeout); err != nil {
+		if err := proto.Write(conn, verb, d.slug, addr, timeout); err != nil {
 			c <- err
 			return
 		}
@@ -681,9 +811,31 @@ func (d *dialer) DialContext(ctx context.Context, network, addr string) (conn ne
 		err = ctx.Err()
 	case err = <-c:
 	}
+	if err == nil && verb == "connectudp" {
+		conn = &packetConn{Conn: conn}
+	}
 	return
 }
 
+// packetConn frames datagrams over a stream connection to the agent.
+type packetConn struct {
+	net.Conn
+}
+
+func (c *packetConn) Read(p []byte) (int, error) {
+	return proto.ReadPacket(c.Conn, p)
+}
+
+func (c *packetConn) Write(p []byte) (int, error) {
+	if len(p) > math.MaxUint16 {
+		return 0, fmt.Errorf("datagram of %d bytes is too large", len(p))
+	}
+	if err := proto.WritePacket(c.Conn, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
 // Pinger wraps a connection to the flyctl agent over which ICMP
 // requests and replies are written. There's a simple protocol
 // for encapsulating requests and responses; drive it with the Pinger
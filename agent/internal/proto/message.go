@@ -0,0 +1,113 @@
+package proto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Version is the agent protocol version spoken by this build.
+//
+// Version 1 is the original protocol: space separated verbs and arguments,
+// answered with "ok ...", "err <message>" or a bare JSON payload. Agents that
+// speak it reply to the "hello" verb with an unsupported command error.
+//
+// Version 2 adds the "hello" handshake and the "rpc" verb, which carries a
+// JSON Request and is answered with "ok <json>" or "err <json Error>".
+const Version = 2
+
+// Hello is exchanged by the "hello" verb. The client sends its own Hello and
+// the agent replies with the version it speaks and the verbs it supports.
+type Hello struct {
+	Version int      `json:"version"`
+	Build   string   `json:"build,omitempty"`
+	Verbs   []string `json:"verbs,omitempty"`
+}
+
+// Supports reports whether every one of verbs is advertised by h.
+func (h *Hello) Supports(verbs ...string) bool {
+	for _, verb := range verbs {
+		found := false
+		for _, v := range h.Verbs {
+			if v == verb {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Request is the payload of the "rpc" verb.
+type Request struct {
+	Verb string          `json:"verb"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+// ErrorCode classifies an Error so clients can handle it without matching
+// on messages.
+type ErrorCode string
+
+const (
+	CodeUnknown           ErrorCode = "unknown"
+	CodeUnsupported       ErrorCode = "unsupported"
+	CodeMalformed         ErrorCode = "malformed"
+	CodeTunnelUnavailable ErrorCode = "tunnel_unavailable"
+	CodeNoSuchHost        ErrorCode = "no_such_host"
+	CodeInternal          ErrorCode = "internal"
+)
+
+// Error is the structured error returned by version 2 verbs.
+type Error struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrInvalidResponse is returned by ParseResponse for replies that are
+// neither "ok" nor "err".
+var ErrInvalidResponse = errors.New("invalid response")
+
+// WriteJSON writes verb followed by v encoded as JSON.
+func WriteJSON(w io.Writer, verb string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return Write(w, verb, string(data))
+}
+
+// ParseResponse decodes a reply read with Read. "ok <json>" is unmarshaled
+// into result, if it's not nil. "err <json>" is returned as an *Error, as is
+// a version 1 "err <message>", with CodeUnknown.
+func ParseResponse(data []byte, result any) error {
+	verb, payload, _ := strings.Cut(string(data), " ")
+
+	switch verb {
+	case "ok":
+		if result == nil || payload == "" {
+			return nil
+		}
+		if err := json.Unmarshal([]byte(payload), result); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		}
+		return nil
+	case "err":
+		perr := &Error{}
+		if err := json.Unmarshal([]byte(payload), perr); err != nil || perr.Code == "" {
+			perr = &Error{Code: CodeUnknown, Message: payload}
+		}
+		return perr
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidResponse, data)
+	}
+}
@@ -46,3 +46,32 @@ func Write(w io.Writer, verb string, args ...string) (err error) {
 
 	return
 }
+
+// WritePacket writes p as a single length-prefixed frame so that datagram
+// boundaries survive the agent's stream connection.
+func WritePacket(w io.Writer, p []byte) (err error) {
+	buf := make([]byte, 2+len(p))
+	binary.LittleEndian.PutUint16(buf, uint16(len(p)))
+	copy(buf[2:], p)
+
+	_, err = w.Write(buf)
+
+	return
+}
+
+// ReadPacket reads a frame written by WritePacket into p. Frames larger than
+// p are truncated, as they would be when reading a datagram socket.
+func ReadPacket(r io.Reader, p []byte) (n int, err error) {
+	var b [2]byte
+	if _, err = io.ReadFull(r, b[:]); err != nil {
+		return
+	}
+	l := int(binary.LittleEndian.Uint16(b[:]))
+
+	n = min(l, len(p))
+	if _, err = io.ReadFull(r, p[:n]); err == nil && l > n {
+		_, err = io.CopyN(io.Discard, r, int64(l-n))
+	}
+
+	return
+}
@@ -0,0 +1,122 @@
+package proto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRead(t *testing.T) {
+	cases := []struct {
+		verb string
+		args []string
+		want string
+	}{
+		{verb: "ping", want: "ping"},
+		{verb: "resolve", args: []string{"personal", "web.internal"}, want: "resolve personal web.internal"},
+		{verb: "hello", args: []string{`{"version":2}`}, want: `hello {"version":2}`},
+	}
+
+	for _, tc := range cases {
+		var buf bytes.Buffer
+		require.NoError(t, Write(&buf, tc.verb, tc.args...))
+
+		data, err := Read(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, string(data))
+	}
+}
+
+func TestPackets(t *testing.T) {
+	cases := []struct {
+		name    string
+		packet  []byte
+		bufSize int
+		want    []byte
+	}{
+		{name: "fits", packet: []byte("hello"), bufSize: 16, want: []byte("hello")},
+		{name: "empty", packet: []byte{}, bufSize: 16, want: []byte{}},
+		{name: "truncated", packet: []byte("hello world"), bufSize: 5, want: []byte("hello")},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, WritePacket(&buf, tc.packet))
+			require.NoError(t, WritePacket(&buf, []byte("next")))
+
+			p := make([]byte, tc.bufSize)
+			n, err := ReadPacket(&buf, p)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, p[:n])
+
+			// the rest of a truncated frame is discarded
+			n, err = ReadPacket(&buf, p)
+			require.NoError(t, err)
+			assert.Equal(t, "next", string(p[:n]))
+		})
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	type result struct {
+		Addr string
+	}
+
+	cases := []struct {
+		name string
+		data string
+		want result
+		code ErrorCode
+		msg  string
+		bad  bool
+	}{
+		{name: "ok", data: "ok"},
+		{name: "ok with result", data: `ok {"Addr":"fdaa::3"}`, want: result{Addr: "fdaa::3"}},
+		{name: "structured error", data: `err {"code":"no_such_host","message":"host was not found in DNS"}`, code: CodeNoSuchHost, msg: "host was not found in DNS"},
+		{name: "legacy error", data: "err unsupported command", code: CodeUnknown, msg: "unsupported command"},
+		{name: "json without code", data: `err {"message":"x"}`, code: CodeUnknown, msg: `{"message":"x"}`},
+		{name: "malformed result", data: "ok {", bad: true},
+		{name: "garbage", data: "what", bad: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got result
+			err := ParseResponse([]byte(tc.data), &got)
+
+			switch {
+			case tc.bad:
+				assert.ErrorIs(t, err, ErrInvalidResponse)
+			case tc.code != "":
+				var perr *Error
+				require.ErrorAs(t, err, &perr)
+				assert.Equal(t, tc.code, perr.Code)
+				assert.Equal(t, tc.msg, perr.Message)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestHelloSupports(t *testing.T) {
+	h := Hello{Verbs: []string{"connect", "ping", "rpc"}}
+
+	cases := []struct {
+		verbs []string
+		want  bool
+	}{
+		{verbs: nil, want: true},
+		{verbs: []string{"ping"}, want: true},
+		{verbs: []string{"ping", "rpc"}, want: true},
+		{verbs: []string{"ping", "status"}, want: false},
+	}
+
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, h.Supports(tc.verbs...), tc.verbs)
+	}
+}
@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+
+	"github.com/superfly/flyctl/agent/internal/proto"
+	"github.com/superfly/flyctl/internal/buildinfo"
+)
+
+// Arguments and results of the typed verbs carried by the agent's "rpc"
+// command. See agent/internal/proto for the wire format.
+
+type ResolveRequest struct {
+	Org  string
+	Host string
+}
+
+type ResolveResponse struct {
+	Addr string
+}
+
+type InstancesRequest struct {
+	Org string
+	App string
+}
+
+type LookupTxtRequest struct {
+	Org  string
+	Name string
+}
+
+type ProbeRequest struct {
+	Org string
+}
+
+type CloseTunnelRequest struct {
+	Org string
+}
+
+// Capabilities describes what the running agent supports, as reported by
+// the "hello" handshake.
+type Capabilities struct {
+	ProtocolVersion int
+	Build           string
+	Verbs           []string
+}
+
+// Supports reports whether the agent accepts every one of verbs.
+func (c *Capabilities) Supports(verbs ...string) bool {
+	h := proto.Hello{Verbs: c.Verbs}
+	return h.Supports(verbs...)
+}
+
+// legacyVerbs are the verbs every agent predating the handshake accepts.
+var legacyVerbs = []string{
+	"connect", "establish", "instances", "kill", "lookupTxt", "ping",
+	"ping6", "probe", "reestablish", "resolve",
+}
+
+// requiredVerbs are the verbs this build uses; an agent lacking any of them
+// has to be replaced.
+var requiredVerbs = append([]string{"closeTunnel", "connectudp", "hello", "rpc", "status"}, legacyVerbs...)
+
+// Capabilities performs the protocol handshake with the agent, once per
+// Client. Agents that predate the handshake are reported as protocol
+// version 1 with the legacy verbs.
+func (c *Client) Capabilities(ctx context.Context) (*Capabilities, error) {
+	c.capsMu.Lock()
+	defer c.capsMu.Unlock()
+
+	if c.caps != nil {
+		return c.caps, nil
+	}
+
+	var hello proto.Hello
+	err := c.do(ctx, func(conn net.Conn) (err error) {
+		if err = proto.WriteJSON(conn, "hello", proto.Hello{
+			Version: proto.Version,
+			Build:   buildinfo.Version().String(),
+		}); err != nil {
+			return
+		}
+
+		var data []byte
+		if data, err = proto.Read(conn); err != nil {
+			return
+		}
+
+		return proto.ParseResponse(data, &hello)
+	})
+
+	var perr *proto.Error
+	switch {
+	case errors.As(err, &perr) && perr.Code == proto.CodeUnknown:
+		// a version 1 agent rejects the unknown verb with a plain error
+		c.caps = &Capabilities{ProtocolVersion: 1, Verbs: legacyVerbs}
+	case err != nil:
+		return nil, err
+	default:
+		c.caps = &Capabilities{ProtocolVersion: hello.Version, Build: hello.Build, Verbs: hello.Verbs}
+	}
+
+	return c.caps, nil
+}
+
+// typed reports whether the agent accepts typed "rpc" requests.
+func (c *Client) typed(ctx context.Context) bool {
+	caps, err := c.Capabilities(ctx)
+	return err == nil && caps.ProtocolVersion >= 2 && caps.Supports("rpc")
+}
+
+// call sends a typed request and decodes its result into result, which may
+// be nil. Structured errors for missing tunnels and hosts are returned as
+// ErrTunnelUnavailable and ErrNoSuchHost.
+func (c *Client) call(ctx context.Context, verb string, args, result any) error {
+	req := proto.Request{Verb: verb}
+	if args != nil {
+		data, err := json.Marshal(args)
+		if err != nil {
+			return err
+		}
+		req.Args = data
+	}
+
+	err := c.do(ctx, func(conn net.Conn) (err error) {
+		if err = proto.WriteJSON(conn, "rpc", req); err != nil {
+			return
+		}
+
+		var data []byte
+		if data, err = proto.Read(conn); err != nil {
+			return
+		}
+
+		return proto.ParseResponse(data, result)
+	})
+
+	var perr *proto.Error
+	if errors.As(err, &perr) {
+		switch perr.Code {
+		case proto.CodeTunnelUnavailable:
+			return ErrTunnelUnavailable
+		case proto.CodeNoSuchHost:
+			return ErrNoSuchHost
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/agent/internal/proto"
+)
+
+// fakeAgent replies to each request with reply(request).
+func fakeAgent(t *testing.T, reply func(req string) string) *Client {
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+
+				req, err := proto.Read(conn)
+				if err != nil {
+					return
+				}
+				verb, rest, _ := strings.Cut(reply(string(req)), " ")
+				if rest == "" {
+					proto.Write(conn, verb)
+				} else {
+					proto.Write(conn, verb, rest)
+				}
+			}()
+		}
+	}()
+
+	return newClient("unix", path)
+}
+
+func TestCapabilities(t *testing.T) {
+	cases := []struct {
+		name     string
+		hello    string
+		version  int
+		typed    bool
+		required bool
+	}{
+		{
+			name:    "legacy agent",
+			hello:   "err unsupported command",
+			version: 1,
+		},
+		{
+			name:     "current agent",
+			hello:    `ok {"version":2,"build":"0.2.1","verbs":["closeTunnel","connect","connectudp","establish","hello","instances","kill","lookupTxt","ping","ping6","probe","reestablish","resolve","rpc","status"]}`,
+			version:  2,
+			typed:    true,
+			required: true,
+		},
+		{
+			name:    "agent missing verbs",
+			hello:   `ok {"version":2,"verbs":["hello","rpc","ping"]}`,
+			version: 2,
+			typed:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var hellos atomic.Int32
+			c := fakeAgent(t, func(req string) string {
+				if strings.HasPrefix(req, "hello ") {
+					hellos.Add(1)
+					return tc.hello
+				}
+				return "err unexpected " + req
+			})
+
+			caps, err := c.Capabilities(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tc.version, caps.ProtocolVersion)
+			assert.Equal(t, tc.required, caps.Supports(requiredVerbs...))
+			assert.Equal(t, tc.typed, c.typed(context.Background()))
+			assert.EqualValues(t, 1, hellos.Load(), "the handshake is cached")
+		})
+	}
+}
+
+func TestTypedCalls(t *testing.T) {
+	const hello = `ok {"version":2,"verbs":["hello","rpc","resolve","probe"]}`
+
+	cases := []struct {
+		name  string
+		reply string
+		addr  string
+		err   error
+	}{
+		{name: "resolved", reply: `ok {"Addr":"fdaa::3"}`, addr: "fdaa::3"},
+		{name: "no such host", reply: `err {"code":"no_such_host","message":"host was not found in DNS"}`, err: ErrNoSuchHost},
+		{name: "no tunnel", reply: `err {"code":"tunnel_unavailable","message":"tunnel unavailable"}`, err: ErrTunnelUnavailable},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := make(chan string, 1)
+			c := fakeAgent(t, func(req string) string {
+				if strings.HasPrefix(req, "hello ") {
+					return hello
+				}
+				got <- req
+				return tc.reply
+			})
+
+			addr, err := c.Resolve(context.Background(), "personal", "web.internal")
+			assert.Equal(t, `rpc {"verb":"resolve","args":{"Org":"personal","Host":"web.internal"}}`, <-got)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.addr, addr)
+		})
+	}
+}
+
+func TestLegacyFallback(t *testing.T) {
+	got := make(chan string, 1)
+	c := fakeAgent(t, func(req string) string {
+		if strings.HasPrefix(req, "hello ") {
+			return "err unsupported command"
+		}
+		got <- req
+		return "ok fdaa::3"
+	})
+
+	addr, err := c.Resolve(context.Background(), "personal", "web.internal")
+	require.NoError(t, err)
+	assert.Equal(t, "fdaa::3", addr)
+	assert.Equal(t, "resolve personal web.internal", <-got)
+}
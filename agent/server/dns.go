@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// defaultUpstream is used for public names when no other resolver is
+// configured on the host.
+const defaultUpstream = "1.1.1.1:53"
+
+type dnsExchanger interface {
+	Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error)
+}
+
+// dnsForwarder answers .internal and .flycast queries through one
+// organization's tunnel and sends everything else to the host's upstream
+// resolvers.
+type dnsForwarder struct {
+	// tunnel returns the tunnel to resolve private names through,
+	// establishing it if needed and marking it as used.
+	tunnel   func(ctx context.Context) (dnsExchanger, error)
+	upstream []string
+	logger   interface{ Printf(string, ...any) }
+}
+
+func isPrivateName(name string) bool {
+	name = strings.ToLower(dns.Fqdn(name))
+	return strings.HasSuffix(name, ".internal.") || strings.HasSuffix(name, ".flycast.")
+}
+
+func (f *dnsForwarder) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	network := "udp"
+	if _, ok := w.RemoteAddr().(*net.TCPAddr); ok {
+		network = "tcp"
+	}
+
+	resp, err := f.resolve(ctx, network, r)
+	if err != nil {
+		f.logger.Printf("dns: %v", err)
+
+		resp = new(dns.Msg)
+		resp.SetRcode(r, dns.RcodeServerFailure)
+	}
+
+	resp.Id = r.Id
+	_ = w.WriteMsg(resp)
+}
+
+func (f *dnsForwarder) resolve(ctx context.Context, network string, r *dns.Msg) (*dns.Msg, error) {
+	if len(r.Question) == 0 {
+		resp := new(dns.Msg)
+		resp.SetRcode(r, dns.RcodeFormatError)
+		return resp, nil
+	}
+
+	if isPrivateName(r.Question[0].Name) {
+		return f.private(ctx, r)
+	}
+
+	return f.public(ctx, network, r)
+}
+
+func (f *dnsForwarder) private(ctx context.Context, r *dns.Msg) (*dns.Msg, error) {
+	tunnel, err := f.tunnel(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("no tunnel to resolve %s: %w", r.Question[0].Name, err)
+	}
+
+	return tunnel.Exchange(ctx, r.Copy())
+}
+
+func (f *dnsForwarder) public(ctx context.Context, network string, r *dns.Msg) (*dns.Msg, error) {
+	client := &dns.Client{Net: network}
+
+	var lastErr error
+	for _, addr := range f.upstream {
+		resp, _, err := client.ExchangeContext(ctx, r, addr)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// upstreamResolvers returns the nameservers from the host's resolv.conf,
+// excluding the agent's own listen address.
+func upstreamResolvers(path, self string) []string {
+	var servers []string
+
+	if conf, err := dns.ClientConfigFromFile(path); err == nil {
+		for _, server := range conf.Servers {
+			addr := net.JoinHostPort(server, conf.Port)
+			if addr != self {
+				servers = append(servers, addr)
+			}
+		}
+	}
+
+	if len(servers) == 0 {
+		servers = []string{defaultUpstream}
+	}
+
+	return servers
+}
+
+// serveDNS runs the local resolver on s.DNSAddr over UDP and TCP until ctx
+// is cancelled. Failing to bind is logged rather than fatal, so the agent
+// keeps serving its own clients.
+func (s *server) serveDNS(ctx context.Context) {
+	f := &dnsForwarder{
+		tunnel:   s.dnsTunnel,
+		upstream: upstreamResolvers("/etc/resolv.conf", s.DNSAddr),
+		logger:   s.Logger,
+	}
+
+	var wg sync.WaitGroup
+	for _, network := range []string{"udp", "tcp"} {
+		srv := &dns.Server{Addr: s.DNSAddr, Net: network, Handler: f}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := srv.ListenAndServe(); err != nil {
+				s.printf("failed serving dns on %s/%s: %v", s.DNSAddr, srv.Net, err)
+			}
+		}()
+
+		go func() {
+			<-ctx.Done()
+			_ = srv.Shutdown()
+		}()
+	}
+
+	s.printf("serving dns for .internal and .flycast names of %s on %s", s.DNSOrg, s.DNSAddr)
+
+	wg.Wait()
+}
+
+// dnsTunnel returns the tunnel of the DNSOrg organization, establishing it
+// on first use or after it was closed.
+func (s *server) dnsTunnel(ctx context.Context) (dnsExchanger, error) {
+	tunnel, err := s.ensureTunnel(ctx, s.DNSOrg)
+	if err != nil {
+		return nil, err
+	}
+	return tunnel, nil
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTunnel answers AAAA queries for the names it knows and NXDOMAIN for
+// everything else.
+type fakeTunnel struct {
+	names map[string]string
+	calls int
+	err   error
+}
+
+func (f *fakeTunnel) Exchange(_ context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	resp := new(dns.Msg)
+	resp.SetReply(msg)
+
+	q := msg.Question[0]
+	addr, ok := f.names[q.Name]
+	if !ok {
+		resp.Rcode = dns.RcodeNameError
+		return resp, nil
+	}
+
+	rr, err := dns.NewRR(q.Name + " 5 IN AAAA " + addr)
+	if err != nil {
+		return nil, err
+	}
+	resp.Answer = append(resp.Answer, rr)
+	return resp, nil
+}
+
+// newTestForwarder returns a forwarder resolving private names through
+// tunnel, or failing to find a tunnel when it's nil.
+func newTestForwarder(tunnel dnsExchanger, upstream ...string) *dnsForwarder {
+	return &dnsForwarder{
+		tunnel: func(context.Context) (dnsExchanger, error) {
+			if tunnel == nil {
+				return nil, errors.New("no such organization")
+			}
+			return tunnel, nil
+		},
+		upstream: upstream,
+		logger:   log.New(os.Stderr, "", 0),
+	}
+}
+
+func query(name string, qtype uint16) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	return m
+}
+
+func TestIsPrivateName(t *testing.T) {
+	cases := map[string]bool{
+		"web.internal.":          true,
+		"iad.web.internal":       true,
+		"_apps.internal.":        true,
+		"web.flycast.":           true,
+		"WEB.INTERNAL.":          true,
+		"fly.io.":                false,
+		"internal.example.com.":  false,
+		"web.internal.example.":  false,
+		"web.flycast.example.io": false,
+	}
+
+	for name, want := range cases {
+		assert.Equal(t, want, isPrivateName(name), name)
+	}
+}
+
+func TestDNSForwarder_Private(t *testing.T) {
+	tunnel := &fakeTunnel{names: map[string]string{"web.internal.": "fdaa::3"}}
+	f := newTestForwarder(tunnel)
+
+	resp, err := f.resolve(context.Background(), "udp", query("web.internal.", dns.TypeAAAA))
+	require.NoError(t, err)
+	require.Len(t, resp.Answer, 1)
+	assert.Equal(t, "fdaa::3", resp.Answer[0].(*dns.AAAA).AAAA.String())
+	assert.Equal(t, 1, tunnel.calls)
+
+	resp, err = f.resolve(context.Background(), "udp", query("missing.internal.", dns.TypeAAAA))
+	require.NoError(t, err)
+	assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+	assert.Equal(t, 2, tunnel.calls)
+}
+
+func TestDNSForwarder_PrivateErrors(t *testing.T) {
+	f := newTestForwarder(nil)
+	_, err := f.resolve(context.Background(), "udp", query("web.internal.", dns.TypeAAAA))
+	assert.ErrorContains(t, err, "no tunnel to resolve web.internal.: no such organization")
+
+	f = newTestForwarder(&fakeTunnel{err: errors.New("tunnel down")})
+	_, err = f.resolve(context.Background(), "udp", query("web.internal.", dns.TypeAAAA))
+	assert.ErrorContains(t, err, "tunnel down")
+}
+
+func startDNS(t *testing.T, handler dns.Handler) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	srv := &dns.Server{PacketConn: pc, Handler: handler, NotifyStartedFunc: func() { close(started) }}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+	<-started
+
+	return pc.LocalAddr().String()
+}
+
+func TestDNSForwarder_ServeDNS(t *testing.T) {
+	upstream := startDNS(t, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		rr, _ := dns.NewRR(r.Question[0].Name + " 60 IN A 192.0.2.1")
+		resp.Answer = append(resp.Answer, rr)
+		w.WriteMsg(resp)
+	}))
+
+	tunnel := &fakeTunnel{names: map[string]string{"db.internal.": "fdaa::5"}}
+	addr := startDNS(t, newTestForwarder(tunnel, upstream))
+
+	client := &dns.Client{}
+
+	resp, _, err := client.Exchange(query("example.com.", dns.TypeA), addr)
+	require.NoError(t, err)
+	require.Len(t, resp.Answer, 1)
+	assert.Equal(t, "192.0.2.1", resp.Answer[0].(*dns.A).A.String())
+
+	resp, _, err = client.Exchange(query("db.internal.", dns.TypeAAAA), addr)
+	require.NoError(t, err)
+	require.Len(t, resp.Answer, 1)
+	assert.Equal(t, "fdaa::5", resp.Answer[0].(*dns.AAAA).AAAA.String())
+}
+
+func TestUpstreamResolvers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	require.NoError(t, os.WriteFile(path, []byte("nameserver 127.0.0.1\nnameserver 10.0.0.2\n"), 0o600))
+
+	assert.Equal(t, []string{"10.0.0.2:53"}, upstreamResolvers(path, "127.0.0.1:53"))
+	assert.Equal(t, []string{"127.0.0.1:53", "10.0.0.2:53"}, upstreamResolvers(path, "127.0.0.1:5353"))
+	assert.Equal(t, []string{defaultUpstream}, upstreamResolvers(filepath.Join(t.TempDir(), "missing"), ""))
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/superfly/flyctl/agent"
+	"github.com/superfly/flyctl/agent/internal/proto"
+	"github.com/superfly/flyctl/internal/buildinfo"
+)
+
+// hello and rpc are registered here rather than in the handlers literal
+// since they read the handler tables themselves.
+func init() {
+	handlers["hello"] = (*session).hello
+	handlers["rpc"] = (*session).rpc
+}
+
+// rpcFunc implements a typed verb. args holds the request's JSON arguments.
+type rpcFunc func(s *session, ctx context.Context, args json.RawMessage) (any, error)
+
+var rpcHandlers = map[string]rpcFunc{
+	"ping": func(s *session, _ context.Context, _ json.RawMessage) (any, error) {
+		return agent.PingResponse{
+			Version:    buildinfo.Version().String(),
+			PID:        os.Getpid(),
+			Background: s.srv.Options.Background,
+		}, nil
+	},
+	"status": func(s *session, _ context.Context, _ json.RawMessage) (any, error) {
+		return agent.StatusResponse{
+			Version:    buildinfo.Version().String(),
+			PID:        os.Getpid(),
+			Background: s.srv.Options.Background,
+			Tunnels:    s.srv.tunnelStatus(),
+			Metrics:    s.srv.metrics.snapshot(),
+		}, nil
+	},
+	"resolve": typed(func(s *session, ctx context.Context, req agent.ResolveRequest) (any, error) {
+		tunnel := s.srv.tunnelFor(req.Org)
+		if tunnel == nil {
+			return nil, agent.ErrTunnelUnavailable
+		}
+
+		addr, err := resolve(ctx, tunnel, req.Host)
+		if err != nil {
+			return nil, err
+		}
+		return agent.ResolveResponse{Addr: addr}, nil
+	}),
+	"instances": typed(func(s *session, ctx context.Context, req agent.InstancesRequest) (any, error) {
+		tunnel := s.srv.tunnelFor(req.Org)
+		if tunnel == nil {
+			return nil, agent.ErrTunnelUnavailable
+		}
+
+		return s.srv.fetchInstances(ctx, tunnel, req.App)
+	}),
+	"lookupTxt": typed(func(s *session, ctx context.Context, req agent.LookupTxtRequest) (any, error) {
+		tunnel := s.srv.tunnelFor(req.Org)
+		if tunnel == nil {
+			return nil, agent.ErrTunnelUnavailable
+		}
+
+		return tunnel.LookupTXT(ctx, req.Name)
+	}),
+	"closeTunnel": typed(func(s *session, _ context.Context, req agent.CloseTunnelRequest) (any, error) {
+		return nil, s.srv.closeTunnel(req.Org)
+	}),
+	"probe": typed(func(s *session, ctx context.Context, req agent.ProbeRequest) (any, error) {
+		return nil, s.srv.probeTunnel(ctx, req.Org)
+	}),
+}
+
+// typed adapts a handler taking a decoded request struct.
+func typed[T any](fn func(*session, context.Context, T) (any, error)) rpcFunc {
+	return func(s *session, ctx context.Context, args json.RawMessage) (any, error) {
+		var req T
+		if len(args) > 0 {
+			if err := json.Unmarshal(args, &req); err != nil {
+				return nil, &proto.Error{Code: proto.CodeMalformed, Message: err.Error()}
+			}
+		}
+		return fn(s, ctx, req)
+	}
+}
+
+// verbs lists every verb the agent accepts, including the typed ones
+// callable through "rpc".
+func verbs() []string {
+	ret := make([]string, 0, len(handlers)+len(rpcHandlers))
+	for verb := range handlers {
+		ret = append(ret, verb)
+	}
+	for verb := range rpcHandlers {
+		if _, ok := handlers[verb]; !ok {
+			ret = append(ret, verb)
+		}
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+func (s *session) hello(_ context.Context, args ...string) {
+	if len(args) > 0 {
+		var client proto.Hello
+		if err := json.Unmarshal([]byte(strings.Join(args, " ")), &client); err != nil {
+			s.result(nil, &proto.Error{Code: proto.CodeMalformed, Message: "malformed hello: " + err.Error()})
+
+			return
+		}
+		s.logger.Printf("client speaks protocol v%d (%s)", client.Version, client.Build)
+	}
+
+	s.result(proto.Hello{
+		Version: proto.Version,
+		Build:   buildinfo.Version().String(),
+		Verbs:   verbs(),
+	}, nil)
+}
+
+func (s *session) rpc(ctx context.Context, args ...string) {
+	var req proto.Request
+	if err := json.Unmarshal([]byte(strings.Join(args, " ")), &req); err != nil {
+		s.result(nil, &proto.Error{Code: proto.CodeMalformed, Message: "malformed rpc request: " + err.Error()})
+
+		return
+	}
+
+	fn := rpcHandlers[req.Verb]
+	if fn == nil {
+		s.result(nil, &proto.Error{Code: proto.CodeUnsupported, Message: "unsupported rpc verb " + req.Verb})
+
+		return
+	}
+
+	s.result(fn(s, ctx, req.Args))
+}
+
+// result replies to a version 2 verb with result or a structured error.
+func (s *session) result(result any, err error) bool {
+	if err != nil {
+		var perr *proto.Error
+		if !errors.As(err, &perr) {
+			perr = &proto.Error{Code: errorCode(err), Message: err.Error()}
+		}
+		return s.replyJSON("err", perr)
+	}
+
+	if result == nil {
+		return s.ok()
+	}
+	return s.replyJSON("ok", result)
+}
+
+func (s *session) replyJSON(verb string, v any) bool {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return s.replyJSON("err", &proto.Error{Code: proto.CodeInternal, Message: "failed marshaling response: " + err.Error()})
+	}
+
+	return s.reply(verb, string(data))
+}
+
+func errorCode(err error) proto.ErrorCode {
+	switch {
+	case errors.Is(err, agent.ErrTunnelUnavailable):
+		return proto.CodeTunnelUnavailable
+	case errors.Is(err, agent.ErrNoSuchHost):
+		return proto.CodeNoSuchHost
+	default:
+		return proto.CodeInternal
+	}
+}
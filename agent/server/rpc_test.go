@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/agent"
+	"github.com/superfly/flyctl/agent/internal/proto"
+	"github.com/superfly/flyctl/wg"
+)
+
+func newTestServer(t *testing.T) *server {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	at, err := latestChange(path)
+	require.NoError(t, err)
+
+	logger := log.New(io.Discard, "", 0)
+
+	return &server{
+		Options: Options{
+			Logger:     logger,
+			ConfigFile: path,
+		},
+		currentChange: at,
+		tunnels:       map[string]*wg.Tunnel{"personal": {}},
+		connects:      map[string]int{"personal": 2},
+		lastUsed:      map[string]time.Time{"personal": time.Now()},
+		metrics:       loadTunnelMetrics(filepath.Join(t.TempDir(), "metrics.json"), logger),
+	}
+}
+
+// roundTrip runs a session for a single request and returns the raw reply.
+func roundTrip(t *testing.T, srv *server, verb string, args ...string) []byte {
+	client, conn := net.Pipe()
+	defer client.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runSession(context.Background(), srv, conn, 1)
+	}()
+
+	require.NoError(t, proto.Write(client, verb, args...))
+	data, err := proto.Read(client)
+	require.NoError(t, err)
+
+	client.Close()
+	<-done
+
+	return data
+}
+
+func rpcRequest(t *testing.T, verb, args string) string {
+	req := proto.Request{Verb: verb}
+	if args != "" {
+		req.Args = []byte(args)
+	}
+
+	buf, err := json.Marshal(req)
+	require.NoError(t, err)
+	return string(buf)
+}
+
+func TestHello(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		code proto.ErrorCode
+	}{
+		{name: "no client hello"},
+		{name: "client hello", args: []string{`{"version":2,"build":"0.2.0"}`}},
+		{name: "malformed", args: []string{`{"version":`}, code: proto.CodeMalformed},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var hello proto.Hello
+			err := proto.ParseResponse(roundTrip(t, newTestServer(t), "hello", tc.args...), &hello)
+
+			if tc.code != "" {
+				var perr *proto.Error
+				require.ErrorAs(t, err, &perr)
+				assert.Equal(t, tc.code, perr.Code)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, proto.Version, hello.Version)
+			assert.True(t, hello.Supports("hello", "rpc", "connect", "connectudp", "status", "resolve"))
+		})
+	}
+}
+
+func TestRPC(t *testing.T) {
+	cases := []struct {
+		name    string
+		request string
+		code    proto.ErrorCode
+		check   func(t *testing.T, data []byte)
+	}{
+		{
+			name:    "ping",
+			request: rpcRequest(t, "ping", ""),
+			check: func(t *testing.T, data []byte) {
+				var res agent.PingResponse
+				require.NoError(t, proto.ParseResponse(data, &res))
+				assert.Equal(t, os.Getpid(), res.PID)
+			},
+		},
+		{
+			name:    "status",
+			request: rpcRequest(t, "status", ""),
+			check: func(t *testing.T, data []byte) {
+				var res agent.StatusResponse
+				require.NoError(t, proto.ParseResponse(data, &res))
+				require.Len(t, res.Tunnels, 1)
+				assert.Equal(t, "personal", res.Tunnels[0].Org)
+				assert.Equal(t, 2, res.Tunnels[0].ActiveConnections)
+			},
+		},
+		{
+			name:    "resolve address",
+			request: rpcRequest(t, "resolve", `{"Org":"personal","Host":"[fdaa::3]:5432"}`),
+			check: func(t *testing.T, data []byte) {
+				var res agent.ResolveResponse
+				require.NoError(t, proto.ParseResponse(data, &res))
+				assert.Equal(t, "[fdaa::3]:5432", res.Addr)
+			},
+		},
+		{
+			name:    "missing tunnel",
+			request: rpcRequest(t, "resolve", `{"Org":"other","Host":"web.internal"}`),
+			code:    proto.CodeTunnelUnavailable,
+		},
+		{
+			name:    "malformed args",
+			request: rpcRequest(t, "resolve", `["personal"]`),
+			code:    proto.CodeMalformed,
+		},
+		{
+			name:    "unknown verb",
+			request: rpcRequest(t, "teleport", ""),
+			code:    proto.CodeUnsupported,
+		},
+		{
+			name:    "malformed request",
+			request: `{"verb":`,
+			code:    proto.CodeMalformed,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := roundTrip(t, newTestServer(t), "rpc", tc.request)
+
+			if tc.code != "" {
+				var perr *proto.Error
+				require.ErrorAs(t, proto.ParseResponse(data, nil), &perr)
+				assert.Equal(t, tc.code, perr.Code)
+				return
+			}
+
+			tc.check(t, data)
+		})
+	}
+}
+
+func TestLegacyVerbsStillWork(t *testing.T) {
+	data := roundTrip(t, newTestServer(t), "resolve", "personal", "[fdaa::3]:5432")
+	assert.Equal(t, "ok [fdaa::3]:5432", string(data))
+
+	data = roundTrip(t, newTestServer(t), "resolve", "other", "[fdaa::3]:5432")
+	assert.Equal(t, "err "+agent.ErrTunnelUnavailable.Error(), string(data))
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"net"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -31,6 +32,20 @@ type Options struct {
 	Background       bool
 	ConfigFile       string
 	ConfigWebsockets bool
+	// DNSAddr, when set, is the local address to serve DNS for .internal
+	// and .flycast names on.
+	DNSAddr string
+	// DNSOrg is the slug of the organization whose private names are
+	// resolved on DNSAddr.
+	DNSOrg string
+	// MaxTunnels, when positive, caps the number of open tunnels; the least
+	// recently used idle tunnels are closed to make room for new ones.
+	MaxTunnels int
+	// TunnelIdleTimeout, when positive, closes tunnels that haven't been
+	// used for that long.
+	TunnelIdleTimeout time.Duration
+	// MetricsFile is where per-org tunnel metrics are persisted.
+	MetricsFile string
 }
 
 func Run(ctx context.Context, opt Options) (err error) {
@@ -56,6 +71,9 @@ func Run(ctx context.Context, opt Options) (err error) {
 		listener:      l,
 		currentChange: latestChangeAt,
 		tunnels:       make(map[string]*wg.Tunnel),
+		connects:      make(map[string]int),
+		lastUsed:      make(map[string]time.Time),
+		metrics:       loadTunnelMetrics(opt.MetricsFile, opt.Logger),
 	}).serve(ctx, l)
 
 	return
@@ -110,6 +128,14 @@ type server struct {
 	mu            sync.Mutex
 	currentChange time.Time
 	tunnels       map[string]*wg.Tunnel
+	connects      map[string]int       // active connect sessions by org slug
+	lastUsed      map[string]time.Time // last use of each open tunnel by org slug
+
+	metrics *tunnelMetrics
+
+	// rebuildTunnel builds the tunnel of an org again after it was closed,
+	// establishTunnel unless set otherwise by tests.
+	rebuildTunnel func(ctx context.Context, slug string) (*wg.Tunnel, error)
 }
 
 type terminateError struct{ error }
@@ -137,6 +163,28 @@ func (s *server) serve(parent context.Context, l net.Listener) (err error) {
 		return nil
 	})
 
+	eg.Go(func() error {
+		s.metrics.persist(ctx)
+
+		return nil
+	})
+
+	if s.TunnelIdleTimeout > 0 {
+		eg.Go(func() error {
+			s.evictIdleTunnels(ctx)
+
+			return nil
+		})
+	}
+
+	if s.DNSAddr != "" {
+		eg.Go(func() error {
+			s.serveDNS(ctx)
+
+			return nil
+		})
+	}
+
 	eg.Go(func() error {
 		if f := config.Tokens(ctx).FromConfigFile; f == "" {
 			s.print("monitoring for token expiration")
@@ -225,8 +273,12 @@ func (s *server) buildTunnel(ctx context.Context, org *fly.Organization, recycle
 	defer s.mu.Unlock()
 
 	// not checking the region is intentional, it's static during the lifetime of the agent
-	if tunnel = s.tunnels[org.Slug]; tunnel != nil && !recycle {
+	existing := s.tunnels[org.Slug]
+	if tunnel = existing; tunnel != nil && !recycle {
 		// tunnel already exists
+		s.touchTunnelUnlocked(org.Slug)
+		s.metrics.record(org.Slug, func(m *agent.TunnelMetrics) { m.Reused++ })
+
 		return
 	}
 
@@ -247,6 +299,18 @@ func (s *server) buildTunnel(ctx context.Context, org *fly.Organization, recycle
 	}
 
 	s.tunnels[org.Slug] = tunnel
+	s.lastUsed[org.Slug] = time.Now()
+
+	s.metrics.record(org.Slug, func(m *agent.TunnelMetrics) {
+		if existing != nil {
+			m.Rebuilt++
+		} else {
+			m.Built++
+		}
+		m.LastBuilt = time.Now()
+	})
+
+	s.enforceMaxTunnelsUnlocked(org.Slug)
 
 	return
 }
@@ -299,9 +363,103 @@ func (s *server) tunnelFor(slug string) *wg.Tunnel {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.touchTunnelUnlocked(slug)
+
 	return s.tunnels[slug]
 }
 
+// ensureTunnel returns slug's tunnel, building it again if it was closed,
+// e.g. after being idle for too long.
+func (s *server) ensureTunnel(ctx context.Context, slug string) (*wg.Tunnel, error) {
+	if tunnel := s.tunnelFor(slug); tunnel != nil {
+		return tunnel, nil
+	}
+
+	rebuild := s.rebuildTunnel
+	if rebuild == nil {
+		rebuild = s.establishTunnel
+	}
+	return rebuild(ctx, slug)
+}
+
+func (s *server) establishTunnel(ctx context.Context, slug string) (*wg.Tunnel, error) {
+	org, err := s.fetchOrg(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.buildTunnel(ctx, org, false)
+}
+
+// trackConnect counts an active connect session through slug's tunnel until
+// the returned func is called.
+func (s *server) trackConnect(slug string) func() {
+	s.mu.Lock()
+	s.connects[slug]++
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.connects[slug]--; s.connects[slug] <= 0 {
+			delete(s.connects, slug)
+		}
+	}
+}
+
+// tunnelStatus reports every tunnel, ordered by org slug.
+func (s *server) tunnelStatus() []agent.TunnelStatus {
+	s.mu.Lock()
+	tunnels := make(map[string]*wg.Tunnel, len(s.tunnels))
+	for slug, tunnel := range s.tunnels {
+		tunnels[slug] = tunnel
+	}
+	connects := make(map[string]int, len(s.connects))
+	for slug, n := range s.connects {
+		connects[slug] = n
+	}
+	lastUsed := make(map[string]time.Time, len(s.lastUsed))
+	for slug, at := range s.lastUsed {
+		lastUsed[slug] = at
+	}
+	s.mu.Unlock()
+
+	ret := make([]agent.TunnelStatus, 0, len(tunnels))
+	for slug, tunnel := range tunnels {
+		status := agent.TunnelStatus{
+			Org:               slug,
+			ActiveConnections: connects[slug],
+			LastUsed:          lastUsed[slug],
+		}
+		if state := tunnel.State; state != nil {
+			status.Peer = state.Name
+			status.PeerIP = state.Peer.Peerip
+			status.Region = state.Region
+		}
+
+		stats, err := tunnel.Stats()
+		if err != nil {
+			status.Error = err.Error()
+		}
+		status.Endpoint = stats.Endpoint
+		status.Transport = stats.Transport
+		status.LastHandshake = stats.LastHandshake
+		status.RxBytes = stats.RxBytes
+		status.TxBytes = stats.TxBytes
+		status.DNSQueries = stats.DNSQueries
+		status.DNSErrors = stats.DNSErrors
+
+		ret = append(ret, status)
+	}
+
+	slices.SortFunc(ret, func(a, b agent.TunnelStatus) int {
+		return strings.Compare(a.Org, b.Org)
+	})
+
+	return ret
+}
+
 func (s *server) probeTunnel(ctx context.Context, slug string) (err error) {
 	tunnel := s.tunnelFor(slug)
 	if tunnel == nil {
@@ -344,6 +502,7 @@ func (s *server) validateTunnelsUnlocked() error {
 	for slug, tunnel := range s.tunnels {
 		if peers[slug] == nil {
 			delete(s.tunnels, slug)
+			delete(s.lastUsed, slug)
 
 			s.printf("no peer for %s in config - closing tunnel ...", slug)
 
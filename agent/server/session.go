@@ -114,11 +114,14 @@ var handlers = map[string]handlerFunc{
 	"establish":   (*session).establish,
 	"reestablish": (*session).reestablish,
 	"connect":     (*session).connect,
+	"connectudp":  (*session).connectUDP,
 	"probe":       (*session).probe,
 	"instances":   (*session).instances,
 	"resolve":     (*session).resolve,
 	"lookupTxt":   (*session).lookupTxt,
 	"ping6":       (*session).ping6,
+	"status":      (*session).status,
+	"closeTunnel": (*session).closeTunnel,
 }
 
 var errMalformedKill = errors.New("malformed kill command")
@@ -147,6 +150,38 @@ func (s *session) ping(_ context.Context, args ...string) {
 	})
 }
 
+var errMalformedStatus = errors.New("malformed status command")
+
+func (s *session) status(_ context.Context, args ...string) {
+	if !s.noArgs(args, errMalformedStatus) {
+		return
+	}
+
+	_ = s.marshal(agent.StatusResponse{
+		Version:    buildinfo.Version().String(),
+		PID:        os.Getpid(),
+		Background: s.srv.Options.Background,
+		Tunnels:    s.srv.tunnelStatus(),
+		Metrics:    s.srv.metrics.snapshot(),
+	})
+}
+
+var errMalformedCloseTunnel = errors.New("malformed closeTunnel command")
+
+func (s *session) closeTunnel(_ context.Context, args ...string) {
+	if !s.exactArgs(1, args, errMalformedCloseTunnel) {
+		return
+	}
+
+	if err := s.srv.closeTunnel(args[0]); err != nil {
+		s.error(err)
+
+		return
+	}
+
+	s.ok()
+}
+
 var errMalformedEstablish = errors.New("malformed establish command")
 
 func (s *session) doEstablish(ctx context.Context, recycle bool, args ...string) {
@@ -185,7 +220,11 @@ func (s *session) reestablish(ctx context.Context, args ...string) {
 var errNoSuchOrg = errors.New("no such organization")
 
 func (s *session) fetchOrg(ctx context.Context, slug string) (*fly.Organization, error) {
-	orgs, err := s.srv.Client.GetOrganizations(ctx)
+	return s.srv.fetchOrg(ctx, slug)
+}
+
+func (s *server) fetchOrg(ctx context.Context, slug string) (*fly.Organization, error) {
+	orgs, err := s.Client.GetOrganizations(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -348,22 +387,81 @@ var (
 )
 
 func (s *session) connect(ctx context.Context, args ...string) {
-	if !s.exactArgs(3, args, errMalformedConnect) {
+	outconn, release := s.dialTunnel(ctx, "tcp", args, errMalformedConnect)
+	if outconn == nil {
+		return
+	}
+	defer release()
+
+	defer func() {
+		if err := outconn.Close(); err != nil && !isClosed(err) {
+			s.logger.Printf("failed closing outconn: %v", err)
+		}
+	}()
+
+	if !s.ok() {
+		return
+	}
+
+	var eg *errgroup.Group
+	eg, ctx = errgroup.WithContext(ctx)
+
+	eg.Go(func() error {
+		<-ctx.Done()
+		_ = s.conn.Close()
+		_ = outconn.Close()
+
+		return errDone
+	})
+
+	eg.Go(func() (err error) {
+		if _, err = io.Copy(s.conn, outconn); err == nil {
+			err = io.EOF
+		}
+
 		return
+	})
+
+	eg.Go(func() (err error) {
+		if _, err = io.Copy(outconn, s.conn); err == nil {
+			err = io.EOF
+		}
+
+		return
+	})
+
+	_ = eg.Wait()
+}
+
+// dialTunnel handles the "<slug> <addr> <timeout>" arguments shared by the
+// connect commands and dials addr through the org's tunnel, building it again
+// if it was closed in the meantime. The connect is counted as active, which
+// keeps the tunnel from being evicted, until release is called. It replies
+// with an error and returns nil if that fails.
+func (s *session) dialTunnel(ctx context.Context, network string, args []string, malformed error) (outconn net.Conn, release func()) {
+	if !s.exactArgs(3, args, malformed) {
+		return nil, nil
 	}
 
 	timeout, err := strconv.ParseUint(args[2], 10, 32)
 	if err != nil {
 		s.error(err)
 
-		return
+		return nil, nil
 	}
 
-	tunnel := s.srv.tunnelFor(args[0])
-	if tunnel == nil {
-		s.error(agent.ErrTunnelUnavailable)
+	release = s.srv.trackConnect(args[0])
+	defer func() {
+		if outconn == nil {
+			release()
+		}
+	}()
 
-		return
+	tunnel, err := s.srv.ensureTunnel(ctx, args[0])
+	if err != nil {
+		s.error(err)
+
+		return nil, nil
 	}
 
 	var dialContext context.Context
@@ -375,12 +473,26 @@ func (s *session) connect(ctx context.Context, args ...string) {
 	}
 	defer cancel()
 
-	outconn, err := tunnel.DialContext(dialContext, "tcp", args[1])
-	if err != nil {
+	if outconn, err = tunnel.DialContext(dialContext, network, args[1]); err != nil {
 		s.error(err)
 
+		return nil, nil
+	}
+
+	return outconn, release
+}
+
+var errMalformedConnectUDP = errors.New("malformed connectudp command")
+
+// connectUDP is the datagram counterpart of connect. After replying ok, each
+// datagram is relayed as a length-prefixed frame over the agent connection.
+func (s *session) connectUDP(ctx context.Context, args ...string) {
+	outconn, release := s.dialTunnel(ctx, "udp", args, errMalformedConnectUDP)
+	if outconn == nil {
 		return
 	}
+	defer release()
+
 	defer func() {
 		if err := outconn.Close(); err != nil && !isClosed(err) {
 			s.logger.Printf("failed closing outconn: %v", err)
@@ -402,20 +514,30 @@ func (s *session) connect(ctx context.Context, args ...string) {
 		return errDone
 	})
 
-	eg.Go(func() (err error) {
-		if _, err = io.Copy(s.conn, outconn); err == nil {
-			err = io.EOF
+	eg.Go(func() error {
+		buf := make([]byte, 65535)
+		for {
+			n, err := outconn.Read(buf)
+			if err != nil {
+				return err
+			}
+			if err := proto.WritePacket(s.conn, buf[:n]); err != nil {
+				return err
+			}
 		}
-
-		return
 	})
 
-	eg.Go(func() (err error) {
-		if _, err = io.Copy(outconn, s.conn); err == nil {
-			err = io.EOF
+	eg.Go(func() error {
+		buf := make([]byte, 65535)
+		for {
+			n, err := proto.ReadPacket(s.conn, buf)
+			if err != nil {
+				return err
+			}
+			if _, err := outconn.Write(buf[:n]); err != nil {
+				return err
+			}
 		}
-
-		return
 	})
 
 	_ = eg.Wait()
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/superfly/flyctl/agent"
+)
+
+// metricsSaveInterval is how often changed tunnel metrics are persisted.
+const metricsSaveInterval = 10 * time.Second
+
+// tunnelMetrics counts how each org's tunnel has been used across agent
+// restarts. Changes are kept in memory and persisted as JSON to path by
+// persist.
+type tunnelMetrics struct {
+	mu     sync.Mutex
+	path   string
+	byOrg  map[string]*agent.TunnelMetrics
+	dirty  bool // byOrg changed since it was last saved
+	logger interface{ Printf(string, ...any) }
+}
+
+func loadTunnelMetrics(path string, logger interface{ Printf(string, ...any) }) *tunnelMetrics {
+	m := &tunnelMetrics{
+		path:   path,
+		byOrg:  map[string]*agent.TunnelMetrics{},
+		logger: logger,
+	}
+
+	if path == "" {
+		return m
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		logger.Printf("failed reading tunnel metrics: %v", err)
+	default:
+		if err := json.Unmarshal(data, &m.byOrg); err != nil {
+			logger.Printf("failed decoding tunnel metrics: %v", err)
+			m.byOrg = map[string]*agent.TunnelMetrics{}
+		}
+	}
+
+	return m
+}
+
+// record applies fn to slug's metrics. It's cheap enough to call with s.mu
+// held; the change is saved later by persist.
+func (m *tunnelMetrics) record(slug string, fn func(*agent.TunnelMetrics)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tm := m.byOrg[slug]
+	if tm == nil {
+		tm = &agent.TunnelMetrics{}
+		m.byOrg[slug] = tm
+	}
+	fn(tm)
+
+	m.dirty = true
+}
+
+// persist saves changed metrics every metricsSaveInterval until ctx is
+// cancelled, and once more before returning.
+func (m *tunnelMetrics) persist(ctx context.Context) {
+	ticker := time.NewTicker(metricsSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.save()
+			return
+		case <-ticker.C:
+			m.save()
+		}
+	}
+}
+
+// save writes the metrics to path if they changed since the last save.
+func (m *tunnelMetrics) save() {
+	m.mu.Lock()
+	if !m.dirty || m.path == "" {
+		m.mu.Unlock()
+		return
+	}
+	data, err := json.MarshalIndent(m.byOrg, "", "  ")
+	m.dirty = false
+	m.mu.Unlock()
+
+	if err == nil {
+		err = writeFileAtomic(m.path, data)
+	}
+	if err != nil {
+		m.logger.Printf("failed saving tunnel metrics: %v", err)
+	}
+}
+
+func (m *tunnelMetrics) snapshot() map[string]agent.TunnelMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ret := make(map[string]agent.TunnelMetrics, len(m.byOrg))
+	for slug, tm := range m.byOrg {
+		ret[slug] = *tm
+	}
+	return ret
+}
+
+// writeFileAtomic replaces path with data through a temporary file, so
+// readers never see a partial write.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// lruVictims returns the least recently used tunnels to close so that at
+// most max remain open. Tunnels with active connections and keep are never
+// chosen, so the limit may be exceeded while they're busy.
+func lruVictims(lastUsed map[string]time.Time, active map[string]int, keep string, max int) []string {
+	if max <= 0 || len(lastUsed) <= max {
+		return nil
+	}
+
+	var candidates []string
+	for slug := range lastUsed {
+		if slug != keep && active[slug] == 0 {
+			candidates = append(candidates, slug)
+		}
+	}
+	slices.SortFunc(candidates, func(a, b string) int {
+		return lastUsed[a].Compare(lastUsed[b])
+	})
+
+	return candidates[:min(len(candidates), len(lastUsed)-max)]
+}
+
+// idleVictims returns the tunnels without active connections that haven't
+// been used in the last timeout.
+func idleVictims(lastUsed map[string]time.Time, active map[string]int, timeout time.Duration, now time.Time) []string {
+	var ret []string
+	for slug, at := range lastUsed {
+		if active[slug] == 0 && now.Sub(at) > timeout {
+			ret = append(ret, slug)
+		}
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// touchTunnelUnlocked marks slug's tunnel as used now. s.mu must be held.
+func (s *server) touchTunnelUnlocked(slug string) {
+	if _, ok := s.tunnels[slug]; ok {
+		s.lastUsed[slug] = time.Now()
+	}
+}
+
+// closeTunnelUnlocked closes and forgets slug's tunnel. s.mu must be held.
+func (s *server) closeTunnelUnlocked(slug, reason string) error {
+	tunnel := s.tunnels[slug]
+	if tunnel == nil {
+		return agent.ErrTunnelUnavailable
+	}
+
+	delete(s.tunnels, slug)
+	delete(s.lastUsed, slug)
+
+	s.printf("closing tunnel for %s: %s", slug, reason)
+
+	return tunnel.Close()
+}
+
+// closeTunnel closes slug's tunnel on request.
+func (s *server) closeTunnel(slug string) error {
+	s.mu.Lock()
+	err := s.closeTunnelUnlocked(slug, "closed by request")
+	s.mu.Unlock()
+
+	if !errors.Is(err, agent.ErrTunnelUnavailable) {
+		s.metrics.record(slug, func(m *agent.TunnelMetrics) { m.Closed++ })
+	}
+
+	return err
+}
+
+// enforceMaxTunnelsUnlocked evicts least recently used tunnels beyond
+// MaxTunnels, keeping the one that was just built. s.mu must be held.
+func (s *server) enforceMaxTunnelsUnlocked(keep string) {
+	for _, slug := range lruVictims(s.lastUsed, s.connects, keep, s.MaxTunnels) {
+		if err := s.closeTunnelUnlocked(slug, "too many open tunnels"); err != nil {
+			s.printf("failed closing tunnel: %v", err)
+		}
+		s.metrics.record(slug, func(m *agent.TunnelMetrics) { m.Evicted++ })
+	}
+}
+
+// evictIdleTunnels closes tunnels idle for longer than TunnelIdleTimeout
+// until ctx is cancelled.
+func (s *server) evictIdleTunnels(ctx context.Context) {
+	ticker := time.NewTicker(min(s.TunnelIdleTimeout/2, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.closeIdleTunnels(now)
+		}
+	}
+}
+
+// closeIdleTunnels closes the tunnels idle for longer than TunnelIdleTimeout
+// as of now. They're built again by the next connect through them.
+func (s *server) closeIdleTunnels(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, slug := range idleVictims(s.lastUsed, s.connects, s.TunnelIdleTimeout, now) {
+		if err := s.closeTunnelUnlocked(slug, "idle"); err != nil {
+			s.printf("failed closing tunnel: %v", err)
+		}
+		s.metrics.record(slug, func(m *agent.TunnelMetrics) { m.Evicted++ })
+	}
+}
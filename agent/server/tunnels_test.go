@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/agent"
+	"github.com/superfly/flyctl/wg"
+)
+
+func TestLRUVictims(t *testing.T) {
+	now := time.Now()
+	lastUsed := map[string]time.Time{
+		"a": now.Add(-3 * time.Minute),
+		"b": now.Add(-2 * time.Minute),
+		"c": now.Add(-1 * time.Minute),
+		"d": now,
+	}
+
+	cases := []struct {
+		name   string
+		active map[string]int
+		keep   string
+		max    int
+		want   []string
+	}{
+		{name: "unlimited", max: 0},
+		{name: "under limit", max: 4},
+		{name: "oldest first", max: 2, keep: "d", want: []string{"a", "b"}},
+		{name: "skips busy", max: 2, keep: "d", active: map[string]int{"a": 1}, want: []string{"b", "c"}},
+		{name: "skips kept", max: 3, keep: "a", want: []string{"b"}},
+		{name: "all busy", max: 1, keep: "d", active: map[string]int{"a": 1, "b": 1, "c": 1}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, lruVictims(lastUsed, tc.active, tc.keep, tc.max))
+		})
+	}
+}
+
+func TestIdleVictims(t *testing.T) {
+	now := time.Now()
+	lastUsed := map[string]time.Time{
+		"old":  now.Add(-time.Hour),
+		"busy": now.Add(-time.Hour),
+		"new":  now.Add(-time.Minute),
+	}
+
+	got := idleVictims(lastUsed, map[string]int{"busy": 1}, 10*time.Minute, now)
+	assert.Equal(t, []string{"old"}, got)
+}
+
+func TestCloseTunnelAndMetrics(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	path := filepath.Join(t.TempDir(), "metrics.json")
+
+	s := &server{
+		Options:  Options{Logger: logger, MaxTunnels: 1},
+		tunnels:  map[string]*wg.Tunnel{"a": {}, "b": {}},
+		connects: map[string]int{},
+		lastUsed: map[string]time.Time{"a": time.Now().Add(-time.Hour), "b": time.Now()},
+		metrics:  loadTunnelMetrics(path, logger),
+	}
+
+	s.mu.Lock()
+	s.enforceMaxTunnelsUnlocked("b")
+	s.mu.Unlock()
+	assert.Nil(t, s.tunnelFor("a"))
+	assert.NotNil(t, s.tunnelFor("b"))
+
+	require.NoError(t, s.closeTunnel("b"))
+	assert.ErrorIs(t, s.closeTunnel("b"), agent.ErrTunnelUnavailable)
+	assert.Empty(t, s.tunnels)
+	assert.Empty(t, s.lastUsed)
+
+	// metrics are saved when the agent stops and survive a restart
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.metrics.persist(ctx)
+
+	metrics := loadTunnelMetrics(path, logger).snapshot()
+	assert.Equal(t, 1, metrics["a"].Evicted)
+	assert.Equal(t, 1, metrics["b"].Closed)
+}
+
+func TestIdleEvictionThenConnect(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	now := time.Now()
+
+	var rebuilt []string
+	s := &server{
+		Options:  Options{Logger: logger, TunnelIdleTimeout: time.Minute},
+		tunnels:  map[string]*wg.Tunnel{"idle": {}, "dialing": {}},
+		connects: map[string]int{},
+		lastUsed: map[string]time.Time{"idle": now.Add(-time.Hour), "dialing": now.Add(-time.Hour)},
+		metrics:  loadTunnelMetrics("", logger),
+	}
+	s.rebuildTunnel = func(_ context.Context, slug string) (*wg.Tunnel, error) {
+		rebuilt = append(rebuilt, slug)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		tunnel := &wg.Tunnel{}
+		s.tunnels[slug] = tunnel
+		s.lastUsed[slug] = time.Now()
+		return tunnel, nil
+	}
+
+	// A connect is counted before its tunnel is dialed
+	release := s.trackConnect("dialing")
+
+	s.closeIdleTunnels(now)
+	assert.Nil(t, s.tunnelFor("idle"))
+	assert.NotNil(t, s.tunnelFor("dialing"))
+	release()
+
+	// The next connect through the evicted tunnel builds it again
+	tunnel, err := s.ensureTunnel(context.Background(), "idle")
+	require.NoError(t, err)
+	assert.Same(t, tunnel, s.tunnelFor("idle"))
+
+	_, err = s.ensureTunnel(context.Background(), "dialing")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"idle"}, rebuilt)
+	assert.Equal(t, 1, s.metrics.snapshot()["idle"].Evicted)
+}
@@ -17,6 +17,10 @@ const (
 
 	ConfigWireGuardState      = "wire_guard_state"
 	ConfigWireGuardWebsockets = "wire_guard_websockets"
+	ConfigAgentDNSAddr        = "agent_dns_addr"
+	ConfigAgentDNSOrg         = "agent_dns_org"
+	ConfigAgentMaxTunnels     = "agent_max_tunnels"
+	ConfigAgentTunnelIdle     = "agent_tunnel_idle_timeout"
 
 	ConfigRegistryHost = "registry_host"
 )
@@ -72,6 +72,10 @@ func initViper() {
 	viper.SetDefault(ConfigFlapsBaseUrl, "https://api.machines.dev")
 	viper.SetDefault(ConfigRegistryHost, "registry.fly.io")
 	viper.SetDefault(ConfigWireGuardWebsockets, true)
+	viper.SetDefault(ConfigAgentDNSAddr, "")
+	viper.SetDefault(ConfigAgentDNSOrg, "personal")
+	viper.SetDefault(ConfigAgentMaxTunnels, 0)
+	viper.SetDefault(ConfigAgentTunnelIdle, "0s")
 	viper.BindEnv(ConfigVerboseOutput, "VERBOSE")
 	viper.BindEnv(ConfigGQLErrorLogging, "GQLErrorLogging")
 
@@ -19,6 +19,7 @@ require (
 	github.com/docker/docker v25.0.2+incompatible
 	github.com/dustin/go-humanize v1.0.1
 	github.com/ejcx/sshcert v1.1.0
+	github.com/evanphx/json-patch/v5 v5.9.0
 	github.com/getsentry/sentry-go v0.27.0
 	github.com/gofrs/flock v0.8.1
 	github.com/google/go-cmp v0.6.0
@@ -48,6 +49,7 @@ require (
 	github.com/pelletier/go-toml/v2 v2.1.2-0.20240125232133-05bedf36d8d1
 	github.com/pkg/errors v0.9.1
 	github.com/pkg/sftp v1.13.6
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/samber/lo v1.39.0
 	github.com/skratchdot/open-golang v0.0.0-20200116055534-eef842397966
 	github.com/sourcegraph/conc v0.3.0
@@ -75,6 +77,7 @@ require (
 	gopkg.in/yaml.v2 v2.4.0
 	gopkg.in/yaml.v3 v3.0.1
 	nhooyr.io/websocket v1.8.10
+	rsc.io/qr v0.2.0
 )
 
 require (
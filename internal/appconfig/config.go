@@ -56,6 +56,7 @@ type Config struct {
 	// Others, less important.
 	Statics []Static   `toml:"statics,omitempty" json:"statics,omitempty"`
 	Metrics []*Metrics `toml:"metrics,omitempty" json:"metrics,omitempty"`
+	Jobs    []Job      `toml:"jobs,omitempty" json:"jobs,omitempty"`
 
 	// MergedFiles is a list of files that have been merged from the app config and flags.
 	MergedFiles []*fly.File `toml:"-" json:"-"`
@@ -233,6 +233,16 @@ func TestToDefinition(t *testing.T) {
 				"processes": []any{"web"},
 			},
 		},
+		"jobs": []any{
+			map[string]any{
+				"name":     "nightly-report",
+				"schedule": "30 2 * * 1-5",
+				"timezone": "Europe/Paris",
+				"command":  "bin/report --all",
+				"process":  "task",
+				"region":   "cdg",
+			},
+		},
 		"statics": []any{
 			map[string]any{
 				"guest_path": "/path/to/statics",
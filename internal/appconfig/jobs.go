@@ -0,0 +1,185 @@
+package appconfig
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/shlex"
+	"github.com/robfig/cron/v3"
+	fly "github.com/superfly/fly-go"
+)
+
+const (
+	// JobMetadataKey marks a machine as running the [[jobs]] entry of that name.
+	JobMetadataKey = "fly_job"
+	// JobScheduleMetadataKey holds the cron expression of the job.
+	JobScheduleMetadataKey = "fly_job_schedule"
+	// JobTimezoneMetadataKey holds the time zone the cron expression is evaluated in.
+	JobTimezoneMetadataKey = "fly_job_timezone"
+)
+
+// PlatformSchedules are the schedules the Machines API runs on its own.
+var PlatformSchedules = []string{"hourly", "daily", "weekly", "monthly"}
+
+// Job is a scheduled task defined in a [[jobs]] section. Every job is
+// deployed as one stopped machine based on the config of a process group.
+type Job struct {
+	Name     string `toml:"name" json:"name,omitempty"`
+	Schedule string `toml:"schedule" json:"schedule,omitempty"`
+	Timezone string `toml:"timezone,omitempty" json:"timezone,omitempty"`
+	Command  string `toml:"command,omitempty" json:"command,omitempty"`
+	Process  string `toml:"process,omitempty" json:"process,omitempty"`
+	Region   string `toml:"region,omitempty" json:"region,omitempty"`
+}
+
+var cronParser = cron.NewParser(
+	cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// cronPlatformSchedules maps the cron descriptors that only ask for a run per
+// period to the platform schedule of that period.
+var cronPlatformSchedules = map[string]string{
+	"@hourly":   "hourly",
+	"@daily":    "daily",
+	"@midnight": "daily",
+	"@weekly":   "weekly",
+	"@monthly":  "monthly",
+}
+
+// PlatformSchedule returns the schedule to set on the job's machine when the
+// platform can run it without flyctl's help, or an empty string when the job
+// needs `fly jobs scheduler`. Cron descriptors such as @daily are run by the
+// platform too when no time zone is set, once per period at a time it picks.
+func (j Job) PlatformSchedule() string {
+	if slices.Contains(PlatformSchedules, j.Schedule) {
+		return j.Schedule
+	}
+	if j.Timezone == "" {
+		return cronPlatformSchedules[j.Schedule]
+	}
+	return ""
+}
+
+// NeedsScheduler reports whether the job only runs while `fly jobs scheduler`
+// is running.
+func (j Job) NeedsScheduler() bool {
+	return j.PlatformSchedule() == ""
+}
+
+// ParseSchedule parses the job's cron expression in its time zone.
+func (j Job) ParseSchedule() (cron.Schedule, error) {
+	loc, err := j.Location()
+	if err != nil {
+		return nil, err
+	}
+
+	sched, err := cronParser.Parse(j.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule '%s' for job '%s': %w", j.Schedule, j.Name, err)
+	}
+
+	if spec, ok := sched.(*cron.SpecSchedule); ok {
+		spec.Location = loc
+	}
+	return sched, nil
+}
+
+// Location returns the job's time zone, UTC by default.
+func (j Job) Location() (*time.Location, error) {
+	if j.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(j.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone '%s' for job '%s': %w", j.Timezone, j.Name, err)
+	}
+	return loc, nil
+}
+
+// ToJobMachineConfig returns the config of the machine running the job. Job
+// machines are left out of the Fly Launch platform so that deploys don't
+// treat them as members of their process group.
+func (c *Config) ToJobMachineConfig(job Job, src *fly.MachineConfig) (*fly.MachineConfig, error) {
+	processGroup := job.Process
+	if processGroup == "" {
+		processGroup = c.DefaultProcessName()
+	}
+
+	mConfig, err := c.ToMachineConfig(processGroup, src)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Command != "" {
+		cmd, err := shlex.Split(job.Command)
+		if err != nil {
+			return nil, fmt.Errorf("can't shell split command for job '%s': %w", job.Name, err)
+		}
+		mConfig.Init.Cmd = cmd
+	}
+
+	// Volumes can only be attached to a single machine, they stay with the
+	// machines of the process group.
+	mConfig.Mounts = nil
+	mConfig.Services = nil
+	mConfig.Checks = nil
+	mConfig.Standbys = nil
+	mConfig.Schedule = job.PlatformSchedule()
+	mConfig.Restart = fly.MachineRestart{Policy: fly.MachineRestartPolicyNo}
+
+	delete(mConfig.Metadata, fly.MachineConfigMetadataKeyFlyPlatformVersion)
+	mConfig.Metadata[JobMetadataKey] = job.Name
+	mConfig.Metadata[JobScheduleMetadataKey] = job.Schedule
+	mConfig.Metadata[JobTimezoneMetadataKey] = job.Timezone
+	if job.Timezone == "" {
+		delete(mConfig.Metadata, JobTimezoneMetadataKey)
+	}
+
+	mConfig.Env["FLY_JOB"] = job.Name
+
+	return mConfig, nil
+}
+
+func (cfg *Config) validateJobs() (extraInfo string, err error) {
+	seen := map[string]bool{}
+	processNames := cfg.ProcessNames()
+
+	for _, job := range cfg.Jobs {
+		switch {
+		case job.Name == "":
+			extraInfo += "Every [[jobs]] section needs a name\n"
+			err = ValidationError
+			continue
+		case seen[job.Name]:
+			extraInfo += fmt.Sprintf("Job '%s' is defined more than once\n", job.Name)
+			err = ValidationError
+		}
+		seen[job.Name] = true
+
+		if job.Schedule == "" {
+			extraInfo += fmt.Sprintf("Job '%s' needs a schedule\n", job.Name)
+			err = ValidationError
+		} else if !slices.Contains(PlatformSchedules, job.Schedule) {
+			if _, vErr := job.ParseSchedule(); vErr != nil {
+				extraInfo += vErr.Error() + "\n"
+				err = ValidationError
+			}
+		} else if job.Timezone != "" {
+			extraInfo += fmt.Sprintf("Job '%s' uses the '%s' platform schedule which doesn't support a timezone\n", job.Name, job.Schedule)
+			err = ValidationError
+		}
+
+		if _, vErr := shlex.Split(job.Command); vErr != nil {
+			extraInfo += fmt.Sprintf("Can't shell split command for job '%s': '%s'\n", job.Name, job.Command)
+			err = ValidationError
+		}
+
+		if job.Process != "" && !slices.Contains(processNames, job.Process) {
+			extraInfo += fmt.Sprintf("Job '%s' refers to an unknown process group '%s'\n", job.Name, job.Process)
+			err = ValidationError
+		}
+	}
+
+	return
+}
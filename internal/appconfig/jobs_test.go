@@ -0,0 +1,126 @@
+package appconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+)
+
+func TestToJobMachineConfig(t *testing.T) {
+	cfg, err := LoadConfig("./testdata/full-reference.toml")
+	require.NoError(t, err)
+	require.Len(t, cfg.Jobs, 1)
+
+	got, err := cfg.ToJobMachineConfig(cfg.Jobs[0], nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"bin/report", "--all"}, got.Init.Cmd)
+	assert.Empty(t, got.Services)
+	assert.Empty(t, got.Checks)
+	assert.Empty(t, got.Mounts)
+	assert.Equal(t, "", got.Schedule)
+	assert.Equal(t, fly.MachineRestartPolicyNo, got.Restart.Policy)
+	assert.Equal(t, "nightly-report", got.Env["FLY_JOB"])
+	assert.Equal(t, "task", got.Metadata[fly.MachineConfigMetadataKeyFlyProcessGroup])
+	assert.Equal(t, "nightly-report", got.Metadata[JobMetadataKey])
+	assert.Equal(t, "30 2 * * 1-5", got.Metadata[JobScheduleMetadataKey])
+	assert.Equal(t, "Europe/Paris", got.Metadata[JobTimezoneMetadataKey])
+	assert.NotContains(t, got.Metadata, fly.MachineConfigMetadataKeyFlyPlatformVersion)
+
+	// Platform schedules are handed over to the Machines API
+	got, err = cfg.ToJobMachineConfig(Job{Name: "cleanup", Schedule: "daily", Process: "web"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "daily", got.Schedule)
+	assert.Equal(t, []string{"run", "web"}, got.Init.Cmd)
+	assert.NotContains(t, got.Metadata, JobTimezoneMetadataKey)
+}
+
+func TestJob_PlatformSchedule(t *testing.T) {
+	cases := []struct {
+		job      Job
+		schedule string
+	}{
+		{Job{Schedule: "daily"}, "daily"},
+		{Job{Schedule: "@hourly"}, "hourly"},
+		{Job{Schedule: "@midnight"}, "daily"},
+		{Job{Schedule: "@monthly"}, "monthly"},
+		{Job{Schedule: "@daily", Timezone: "Europe/Paris"}, ""},
+		{Job{Schedule: "0 0 * * *"}, ""},
+		{Job{Schedule: "@every 90m"}, ""},
+	}
+
+	for _, tc := range cases {
+		assert.Equal(t, tc.schedule, tc.job.PlatformSchedule(), tc.job.Schedule)
+		assert.Equal(t, tc.schedule == "", tc.job.NeedsScheduler(), tc.job.Schedule)
+	}
+}
+
+func TestConfig_ValidateJobs(t *testing.T) {
+	testcases := []struct {
+		name   string
+		jobs   []Job
+		errMsg string
+	}{
+		{
+			name: "valid",
+			jobs: []Job{
+				{Name: "a", Schedule: "*/15 * * * *"},
+				{Name: "b", Schedule: "@every 90m", Timezone: "America/New_York"},
+				{Name: "c", Schedule: "weekly", Process: "web"},
+			},
+		},
+		{
+			name:   "missing name",
+			jobs:   []Job{{Schedule: "daily"}},
+			errMsg: "Every [[jobs]] section needs a name",
+		},
+		{
+			name:   "duplicate name",
+			jobs:   []Job{{Name: "a", Schedule: "daily"}, {Name: "a", Schedule: "hourly"}},
+			errMsg: "Job 'a' is defined more than once",
+		},
+		{
+			name:   "missing schedule",
+			jobs:   []Job{{Name: "a"}},
+			errMsg: "Job 'a' needs a schedule",
+		},
+		{
+			name:   "invalid schedule",
+			jobs:   []Job{{Name: "a", Schedule: "61 * * * *"}},
+			errMsg: "invalid schedule '61 * * * *' for job 'a'",
+		},
+		{
+			name:   "invalid timezone",
+			jobs:   []Job{{Name: "a", Schedule: "0 * * * *", Timezone: "Mars/Olympus"}},
+			errMsg: "invalid timezone 'Mars/Olympus' for job 'a'",
+		},
+		{
+			name:   "timezone with platform schedule",
+			jobs:   []Job{{Name: "a", Schedule: "daily", Timezone: "UTC"}},
+			errMsg: "doesn't support a timezone",
+		},
+		{
+			name:   "unknown process group",
+			jobs:   []Job{{Name: "a", Schedule: "daily", Process: "worker"}},
+			errMsg: "unknown process group 'worker'",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewConfig()
+			cfg.Processes = map[string]string{"web": "run web"}
+			cfg.Jobs = tc.jobs
+
+			extraInfo, err := cfg.validateJobs()
+			if tc.errMsg == "" {
+				require.NoError(t, err, extraInfo)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, extraInfo, tc.errMsg)
+		})
+	}
+}
@@ -402,6 +402,17 @@ func TestLoadTOMLAppConfigReferenceFormat(t *testing.T) {
 			},
 		},
 
+		Jobs: []Job{
+			{
+				Name:     "nightly-report",
+				Schedule: "30 2 * * 1-5",
+				Timezone: "Europe/Paris",
+				Command:  "bin/report --all",
+				Process:  "task",
+				Region:   "cdg",
+			},
+		},
+
 		HTTPService: &HTTPService{
 			InternalPort:       8080,
 			ForceHTTPS:         true,
@@ -35,6 +35,7 @@ func (cfg *Config) Validate(ctx context.Context) (err error, extra_info string)
 		cfg.validateMachineConversion,
 		cfg.validateConsoleCommand,
 		cfg.validateMounts,
+		cfg.validateJobs,
 	}
 
 	extra_info = fmt.Sprintf("Validating %s\n", cfg.ConfigFilePath())
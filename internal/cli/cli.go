@@ -106,6 +106,10 @@ func Run(ctx context.Context, io *iostreams.IOStreams, args ...string) int {
 		// fail CI on. Print a warning and exit 0. Remove this once we're fully on Machines!
 		printError(io, cs, cmd, err)
 		return 0
+	case hasExitCode(err):
+		printError(io, cs, cmd, err)
+		code, _ := flyerr.GetErrorExitCode(err)
+		return code
 	default:
 		printError(io, cs, cmd, err)
 
@@ -130,6 +134,11 @@ func isUnchangedError(err error) bool {
 	return false
 }
 
+func hasExitCode(err error) bool {
+	_, ok := flyerr.GetErrorExitCode(err)
+	return ok
+}
+
 func printError(io *iostreams.IOStreams, cs *iostreams.ColorScheme, cmd *cobra.Command, err error) {
 	if env.IS_GH_ACTION() && env.IsTruthy("FLY_GHA_ERROR_ANNOTATION") {
 		printGHAErrorAnnotation(cmd, err)
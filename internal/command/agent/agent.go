@@ -4,6 +4,7 @@ package agent
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/spf13/cobra"
@@ -20,7 +21,7 @@ import (
 func New() (cmd *cobra.Command) {
 	const (
 		short = "Commands that manage the Fly agent, a background process that manages flyctl wireguard connections"
-		long  = "The Fly agent is a background process that manages wireguard connections started by flyctl.\nCommands such as 'fly ssh' and 'fly proxy' use this agent.\nGenerally, the agent starts and stops automatically. These commands are useful for debugging.\n"
+		long  = "The Fly agent is a background process that manages wireguard connections started by flyctl.\nCommands such as 'fly ssh' and 'fly proxy' use this agent.\nGenerally, the agent starts and stops automatically. These commands are useful for debugging.\n\nSet agent_dns_addr in the config file (or FLY_AGENT_DNS_ADDR), e.g. to 127.0.0.1:5353,\nto have the agent serve DNS for .internal and .flycast names and forward other queries to the\nsystem's resolvers. Private names are resolved through the tunnel of the organization set with\nagent_dns_org (or FLY_AGENT_DNS_ORG), your personal one by default.\n"
 		usage = "agent <command>"
 	)
 
@@ -29,6 +30,8 @@ func New() (cmd *cobra.Command) {
 	cmd.AddCommand(
 		newRun(),
 		newPing(),
+		newStatus(),
+		newTunnels(),
 		newStart(),
 		newStop(),
 		newRestart(),
@@ -66,5 +69,9 @@ func dial(ctx context.Context) (client *agent.Client, err error) {
 }
 
 func socketPath(ctx context.Context) string {
+	if path := os.Getenv("FLY_AGENT_SOCKET"); path != "" {
+		return path
+	}
+
 	return filepath.Join(state.ConfigDirectory(ctx), "fly-agent.sock")
 }
@@ -65,6 +65,12 @@ func run(ctx context.Context) error {
 		Background:       logPath != "",
 		ConfigFile:       state.ConfigFile(ctx),
 		ConfigWebsockets: viper.GetBool(flyctl.ConfigWireGuardWebsockets),
+		DNSAddr:          viper.GetString(flyctl.ConfigAgentDNSAddr),
+		DNSOrg:           viper.GetString(flyctl.ConfigAgentDNSOrg),
+
+		MaxTunnels:        viper.GetInt(flyctl.ConfigAgentMaxTunnels),
+		TunnelIdleTimeout: viper.GetDuration(flyctl.ConfigAgentTunnelIdle),
+		MetricsFile:       filepath.Join(state.ConfigDirectory(ctx), "agent-tunnels.json"),
 	}
 
 	return server.Run(ctx, opt)
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/agent"
+	"github.com/superfly/flyctl/iostreams"
+
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+)
+
+func newStatus() (cmd *cobra.Command) {
+	const (
+		short = "Show the Fly agent's WireGuard tunnels and their statistics"
+		long  = short + "\n"
+	)
+
+	cmd = command.New("status", short, long, runStatus)
+
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd, flag.JSONOutput())
+	return
+}
+
+func runStatus(ctx context.Context) (err error) {
+	var client *agent.Client
+	if client, err = dial(ctx); err != nil {
+		return
+	}
+
+	var status agent.StatusResponse
+	if status, err = client.Status(ctx); err != nil {
+		err = fmt.Errorf("failed fetching agent status: %w", err)
+
+		return
+	}
+
+	out := iostreams.FromContext(ctx).Out
+	if config.FromContext(ctx).JSONOutput {
+		return render.JSON(out, status)
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "%-10s: %d\n", "PID", status.PID)
+	fmt.Fprintf(&buf, "%-10s: %s\n", "Version", status.Version)
+	fmt.Fprintf(&buf, "%-10s: %t\n", "Background", status.Background)
+	fmt.Fprintln(&buf)
+
+	if _, err = buf.WriteTo(out); err != nil {
+		return
+	}
+
+	if len(status.Tunnels) == 0 {
+		fmt.Fprintln(out, "No tunnels are open.")
+
+		return
+	}
+
+	return render.Table(out, "Tunnels", tunnelRows(status.Tunnels),
+		"Org", "Peer", "Endpoint", "Transport", "Last Handshake", "Received", "Sent", "Connections", "DNS Queries", "DNS Errors")
+}
+
+func tunnelRows(tunnels []agent.TunnelStatus) [][]string {
+	rows := make([][]string, 0, len(tunnels))
+	for _, t := range tunnels {
+		handshake := "never"
+		if !t.LastHandshake.IsZero() {
+			handshake = humanize.Time(t.LastHandshake)
+		}
+
+		rows = append(rows, []string{
+			t.Org,
+			t.Peer,
+			t.Endpoint,
+			t.Transport,
+			handshake,
+			humanize.IBytes(t.RxBytes),
+			humanize.IBytes(t.TxBytes),
+			strconv.Itoa(t.ActiveConnections),
+			strconv.FormatUint(t.DNSQueries, 10),
+			strconv.FormatUint(t.DNSErrors, 10),
+		})
+	}
+	return rows
+}
@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/agent"
+	"github.com/superfly/flyctl/iostreams"
+
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+)
+
+func newTunnels() (cmd *cobra.Command) {
+	const (
+		short = "Manage the Fly agent's WireGuard tunnels"
+		long  = short + `
+
+The agent keeps a tunnel open for each organization it has connected to.
+Set agent_max_tunnels in the config file (or FLY_AGENT_MAX_TUNNELS) to cap how
+many stay open, closing the least recently used idle ones first, and
+agent_tunnel_idle_timeout (or FLY_AGENT_TUNNEL_IDLE_TIMEOUT), e.g. to 30m, to
+close tunnels that go unused.
+`
+	)
+
+	cmd = command.New("tunnels", short, long, nil)
+
+	cmd.AddCommand(
+		newTunnelsList(),
+		newTunnelsClose(),
+	)
+
+	return
+}
+
+func newTunnelsList() (cmd *cobra.Command) {
+	const (
+		short = "List open tunnels and how often each org's tunnel was reused or rebuilt"
+		long  = short + "\n"
+	)
+
+	cmd = command.New("list", short, long, runTunnelsList)
+
+	cmd.Args = cobra.NoArgs
+	cmd.Aliases = []string{"ls"}
+
+	flag.Add(cmd, flag.JSONOutput())
+	return
+}
+
+func runTunnelsList(ctx context.Context) (err error) {
+	var client *agent.Client
+	if client, err = dial(ctx); err != nil {
+		return
+	}
+
+	var status agent.StatusResponse
+	if status, err = client.Status(ctx); err != nil {
+		err = fmt.Errorf("failed fetching agent status: %w", err)
+
+		return
+	}
+
+	out := iostreams.FromContext(ctx).Out
+	if config.FromContext(ctx).JSONOutput {
+		return render.JSON(out, struct {
+			Tunnels []agent.TunnelStatus
+			Metrics map[string]agent.TunnelMetrics
+		}{status.Tunnels, status.Metrics})
+	}
+
+	return render.Table(out, "", tunnelMetricRows(status),
+		"Org", "Open", "Last Used", "Built", "Reused", "Rebuilt", "Evicted", "Closed")
+}
+
+func tunnelMetricRows(status agent.StatusResponse) [][]string {
+	open := map[string]agent.TunnelStatus{}
+	orgs := make([]string, 0, len(status.Metrics))
+	for _, t := range status.Tunnels {
+		open[t.Org] = t
+		orgs = append(orgs, t.Org)
+	}
+	for org := range status.Metrics {
+		if _, ok := open[org]; !ok {
+			orgs = append(orgs, org)
+		}
+	}
+	slices.Sort(orgs)
+
+	rows := make([][]string, 0, len(orgs))
+	for _, org := range orgs {
+		var (
+			t, isOpen = open[org]
+			m         = status.Metrics[org]
+			lastUsed  = ""
+		)
+		if isOpen && !t.LastUsed.IsZero() {
+			lastUsed = humanize.Time(t.LastUsed)
+		}
+
+		rows = append(rows, []string{
+			org,
+			strconv.FormatBool(isOpen),
+			lastUsed,
+			strconv.Itoa(m.Built),
+			strconv.Itoa(m.Reused),
+			strconv.Itoa(m.Rebuilt),
+			strconv.Itoa(m.Evicted),
+			strconv.Itoa(m.Closed),
+		})
+	}
+	return rows
+}
+
+func newTunnelsClose() (cmd *cobra.Command) {
+	const (
+		short = "Close the agent's tunnel to an organization"
+		long  = short + "\n"
+	)
+
+	cmd = command.New("close <org>", short, long, runTunnelsClose)
+
+	cmd.Args = cobra.ExactArgs(1)
+
+	return
+}
+
+func runTunnelsClose(ctx context.Context) (err error) {
+	var client *agent.Client
+	if client, err = dial(ctx); err != nil {
+		return
+	}
+
+	slug := flag.FirstArg(ctx)
+	if err = client.CloseTunnel(ctx, slug); err != nil {
+		return fmt.Errorf("failed closing tunnel to %s: %w", slug, err)
+	}
+
+	fmt.Fprintf(iostreams.FromContext(ctx).Out, "Closed tunnel to %s\n", slug)
+
+	return
+}
@@ -103,6 +103,7 @@ type machineDeployment struct {
 	immediateMaxConcurrent int
 	volumeInitialSize      int
 	processGroups          map[string]interface{}
+	deployLogs             *deployLogs
 }
 
 func NewMachineDeployment(ctx context.Context, args MachineDeploymentArgs) (MachineDeployment, error) {
@@ -45,6 +45,8 @@ func (md *machineDeployment) DeployMachinesApp(ctx context.Context) error {
 
 	ctx = flaps.NewContext(ctx, md.flapsClient)
 
+	md.deployLogs = newDeployLogs(md.apiClient, md.app.Name)
+
 	if err := md.updateReleaseInBackend(ctx, "running"); err != nil {
 		tracing.RecordError(span, err, "failed to update release")
 		return fmt.Errorf("failed to set release status to 'running': %w", err)
@@ -57,6 +59,10 @@ func (md *machineDeployment) DeployMachinesApp(ctx context.Context) error {
 		err = md.deployMachinesApp(ctx)
 	}
 
+	if path := md.deployLogs.close(err != nil); path != "" {
+		fmt.Fprintf(md.io.ErrOut, "Logs of the machines captured during the deploy were saved to %s\n", path)
+	}
+
 	var status string
 	switch {
 	case err == nil:
@@ -254,6 +260,7 @@ func (md *machineDeployment) deployCreateMachinesForGroups(ctx context.Context,
 //   - Remove spare machines from removed groups
 //   - Launch new machines on new groups
 //   - Update existing machines
+//   - Create, update or destroy the machines of scheduled jobs
 func (md *machineDeployment) deployMachinesApp(ctx context.Context) error {
 	ctx, span := tracing.GetTracer().Start(ctx, "deploy_new_machines")
 	defer span.End()
@@ -302,7 +309,11 @@ func (md *machineDeployment) deployMachinesApp(ctx context.Context) error {
 		machineUpdateEntries = append(machineUpdateEntries, &machineUpdateEntry{leasableMachine: lm, launchInput: li})
 	}
 
-	return md.updateExistingMachines(ctx, machineUpdateEntries)
+	if err := md.updateExistingMachines(ctx, machineUpdateEntries); err != nil {
+		return err
+	}
+
+	return md.deployJobs(ctx)
 }
 
 type machineUpdateEntry struct {
@@ -377,13 +388,16 @@ func suggestChangeWaitTimeout(err error, flagName string) error {
 	return err
 }
 
-func (md *machineDeployment) waitForMachine(ctx context.Context, e *machineUpdateEntry) error {
+func (md *machineDeployment) waitForMachine(ctx context.Context, e *machineUpdateEntry) (err error) {
 	lm := e.leasableMachine
 	// Don't wait for SkipLaunch machines, they are updated but not started
 	if e.launchInput.SkipLaunch {
 		return nil
 	}
 
+	reportLogs := md.captureMachineLogs(ctx, lm)
+	defer func() { reportLogs(err) }()
+
 	if !md.skipHealthChecks {
 		if err := lm.WaitForState(ctx, fly.MachineStateStarted, md.waitTimeout, false); err != nil {
 			err = suggestChangeWaitTimeout(err, "wait-timeout")
@@ -766,7 +780,7 @@ type metadata struct {
 	value string
 }
 
-func (md *machineDeployment) spawnMachineInGroup(ctx context.Context, groupName string, standbyFor []string, opts ...spawnOptionsFn) (machine.LeasableMachine, error) {
+func (md *machineDeployment) spawnMachineInGroup(ctx context.Context, groupName string, standbyFor []string, opts ...spawnOptionsFn) (_ machine.LeasableMachine, err error) {
 	options := spawnOptions{
 		meta:  []metadata{},
 		guest: md.machineGuest,
@@ -815,6 +829,9 @@ func (md *machineDeployment) spawnMachineInGroup(ctx context.Context, groupName
 		return lm, nil
 	}
 
+	reportLogs := md.captureMachineLogs(ctx, lm)
+	defer func() { reportLogs(err) }()
+
 	// Otherwise wait for the machine to start
 	if err := md.doSmokeChecks(ctx, lm); err != nil {
 		return nil, err
@@ -1015,22 +1032,9 @@ func (md *machineDeployment) doSmokeChecks(ctx context.Context, lm machine.Leasa
 	resumeLogFn := statuslogger.Pause(ctx)
 	defer resumeLogFn()
 
+	// The logs of the machine are shown by the caller, which captured them
+	// while waiting for it.
 	fmt.Fprintf(md.io.ErrOut, "Smoke checks for %s failed: %v\n", md.colorize.Bold(lm.Machine().ID), err)
-	fmt.Fprintf(md.io.ErrOut, "Check its logs: here's the last lines below, or run 'fly logs -i %s':\n", lm.Machine().ID)
-	logs, _, logErr := md.apiClient.GetAppLogs(ctx, md.app.Name, "", md.appConfig.PrimaryRegion, lm.Machine().ID)
-	if fly.IsNotAuthenticatedError(logErr) {
-		fmt.Fprintf(md.io.ErrOut, "Warn: not authorized to retrieve app logs (this can happen when using deploy tokens), so we can't show you what failed. Use `fly logs -i %s` or open the monitoring dashboard to see them: https://fly.io/apps/%s/monitoring?region=&instance=%s\n", lm.Machine().ID, md.appConfig.AppName, lm.Machine().ID)
-	} else {
-		if logErr != nil {
-			return fmt.Errorf("error getting logs for machine %s: %w", lm.Machine().ID, logErr)
-		}
-		for _, l := range logs {
-			// Ideally we should use InstanceID here, but it's not available in the logs.
-			if l.Timestamp >= lm.Machine().UpdatedAt {
-				fmt.Fprintf(md.io.ErrOut, "  %s\n", l.Message)
-			}
-		}
-	}
 
 	return fmt.Errorf("smoke checks for %s failed: %v", lm.Machine().ID, err)
 }
@@ -0,0 +1,109 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/samber/lo"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	machcmd "github.com/superfly/flyctl/internal/command/machine"
+	"github.com/superfly/flyctl/internal/machine"
+)
+
+// deployJobs reconciles the machines of [[jobs]] sections with fly.toml:
+//   - Create a stopped machine for every new job
+//   - Update the machines of existing jobs to the new release
+//   - Destroy the machines of jobs that were removed
+//
+// Job machines don't carry the fly_platform_version metadata so they are
+// ignored by the rest of the deployment.
+func (md *machineDeployment) deployJobs(ctx context.Context) error {
+	machines, err := md.flapsClient.List(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list job machines: %w", err)
+	}
+	jobMachines := lo.Filter(machines, func(m *fly.Machine, _ int) bool {
+		return m.Config != nil && m.Config.Metadata[appconfig.JobMetadataKey] != "" && m.IsActive()
+	})
+	if len(md.appConfig.Jobs) == 0 && len(jobMachines) == 0 {
+		return nil
+	}
+	byJob := lo.KeyBy(jobMachines, func(m *fly.Machine) string {
+		return m.Config.Metadata[appconfig.JobMetadataKey]
+	})
+
+	fmt.Fprintf(md.io.Out, "Updating scheduled jobs\n")
+
+	for _, job := range md.appConfig.Jobs {
+		if job.NeedsScheduler() {
+			fmt.Fprintf(md.io.ErrOut, "%s Job %s's schedule '%s' can't be run by the platform, it only runs while 'fly jobs scheduler' is running\n",
+				aurora.Yellow("[WARNING]"), job.Name, job.Schedule)
+		}
+	}
+
+	for _, job := range md.appConfig.Jobs {
+		m, exists := byJob[job.Name]
+		delete(byJob, job.Name)
+
+		var src *fly.MachineConfig
+		if exists {
+			src = m.Config
+		}
+		mConfig, err := md.appConfig.ToJobMachineConfig(job, src)
+		if err != nil {
+			return err
+		}
+		if mConfig.Guest == nil {
+			mConfig.Guest = md.machineGuest
+		}
+		mConfig.Image = md.img
+		md.setMachineReleaseData(mConfig)
+		delete(mConfig.Metadata, fly.MachineConfigMetadataKeyFlyPlatformVersion)
+
+		if !exists {
+			region := lo.Ternary(job.Region != "", job.Region, md.appConfig.PrimaryRegion)
+			newMachine, err := md.flapsClient.Launch(ctx, fly.LaunchMachineInput{
+				Region:     region,
+				Config:     mConfig,
+				SkipLaunch: true,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create machine for job '%s': %w", job.Name, err)
+			}
+			fmt.Fprintf(md.io.Out, "  Created machine %s for job %s\n", md.colorize.Bold(newMachine.ID), md.colorize.Bold(job.Name))
+			continue
+		}
+
+		if err := md.updateJobMachine(ctx, m, mConfig); err != nil {
+			return fmt.Errorf("failed to update machine %s of job '%s': %w", m.ID, job.Name, err)
+		}
+		fmt.Fprintf(md.io.Out, "  Updated machine %s of job %s\n", md.colorize.Bold(m.ID), md.colorize.Bold(job.Name))
+	}
+
+	for name, m := range byJob {
+		if err := machcmd.Destroy(ctx, md.app, m, true); err != nil {
+			return fmt.Errorf("failed to destroy machine %s of removed job '%s': %w", m.ID, name, err)
+		}
+	}
+
+	return nil
+}
+
+func (md *machineDeployment) updateJobMachine(ctx context.Context, m *fly.Machine, mConfig *fly.MachineConfig) error {
+	m, releaseLeaseFunc, err := machine.AcquireLease(ctx, m)
+	defer releaseLeaseFunc()
+	if err != nil {
+		return err
+	}
+
+	_, err = md.flapsClient.Update(ctx, fly.LaunchMachineInput{
+		ID:     m.ID,
+		Region: m.Region,
+		Config: mConfig,
+		// Job machines are only ever started by their schedule
+		SkipLaunch: true,
+	}, m.LeaseNonce)
+	return err
+}
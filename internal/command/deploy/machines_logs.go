@@ -0,0 +1,262 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/azazeal/pause"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/machine"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/internal/statuslogger"
+	"github.com/superfly/flyctl/logs"
+)
+
+const (
+	// deployLogLines is how many log lines of a failing machine are shown
+	// next to the error.
+	deployLogLines = 20
+	// deployLogsGracePeriod is how long logs keep being captured after a
+	// machine failed, as log shipping lags behind the machine.
+	deployLogsGracePeriod = 2 * time.Second
+)
+
+// deployLogs captures the logs of the machines being deployed so that they
+// can be shown when a machine fails. Everything captured is also written to a
+// file, which is kept when the deploy fails.
+//
+// A single stream polls the logs of the whole app, however many machines are
+// being waited on, and only the entries of watched machines are kept.
+type deployLogs struct {
+	apiClient *fly.Client
+	appName   string
+
+	startOnce sync.Once
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	mu      sync.Mutex
+	file    *os.File
+	written int
+	watched map[string]time.Time
+	entries map[string]*logRing
+	err     error
+}
+
+func newDeployLogs(apiClient *fly.Client, appName string) *deployLogs {
+	return &deployLogs{
+		apiClient: apiClient,
+		appName:   appName,
+		watched:   map[string]time.Time{},
+		entries:   map[string]*logRing{},
+	}
+}
+
+// start polls the logs of the app until close is called.
+func (d *deployLogs) start(ctx context.Context) {
+	d.startOnce.Do(func() {
+		// The stream outlives the wait of the machine that started it
+		ctx, d.cancel = context.WithCancel(context.WithoutCancel(ctx))
+		d.done = make(chan struct{})
+		c := make(chan logs.LogEntry)
+
+		go func() {
+			defer close(c)
+
+			opts := &logs.LogOptions{AppName: d.appName}
+			if err := logs.Poll(ctx, c, d.apiClient, opts); err != nil && !errors.Is(err, context.Canceled) {
+				d.mu.Lock()
+				d.err = err
+				d.mu.Unlock()
+			}
+		}()
+
+		go func() {
+			defer close(d.done)
+
+			for entry := range c {
+				d.consume(entry)
+			}
+		}()
+	})
+}
+
+// watch captures the logs machine emitted since it was updated, until the
+// returned function is called.
+func (d *deployLogs) watch(ctx context.Context, m *fly.Machine) (stop func()) {
+	if d == nil {
+		return func() {}
+	}
+
+	since, err := time.Parse(time.RFC3339, m.UpdatedAt)
+	if err != nil {
+		since = time.Now()
+	}
+
+	d.mu.Lock()
+	d.watched[m.ID] = since
+	d.mu.Unlock()
+
+	d.start(ctx)
+
+	return func() {
+		d.mu.Lock()
+		delete(d.watched, m.ID)
+		d.mu.Unlock()
+	}
+}
+
+// consume records entry when it was logged by a watched machine since the
+// machine was updated.
+func (d *deployLogs) consume(entry logs.LogEntry) {
+	d.mu.Lock()
+	since, ok := d.watched[entry.Instance]
+	d.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	if ts, err := entry.Time(); err == nil && ts.Before(since) {
+		return
+	}
+	d.record(entry.Instance, entry)
+}
+
+func (d *deployLogs) record(machineID string, entry logs.LogEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ring, ok := d.entries[machineID]
+	if !ok {
+		ring = &logRing{}
+		d.entries[machineID] = ring
+	}
+	ring.add(entry)
+
+	if d.file == nil {
+		f, err := os.CreateTemp("", fmt.Sprintf("fly-deploy-%s-*.log", d.appName))
+		if err != nil {
+			return
+		}
+		d.file = f
+	}
+	if err := render.LogEntry(d.file, entry, render.NoColor(), render.HideRegion()); err == nil {
+		d.written++
+	}
+}
+
+// tail returns the last n entries captured for a machine, along with the
+// error that stopped the capture, if any.
+func (d *deployLogs) tail(machineID string, n int) ([]logs.LogEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var entries []logs.LogEntry
+	if ring, ok := d.entries[machineID]; ok {
+		entries = ring.last(n)
+	}
+	return entries, d.err
+}
+
+// logRing keeps the last deployLogLines entries of a machine.
+type logRing struct {
+	entries []logs.LogEntry
+	next    int
+}
+
+func (r *logRing) add(entry logs.LogEntry) {
+	if len(r.entries) < deployLogLines {
+		r.entries = append(r.entries, entry)
+		return
+	}
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % deployLogLines
+}
+
+// last returns the newest n entries, oldest first.
+func (r *logRing) last(n int) []logs.LogEntry {
+	entries := append(slices.Clone(r.entries[r.next:]), r.entries[:r.next]...)
+	if len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	return entries
+}
+
+// close closes the capture file, keeping it when keep is set. It returns the
+// path of the kept file, if any.
+func (d *deployLogs) close(keep bool) string {
+	if d == nil {
+		return ""
+	}
+
+	if d.cancel != nil {
+		d.cancel()
+		<-d.done
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return ""
+	}
+
+	name := d.file.Name()
+	d.file.Close()
+	d.file = nil
+
+	if !keep || d.written == 0 {
+		os.Remove(name)
+		return ""
+	}
+	return name
+}
+
+// captureMachineLogs captures the logs of lm until the returned function is
+// called with the outcome of waiting for it. When it failed, the last lines of
+// its logs are printed.
+func (md *machineDeployment) captureMachineLogs(ctx context.Context, lm machine.LeasableMachine) func(err error) {
+	stop := md.deployLogs.watch(ctx, lm.Machine())
+
+	return func(err error) {
+		if err == nil || errors.Is(err, context.Canceled) || md.deployLogs == nil {
+			stop()
+			return
+		}
+
+		pause.For(ctx, deployLogsGracePeriod)
+		stop()
+
+		resumeLogFn := statuslogger.Pause(ctx)
+		defer resumeLogFn()
+
+		md.printMachineLogs(md.io.ErrOut, lm.Machine().ID)
+	}
+}
+
+func (md *machineDeployment) printMachineLogs(w io.Writer, machineID string) {
+	entries, err := md.deployLogs.tail(machineID, deployLogLines)
+
+	switch {
+	case fly.IsNotAuthenticatedError(err):
+		fmt.Fprintf(w, "Warn: not authorized to retrieve app logs (this can happen when using deploy tokens), so we can't show you what failed. Use `fly logs -i %s` or open the monitoring dashboard to see them: https://fly.io/apps/%s/monitoring?region=&instance=%s\n", machineID, md.app.Name, machineID)
+		return
+	case len(entries) == 0 && err != nil:
+		fmt.Fprintf(w, "Failed to get the logs of machine %s: %v\n", machineID, err)
+		return
+	case len(entries) == 0:
+		fmt.Fprintf(w, "Machine %s didn't log anything, run 'fly logs -i %s' to check again\n", machineID, machineID)
+		return
+	}
+
+	fmt.Fprintf(w, "Last log lines of machine %s, run 'fly logs -i %s' for more:\n", md.colorize.Bold(machineID), machineID)
+	for _, entry := range entries {
+		fmt.Fprintf(w, "  %s\n", entry.Message)
+	}
+}
@@ -0,0 +1,92 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/logs"
+)
+
+func TestDeployLogs(t *testing.T) {
+	d := newDeployLogs(nil, "my-app")
+	for i := 0; i < 30; i++ {
+		d.record("m1", logs.LogEntry{
+			Instance:  "m1",
+			Timestamp: "2024-03-01T12:00:00Z",
+			Message:   "line " + strconv.Itoa(i),
+		})
+	}
+	d.record("m2", logs.LogEntry{Instance: "m2", Timestamp: "2024-03-01T12:00:00Z", Message: "other"})
+
+	entries, err := d.tail("m1", deployLogLines)
+	require.NoError(t, err)
+	require.Len(t, entries, deployLogLines)
+	require.Equal(t, "line 10", entries[0].Message)
+	require.Equal(t, "line 29", entries[deployLogLines-1].Message)
+
+	io, _, _, _ := iostreams.Test()
+	md := &machineDeployment{
+		app:        &fly.AppCompact{Name: "my-app"},
+		io:         io,
+		colorize:   io.ColorScheme(),
+		deployLogs: d,
+	}
+
+	var buf bytes.Buffer
+	md.printMachineLogs(&buf, "m2")
+	require.Equal(t, "Last log lines of machine m2, run 'fly logs -i m2' for more:\n  other\n", buf.String())
+
+	buf.Reset()
+	md.printMachineLogs(&buf, "m3")
+	require.Contains(t, buf.String(), "Machine m3 didn't log anything")
+
+	path := d.close(true)
+	require.NotEmpty(t, path)
+	defer os.Remove(path)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "line 29")
+	require.Contains(t, string(data), "other")
+}
+
+func TestDeployLogs_CloseRemovesFile(t *testing.T) {
+	d := newDeployLogs(nil, "my-app")
+	d.record("m1", logs.LogEntry{Instance: "m1", Timestamp: "2024-03-01T12:00:00Z", Message: "hi"})
+	name := d.file.Name()
+
+	require.Empty(t, d.close(false))
+	_, err := os.Stat(name)
+	require.True(t, os.IsNotExist(err))
+
+	require.Empty(t, (*deployLogs)(nil).close(true))
+}
+
+func TestDeployLogs_Consume(t *testing.T) {
+	d := newDeployLogs(nil, "my-app")
+	defer d.close(false)
+
+	// Entries are fed by hand instead of polling the API
+	d.startOnce.Do(func() {})
+
+	stop := d.watch(context.Background(), &fly.Machine{ID: "m1", UpdatedAt: "2024-03-01T12:00:00Z"})
+
+	d.consume(logs.LogEntry{Instance: "m1", Timestamp: "2024-03-01T11:59:59Z", Message: "before the update"})
+	d.consume(logs.LogEntry{Instance: "m1", Timestamp: "2024-03-01T12:00:01Z", Message: "after the update"})
+	d.consume(logs.LogEntry{Instance: "m2", Timestamp: "2024-03-01T12:00:01Z", Message: "not watched"})
+	stop()
+	d.consume(logs.LogEntry{Instance: "m1", Timestamp: "2024-03-01T12:00:02Z", Message: "after stop"})
+
+	entries, _ := d.tail("m1", deployLogLines)
+	require.Len(t, entries, 1)
+	require.Equal(t, "after the update", entries[0].Message)
+
+	entries, _ = d.tail("m2", deployLogLines)
+	require.Empty(t, entries)
+}
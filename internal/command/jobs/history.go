@@ -0,0 +1,116 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func newHistory() *cobra.Command {
+	const (
+		short = "Show the recent runs of a scheduled job"
+		long  = short + `
+
+Runs are reconstructed from the events of the job's machine, so only the most
+recent runs are available.
+`
+		usage = "history <job>"
+	)
+
+	cmd := command.New(usage, short, long, runHistory,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+
+	cmd.Args = cobra.ExactArgs(1)
+
+	flag.Add(
+		cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.JSONOutput(),
+	)
+
+	return cmd
+}
+
+func runHistory(ctx context.Context) error {
+	var (
+		io      = iostreams.FromContext(ctx)
+		appName = appconfig.NameFromContext(ctx)
+		name    = flag.FirstArg(ctx)
+	)
+
+	flapsClient, err := newFlapsClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := listJobMachines(ctx, flapsClient)
+	if err != nil {
+		return err
+	}
+
+	var found *jobMachine
+	for i := range jobs {
+		if jobs[i].Job.Name == name {
+			found = &jobs[i]
+			break
+		}
+	}
+	if found == nil {
+		return fmt.Errorf("job '%s' is not deployed on app %s", name, appName)
+	}
+
+	// The list endpoint might truncate events, get the full machine
+	m, err := flapsClient.Get(ctx, found.Machine.ID)
+	if err != nil {
+		return fmt.Errorf("could not get machine %s: %w", found.Machine.ID, err)
+	}
+
+	runs := jobRuns(m.Events)
+
+	if config.FromContext(ctx).JSONOutput {
+		return render.JSON(io.Out, runs)
+	}
+
+	if len(runs) == 0 {
+		fmt.Fprintf(io.Out, "Job %s hasn't run yet\n", name)
+		return nil
+	}
+
+	rows := [][]string{}
+	for _, run := range runs {
+		var exitedAt, duration, exitCode string
+		if run.ExitedAt != nil {
+			exitedAt = run.ExitedAt.Format(time.RFC3339)
+			duration = run.Duration().Round(time.Second).String()
+		} else {
+			exitCode = "running"
+		}
+		if run.ExitCode != nil {
+			exitCode = strconv.Itoa(*run.ExitCode)
+		}
+		if run.OOMKilled {
+			exitCode += " (OOM killed)"
+		}
+
+		rows = append(rows, []string{
+			run.StartedAt.Format(time.RFC3339),
+			exitedAt,
+			duration,
+			exitCode,
+		})
+	}
+
+	return render.Table(io.Out, fmt.Sprintf("%s (machine %s)", name, m.ID), rows, "Started", "Exited", "Duration", "Exit Code")
+}
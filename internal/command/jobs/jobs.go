@@ -11,13 +11,21 @@ import (
 
 func New() *cobra.Command {
 	const (
-		short = "Show jobs at Fly.io"
+		short = "Show jobs at Fly.io, or manage the scheduled jobs of an app"
 
-		long = `Show jobs at Fly.io, including maybe ones you should apply to`
+		long = `Show jobs at Fly.io, including maybe ones you should apply to.
+
+The subcommands list and inspect the scheduled jobs defined in the [[jobs]]
+sections of fly.toml.`
 	)
 
 	cmd := command.New("jobs", short, long, run)
-	cmd.AddCommand(NewOpen())
+	cmd.AddCommand(
+		NewOpen(),
+		newList(),
+		newHistory(),
+		newScheduler(),
+	)
 	return cmd
 }
 func run(ctx context.Context) (err error) {
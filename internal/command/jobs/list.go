@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/format"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func newList() *cobra.Command {
+	const (
+		short = "List the scheduled jobs of an app"
+		long  = short + `
+
+Shows the jobs defined in the [[jobs]] sections of fly.toml along with their
+next scheduled run and the outcome of their last run. Jobs whose schedule the
+platform can't run only run while 'fly jobs scheduler' is running.
+`
+		usage = "list"
+	)
+
+	cmd := command.New(usage, short, long, runList,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+
+	cmd.Aliases = []string{"ls"}
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(
+		cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.JSONOutput(),
+	)
+
+	return cmd
+}
+
+type jobStatus struct {
+	appconfig.Job
+	// NeedsScheduler is set for jobs that only run while `fly jobs scheduler`
+	// is running.
+	NeedsScheduler bool       `json:"needs_scheduler"`
+	MachineID      string     `json:"machine_id"`
+	State          string     `json:"state"`
+	NextRun        *time.Time `json:"next_run,omitempty"`
+	LastRun        *jobRun    `json:"last_run,omitempty"`
+}
+
+func runList(ctx context.Context) error {
+	var (
+		io      = iostreams.FromContext(ctx)
+		appName = appconfig.NameFromContext(ctx)
+		now     = time.Now()
+	)
+
+	flapsClient, err := newFlapsClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := listJobMachines(ctx, flapsClient)
+	if err != nil {
+		return err
+	}
+
+	var statuses []jobStatus
+	for _, jm := range jobs {
+		status := jobStatus{
+			Job:            jm.Job,
+			NeedsScheduler: jm.Job.NeedsScheduler(),
+			MachineID:      jm.Machine.ID,
+			State:          jm.Machine.State,
+		}
+		if status.NeedsScheduler {
+			if sched, err := jm.Job.ParseSchedule(); err == nil {
+				next := sched.Next(now)
+				status.NextRun = &next
+			}
+		}
+		if runs := jobRuns(jm.Machine.Events); len(runs) > 0 {
+			status.LastRun = &runs[0]
+		}
+		statuses = append(statuses, status)
+	}
+
+	if config.FromContext(ctx).JSONOutput {
+		return render.JSON(io.Out, statuses)
+	}
+
+	if len(statuses) == 0 {
+		fmt.Fprintf(io.Out, "No jobs are deployed on app %s\n", appName)
+		return nil
+	}
+
+	rows := [][]string{}
+	for _, s := range statuses {
+		timezone := s.Timezone
+		if timezone == "" {
+			timezone = "UTC"
+		}
+
+		nextRun := "managed by the platform"
+		if s.NeedsScheduler {
+			nextRun = "needs fly jobs scheduler"
+			if s.NextRun != nil {
+				nextRun = format.RelativeTime(*s.NextRun) + " with fly jobs scheduler"
+			}
+		}
+
+		var lastRun, exitCode string
+		if s.LastRun != nil {
+			lastRun = format.RelativeTime(s.LastRun.StartedAt)
+			switch {
+			case s.LastRun.ExitedAt == nil:
+				exitCode = "running"
+			case s.LastRun.ExitCode != nil:
+				exitCode = strconv.Itoa(*s.LastRun.ExitCode)
+			}
+		}
+
+		rows = append(rows, []string{
+			s.Name,
+			s.Schedule,
+			timezone,
+			s.MachineID,
+			s.State,
+			nextRun,
+			lastRun,
+			exitCode,
+		})
+	}
+
+	return render.Table(io.Out, appName, rows, "Name", "Schedule", "Timezone", "Machine", "State", "Next Run", "Last Run", "Exit Code")
+}
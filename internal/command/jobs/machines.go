@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/samber/lo"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/flapsutil"
+)
+
+// jobMachine is a machine deployed from a [[jobs]] section of fly.toml.
+type jobMachine struct {
+	Job     appconfig.Job
+	Machine *fly.Machine
+}
+
+func newFlapsClient(ctx context.Context) (*flaps.Client, error) {
+	return flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{
+		AppName: appconfig.NameFromContext(ctx),
+	})
+}
+
+// listJobMachines returns the job machines of the app sorted by job name. The
+// job definition is read back from the machine metadata set on deploy.
+func listJobMachines(ctx context.Context, flapsClient *flaps.Client) ([]jobMachine, error) {
+	machines, err := flapsClient.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("could not get a list of machines: %w", err)
+	}
+
+	var jobs []jobMachine
+	for _, m := range machines {
+		if m.Config == nil || !m.IsActive() {
+			continue
+		}
+		name := m.Config.Metadata[appconfig.JobMetadataKey]
+		if name == "" {
+			continue
+		}
+		jobs = append(jobs, jobMachine{
+			Job: appconfig.Job{
+				Name:     name,
+				Schedule: m.Config.Metadata[appconfig.JobScheduleMetadataKey],
+				Timezone: m.Config.Metadata[appconfig.JobTimezoneMetadataKey],
+				Process:  m.ProcessGroup(),
+				Region:   m.Region,
+			},
+			Machine: m,
+		})
+	}
+
+	slices.SortFunc(jobs, func(a, b jobMachine) int {
+		return cmp.Compare(a.Job.Name, b.Job.Name)
+	})
+
+	return jobs, nil
+}
+
+// jobRun is a single execution of a job, built from the machine events.
+type jobRun struct {
+	StartedAt time.Time  `json:"started_at"`
+	ExitedAt  *time.Time `json:"exited_at,omitempty"`
+	ExitCode  *int       `json:"exit_code,omitempty"`
+	OOMKilled bool       `json:"oom_killed,omitempty"`
+}
+
+// Duration returns how long the run took, or zero when it's still running.
+func (r jobRun) Duration() time.Duration {
+	if r.ExitedAt == nil {
+		return 0
+	}
+	return r.ExitedAt.Sub(r.StartedAt)
+}
+
+// jobRuns pairs the start and exit events of a machine, most recent run first.
+func jobRuns(events []*fly.MachineEvent) []jobRun {
+	events = slices.Clone(events)
+	slices.SortFunc(events, func(a, b *fly.MachineEvent) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+
+	var runs []jobRun
+	for _, e := range events {
+		switch e.Type {
+		case "start":
+			runs = append(runs, jobRun{StartedAt: e.Time()})
+		case "exit":
+			if len(runs) == 0 || runs[len(runs)-1].ExitedAt != nil {
+				// The start event was already rotated out
+				runs = append(runs, jobRun{StartedAt: e.Time()})
+			}
+			run := &runs[len(runs)-1]
+			run.ExitedAt = lo.ToPtr(e.Time())
+			if e.Request != nil {
+				if code, err := e.Request.GetExitCode(); err == nil {
+					run.ExitCode = &code
+				}
+				if e.Request.ExitEvent != nil {
+					run.OOMKilled = e.Request.ExitEvent.OOMKilled
+				}
+			}
+		}
+	}
+
+	slices.Reverse(runs)
+	return runs
+}
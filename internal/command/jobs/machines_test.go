@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+)
+
+func TestJobRuns(t *testing.T) {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) int64 { return base.Add(d).UnixMilli() }
+	exit := func(d time.Duration, code int) *fly.MachineEvent {
+		return &fly.MachineEvent{
+			Type:      "exit",
+			Timestamp: at(d),
+			Request:   &fly.MachineRequest{ExitEvent: &fly.MachineExitEvent{ExitCode: code}},
+		}
+	}
+
+	// Events come newest first from the API
+	events := []*fly.MachineEvent{
+		{Type: "start", Timestamp: at(2 * time.Hour)},
+		exit(time.Hour+30*time.Second, 1),
+		{Type: "start", Timestamp: at(time.Hour)},
+		exit(10*time.Second, 0),
+		{Type: "update", Timestamp: at(-time.Hour)},
+	}
+
+	runs := jobRuns(events)
+	require.Len(t, runs, 3)
+
+	require.True(t, base.Add(2*time.Hour).Equal(runs[0].StartedAt))
+	require.Nil(t, runs[0].ExitedAt)
+	require.Nil(t, runs[0].ExitCode)
+	require.Zero(t, runs[0].Duration())
+
+	require.True(t, base.Add(time.Hour).Equal(runs[1].StartedAt))
+	require.Equal(t, 1, *runs[1].ExitCode)
+	require.Equal(t, 30*time.Second, runs[1].Duration())
+
+	// The start of the oldest run was rotated out of the events
+	require.True(t, base.Add(10*time.Second).Equal(runs[2].StartedAt))
+	require.Equal(t, 0, *runs[2].ExitCode)
+}
@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+// schedulerRefreshInterval bounds how long the scheduler sleeps before it
+// picks up jobs added or changed by a deploy.
+const schedulerRefreshInterval = time.Minute
+
+func newScheduler() *cobra.Command {
+	const (
+		short = "Start the machines of jobs with cron schedules"
+		long  = short + `
+
+Jobs using the hourly, daily, weekly or monthly schedules, or the @hourly,
+@daily, @weekly and @monthly cron descriptors without a timezone, are run by
+the platform. Jobs with other cron expressions need this scheduler: it runs in the
+foreground and starts the machine of each job whenever its schedule fires.
+A job that is still running when its schedule fires again is skipped.
+
+Cron jobs only run while this command is running. Runs that fall due while
+no scheduler is running are not caught up later, so keep it running on a
+host that's always up, or use one of the platform schedules instead.
+`
+		usage = "scheduler"
+	)
+
+	cmd := command.New(usage, short, long, runScheduler,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(
+		cmd,
+		flag.App(),
+		flag.AppConfig(),
+	)
+
+	return cmd
+}
+
+func runScheduler(ctx context.Context) error {
+	io := iostreams.FromContext(ctx)
+
+	flapsClient, err := newFlapsClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	logf := func(format string, a ...any) {
+		fmt.Fprintf(io.Out, "%s %s\n", time.Now().UTC().Format(time.RFC3339), fmt.Sprintf(format, a...))
+	}
+
+	lastCheck := time.Now()
+	for {
+		wakeAt := time.Now().Add(schedulerRefreshInterval)
+
+		jobs, err := listJobMachines(ctx, flapsClient)
+		if err != nil {
+			logf("%v", err)
+		}
+
+		now := time.Now()
+		for _, jm := range jobs {
+			if !jm.Job.NeedsScheduler() {
+				continue
+			}
+			sched, err := jm.Job.ParseSchedule()
+			if err != nil {
+				logf("%v", err)
+				continue
+			}
+
+			if due := sched.Next(lastCheck); !due.After(now) {
+				startJob(ctx, flapsClient, logf, jm)
+			}
+			if next := sched.Next(now); next.Before(wakeAt) {
+				wakeAt = next
+			}
+		}
+		lastCheck = now
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(wakeAt)):
+		}
+	}
+}
+
+func startJob(ctx context.Context, flapsClient *flaps.Client, logf func(string, ...any), jm jobMachine) {
+	if jm.Machine.State == fly.MachineStateStarted {
+		logf("Job %s is still running on machine %s, skipping this run", jm.Job.Name, jm.Machine.ID)
+		return
+	}
+
+	if _, err := flapsClient.Start(ctx, jm.Machine.ID, ""); err != nil {
+		logf("Failed to start machine %s of job %s: %v", jm.Machine.ID, jm.Job.Name, err)
+		return
+	}
+	logf("Started machine %s of job %s", jm.Machine.ID, jm.Job.Name)
+}
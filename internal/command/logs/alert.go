@@ -0,0 +1,162 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/google/shlex"
+
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/logs"
+	"github.com/superfly/flyctl/terminal"
+)
+
+// alertNotifyTimeout bounds how long alert commands and webhooks may take.
+const alertNotifyTimeout = 30 * time.Second
+
+var alertFlags = flag.Set{
+	flag.String{
+		Name:        "alert",
+		Description: "Raise an alert when logs matching this filter expression cross --alert-threshold",
+	},
+	flag.String{
+		Name:        "alert-threshold",
+		Description: "Number of matching logs within a window that raises an alert, e.g. 10/1m",
+		Default:     "1/1m",
+	},
+	flag.Duration{
+		Name:        "alert-debounce",
+		Description: "Minimum time between two alerts",
+		Default:     5 * time.Minute,
+	},
+	flag.String{
+		Name:        "alert-exec",
+		Description: "Command to run when an alert is raised, the alert is passed as JSON on stdin",
+	},
+	flag.String{
+		Name:        "alert-webhook",
+		Description: "URL to POST alerts to as JSON",
+	},
+}
+
+// alertFunc wraps a stream to raise alerts while passing its entries through.
+type alertFunc func(ctx context.Context, stream <-chan logs.LogEntry) <-chan logs.LogEntry
+
+// newAlerting returns the alertFunc raising the alert configured with --alert.
+func newAlerting(ctx context.Context, appNames []string) (alertFunc, error) {
+	query := flag.GetString(ctx, "alert")
+
+	match, err := logs.NewFilter(logs.FilterOptions{
+		Query:          query,
+		ProcessGroupOf: newProcessGroups(appNames).resolver(ctx),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid --alert: %w", err)
+	}
+
+	threshold, window, err := logs.ParseThreshold(flag.GetString(ctx, "alert-threshold"))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		command = flag.GetString(ctx, "alert-exec")
+		webhook = flag.GetString(ctx, "alert-webhook")
+	)
+
+	var args []string
+	if command != "" {
+		if args, err = shlex.Split(command); err != nil || len(args) == 0 {
+			return nil, fmt.Errorf("invalid --alert-exec '%s'", command)
+		}
+	}
+
+	alerter := logs.NewAlerter(logs.AlertRule{
+		Query:     query,
+		Match:     match,
+		Threshold: threshold,
+		Window:    window,
+		Debounce:  flag.GetDuration(ctx, "alert-debounce"),
+		// Tailing starts with recent entries, which must not raise alerts
+		NotBefore: time.Now(),
+	})
+
+	notify := func(ctx context.Context, alert logs.Alert) {
+		terminal.Warnf("Alert: %d logs matched '%s' within %s\n", alert.Count, alert.Rule, alert.Window)
+
+		ctx, cancel := context.WithTimeout(ctx, alertNotifyTimeout)
+		defer cancel()
+
+		if len(args) > 0 {
+			if err := execAlert(ctx, args, alert); err != nil {
+				terminal.Warnf("Failed running --alert-exec: %v\n", err)
+			}
+		}
+		if webhook != "" {
+			if err := postAlert(ctx, webhook, alert); err != nil {
+				terminal.Warnf("Failed posting alert to --alert-webhook: %v\n", err)
+			}
+		}
+	}
+
+	return func(ctx context.Context, stream <-chan logs.LogEntry) <-chan logs.LogEntry {
+		return logs.WatchAlerts(ctx, stream, alerter, notify)
+	}, nil
+}
+
+// execAlert runs the alert command with the alert as JSON on stdin and its
+// main properties in the environment.
+func execAlert(ctx context.Context, args []string, alert logs.Alert) error {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	io := iostreams.FromContext(ctx)
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = io.ErrOut
+	cmd.Stderr = io.ErrOut
+	cmd.Env = append(os.Environ(),
+		"FLY_ALERT_RULE="+alert.Rule,
+		"FLY_ALERT_COUNT="+strconv.Itoa(alert.Count),
+		"FLY_ALERT_THRESHOLD="+strconv.Itoa(alert.Threshold),
+		"FLY_ALERT_WINDOW="+alert.Window.String(),
+	)
+
+	return cmd.Run()
+}
+
+func postAlert(ctx context.Context, url string, alert logs.Alert) error {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
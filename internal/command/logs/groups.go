@@ -0,0 +1,74 @@
+package logs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/logger"
+)
+
+// processGroups maps the instances logs come from to the process group of
+// their machine. Machines are listed lazily and listed again when logs show
+// up from an unknown instance, at most once per refreshInterval.
+type processGroups struct {
+	appNames        []string
+	refreshInterval time.Duration
+
+	mu          sync.Mutex
+	groups      map[string]string
+	lastRefresh time.Time
+}
+
+func newProcessGroups(appNames []string) *processGroups {
+	return &processGroups{
+		appNames:        appNames,
+		refreshInterval: 30 * time.Second,
+	}
+}
+
+func (pg *processGroups) resolver(ctx context.Context) func(instance string) string {
+	return func(instance string) string {
+		pg.mu.Lock()
+		defer pg.mu.Unlock()
+
+		if group, ok := pg.groups[instance]; ok {
+			return group
+		}
+		if time.Since(pg.lastRefresh) < pg.refreshInterval {
+			return ""
+		}
+
+		pg.lastRefresh = time.Now()
+		if err := pg.refresh(ctx); err != nil {
+			logger.FromContext(ctx).Debugf("failed listing machines: %v", err)
+		}
+		return pg.groups[instance]
+	}
+}
+
+func (pg *processGroups) refresh(ctx context.Context) error {
+	groups := map[string]string{}
+	for _, appName := range pg.appNames {
+		flapsClient, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{
+			AppName: appName,
+		})
+		if err != nil {
+			return err
+		}
+
+		machines, err := flapsClient.List(ctx, "")
+		if err != nil {
+			return err
+		}
+
+		for _, m := range machines {
+			groups[m.ID] = m.ProcessGroup()
+		}
+	}
+
+	pg.groups = groups
+	return nil
+}
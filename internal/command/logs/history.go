@@ -0,0 +1,104 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	fly "github.com/superfly/fly-go"
+
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/logs"
+	"github.com/superfly/flyctl/terminal"
+)
+
+// printHistory prints the recent logs of apps matching filter, oldest first.
+func printHistory(ctx context.Context, appNames []string, filter logs.Filter, renderOpts []render.LogOption) error {
+	var (
+		ios    = iostreams.FromContext(ctx)
+		client = fly.ClientFromContext(ctx)
+		limit  = flag.GetInt(ctx, "limit")
+	)
+
+	if limit < 0 {
+		return fmt.Errorf("--limit must be positive")
+	}
+
+	hopts := logs.HistoryOptions{Filter: filter}
+
+	var err error
+	if hopts.Since, err = flag.GetTime(ctx, "since"); err != nil {
+		return err
+	}
+	if hopts.Until, err = flag.GetTime(ctx, "until"); err != nil {
+		return err
+	}
+
+	var entries []logs.LogEntry
+	for _, appName := range appNames {
+		opts := &logs.LogOptions{
+			AppName:    appName,
+			RegionCode: config.FromContext(ctx).Region,
+			VMID:       flag.GetString(ctx, "instance"),
+		}
+
+		result, err := logs.History(ctx, client, opts, hopts)
+		if err != nil {
+			return fmt.Errorf("failed fetching logs of %s: %w", appName, err)
+		}
+
+		if !hopts.Since.IsZero() && result.Oldest.After(hopts.Since) {
+			terminal.Warnf("Logs of %s can only be fetched since %s\n", appName, result.Oldest.UTC().Format(time.RFC3339))
+		}
+		entries = append(entries, result.Entries...)
+	}
+
+	entries = lastEntries(entries, limit)
+
+	var w io.Writer = ios.Out
+	if output := flag.GetString(ctx, "output"); output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		w = f
+		renderOpts = append(renderOpts, render.NoColor())
+		defer fmt.Fprintf(ios.ErrOut, "Wrote %d log lines to %s\n", len(entries), output)
+	}
+
+	write, err := newLogWriter(ctx, w, renderOpts...)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := write(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lastEntries sorts entries from oldest to newest and returns the newest
+// limit of them, or all of them when limit is 0.
+func lastEntries(entries []logs.LogEntry, limit int) []logs.LogEntry {
+	slices.SortStableFunc(entries, func(a, b logs.LogEntry) int {
+		ta, _ := a.Time()
+		tb, _ := b.Time()
+		return ta.Compare(tb)
+	})
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries
+}
@@ -4,10 +4,13 @@ package logs
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"path"
+	"sync"
 	"time"
 
-	"github.com/azazeal/pause"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 
@@ -21,6 +24,7 @@ import (
 	"github.com/superfly/flyctl/internal/flag"
 	"github.com/superfly/flyctl/internal/logger"
 	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/terminal"
 )
 
 func New() (cmd *cobra.Command) {
@@ -32,20 +36,63 @@ Logs can be filtered to a specific instance using the --instance/-i flag or
 to all instances running in a specific region using the --region/-r flag.
 
 By default logs are continually streamed until the command is aborted.
-Use --no-tail to only fetch the logs in the buffer.
+Use --no-tail to print the most recent logs instead and exit, e.g.
+
+  fly logs --no-tail --level warn --output recent.log
+
+The logs API can't page backwards, so --no-tail only reaches as far back as
+the most recent page of logs it returns. --since, --until and --limit narrow
+down what that page holds, they can't fetch older logs, and a warning is shown
+when --since reaches further back than the oldest log fetched. To review
+incidents after the fact, keep the logs with 'fly logs ship'.
+
+--format selects the output format. ndjson, logfmt and csv flatten the fields
+of entries, templates are executed with the entry and the parsed fields of its
+message as .Fields. With --parse-json, messages that are JSON objects are shown
+as key=value pairs, limited to the fields given with --message-keys, and their
+fields are added as msg.<key> to the structured formats. csv only adds the
+fields given with --message-keys, as its columns have to be known upfront.
+
+--alert raises an alert when more logs than --alert-threshold match a filter
+expression within a sliding window, by running --alert-exec or posting to
+--alert-webhook, and at most once per --alert-debounce:
+
+  fly logs --alert 'level=error' --alert-threshold 10/1m --alert-exec ./page.sh
+
+The logs of several apps can be tailed at once by repeating --app, or with
+--org to tail all apps of an organization, optionally limited to the ones
+matching --app-pattern. Entries are then merged in timestamp order and
+prefixed with the name of their app.
+
+Logs can also be filtered client side by level, message, process group,
+HTTP response status and time. --query combines conditions on the fields
+level, message (or msg), region, instance, group, provider, method, url and
+status with and, or, not and parentheses, e.g.
+
+  fly logs --query 'level>=warn and (status>=500 or msg~"timeout")'
+
+Supported operators are = and != for all fields, : (contains), ~ and !~
+(regular expressions) for text fields, and <, <=, > and >= for level and
+status. Values with spaces or operators need to be quoted.
 `
 		short = "View app logs"
 	)
 
 	cmd = command.New("logs", short, long, run,
 		command.RequireSession,
-		command.RequireAppName,
+		command.LoadAppNameIfPresent,
 	)
 
 	cmd.Args = cobra.NoArgs
 
 	flag.Add(cmd,
-		flag.App(),
+		flag.Apps(),
+		flag.Org(),
+		flag.String{
+			Name:        "app-pattern",
+			Description: "Only tail the apps of --org whose name matches this glob pattern",
+			Default:     "*",
+		},
 		flag.AppConfig(),
 		flag.Region(),
 		flag.JSONOutput(),
@@ -59,43 +106,213 @@ Use --no-tail to only fetch the logs in the buffer.
 			Shorthand:   "n",
 			Description: "Do not continually stream logs",
 		},
+		flag.ProcessGroup("Only show logs of machines in this process group"),
+		flag.String{
+			Name:        "level",
+			Description: "Only show logs of this level or higher, e.g. warn",
+		},
+		flag.String{
+			Name:        "grep",
+			Description: "Only show logs whose message matches this regular expression",
+		},
+		flag.String{
+			Name:        "status",
+			Description: "Only show HTTP logs with this response status, e.g. 502, 5xx or >=400",
+		},
+		flag.String{
+			Name:        "since",
+			Description: "Only show logs after this time, either a timestamp or a duration like 15m. With --no-tail, older logs than the most recent page can't be fetched",
+		},
+		flag.String{
+			Name:        "until",
+			Description: "Only show logs before this time, either a timestamp or a duration like 15m",
+		},
+		flag.String{
+			Name:        "query",
+			Shorthand:   "q",
+			Description: "Only show logs matching this filter expression",
+		},
+		alertFlags,
+		flag.String{
+			Name:        "format",
+			Description: "Output format: text, json, ndjson, logfmt, csv or a Go template like '{{.Timestamp}} {{.Message}}'",
+		},
+		flag.Bool{
+			Name:        "parse-json",
+			Description: "Parse messages that are JSON objects and show their fields",
+		},
+		flag.StringSlice{
+			Name:        "message-keys",
+			Description: "Only show these fields of JSON messages, implies --parse-json",
+		},
+		flag.Int{
+			Name:        "limit",
+			Description: "With --no-tail, only show this many of the most recent logs fetched, which come from the most recent page of logs",
+		},
+		flag.String{
+			Name:        "output",
+			Description: "With --no-tail, write logs to this file instead of the terminal",
+		},
 	)
+
+	cmd.AddCommand(newShip(), newStats())
+
 	return
 }
 
+const (
+	// mergeWindow is how long entries of several apps are held back so they
+	// can be printed in timestamp order.
+	mergeWindow = time.Second
+	// reorderWindow is how long entries of a single app are held back to
+	// smooth out the ordering of the polling and NATS streams.
+	reorderWindow = 250 * time.Millisecond
+	// handoffTimeout bounds how long NATS entries are held back while waiting
+	// for polling to catch up with them.
+	handoffTimeout = 10 * time.Second
+)
+
 func run(ctx context.Context) error {
 	client := fly.ClientFromContext(ctx)
 
-	opts := &logs.LogOptions{
-		AppName:    appconfig.NameFromContext(ctx),
-		RegionCode: config.FromContext(ctx).Region,
-		VMID:       flag.GetString(ctx, "instance"),
-		NoTail:     flag.GetBool(ctx, "no-tail"),
+	appNames, err := appNamesToTail(ctx)
+	if err != nil {
+		return err
+	}
+
+	filter, err := newFilter(ctx, appNames)
+	if err != nil {
+		return err
+	}
+
+	var renderOpts []render.LogOption
+	if len(appNames) > 1 {
+		renderOpts = append(renderOpts, render.ShowAppNames(appNames))
+	}
+
+	if flag.GetBool(ctx, "no-tail") {
+		if flag.GetString(ctx, "alert") != "" {
+			return errors.New("--alert can't be used with --no-tail")
+		}
+		return printHistory(ctx, appNames, filter, renderOpts)
+	}
+	if flag.IsSpecified(ctx, "limit") || flag.IsSpecified(ctx, "output") {
+		return errors.New("--limit and --output require --no-tail")
+	}
+
+	write, err := newLogWriter(ctx, iostreams.FromContext(ctx).Out, renderOpts...)
+	if err != nil {
+		return err
+	}
+
+	var alert alertFunc
+	if flag.GetString(ctx, "alert") != "" {
+		if alert, err = newAlerting(ctx, appNames); err != nil {
+			return err
+		}
 	}
 
 	var eg *errgroup.Group
 	eg, ctx = errgroup.WithContext(ctx)
 
 	var streams []<-chan logs.LogEntry
-	if opts.NoTail {
-		streams = []<-chan logs.LogEntry{
-			poll(ctx, eg, client, opts),
+	for _, appName := range appNames {
+		opts := &logs.LogOptions{
+			AppName:    appName,
+			RegionCode: config.FromContext(ctx).Region,
+			VMID:       flag.GetString(ctx, "instance"),
 		}
+
+		streams = append(streams, tail(ctx, eg, client, opts))
+	}
+
+	if len(appNames) > 1 {
+		streams = []<-chan logs.LogEntry{logs.Merge(ctx, mergeWindow, streams...)}
 	} else {
-		pollingCtx, cancelPolling := context.WithCancel(ctx)
-		streams = []<-chan logs.LogEntry{
-			poll(pollingCtx, eg, client, opts),
-			nats(ctx, eg, client, opts, cancelPolling),
-		}
+		streams = []<-chan logs.LogEntry{logs.Merge(ctx, reorderWindow, streams...)}
+	}
+
+	// Alerts see every entry, regardless of the filters of the output
+	if alert != nil {
+		streams = []<-chan logs.LogEntry{alert(ctx, streams[0])}
 	}
 
 	eg.Go(func() error {
-		return printStreams(ctx, streams...)
+		return printStreams(ctx, filter, write, streams...)
 	})
 
 	return eg.Wait()
 }
 
+// appNamesToTail returns the apps given with --app, or the apps of the
+// organization given with --org matching --app-pattern.
+func appNamesToTail(ctx context.Context) ([]string, error) {
+	appNames := flag.GetApps(ctx)
+
+	org := flag.GetOrg(ctx)
+	switch {
+	case org != "" && len(appNames) > 0:
+		return nil, errors.New("--org can't be used with --app")
+	case org == "" && flag.IsSpecified(ctx, "app-pattern"):
+		return nil, errors.New("--app-pattern requires --org")
+	case org == "" && len(appNames) == 0:
+		if appName := appconfig.NameFromContext(ctx); appName != "" {
+			return []string{appName}, nil
+		}
+		return nil, command.ErrRequireAppName
+	case org == "":
+		return lo.Uniq(appNames), nil
+	}
+
+	client := fly.ClientFromContext(ctx)
+	organization, err := client.GetOrganizationBySlug(ctx, org)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetching organization %s: %w", org, err)
+	}
+
+	apps, err := client.GetAppsForOrganization(ctx, organization.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing apps of organization %s: %w", org, err)
+	}
+
+	pattern := flag.GetString(ctx, "app-pattern")
+	for _, app := range apps {
+		matched, err := path.Match(pattern, app.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --app-pattern: %w", err)
+		}
+		if matched {
+			appNames = append(appNames, app.Name)
+		}
+	}
+
+	if len(appNames) == 0 {
+		return nil, fmt.Errorf("no apps of organization %s match '%s'", org, pattern)
+	}
+	return appNames, nil
+}
+
+func newFilter(ctx context.Context, appNames []string) (logs.Filter, error) {
+	opts := logs.FilterOptions{
+		Level:          flag.GetString(ctx, "level"),
+		Grep:           flag.GetString(ctx, "grep"),
+		ProcessGroup:   flag.GetProcessGroup(ctx),
+		Status:         flag.GetString(ctx, "status"),
+		Query:          flag.GetString(ctx, "query"),
+		ProcessGroupOf: newProcessGroups(appNames).resolver(ctx),
+	}
+
+	var err error
+	if opts.Since, err = flag.GetTime(ctx, "since"); err != nil {
+		return nil, err
+	}
+	if opts.Until, err = flag.GetTime(ctx, "until"); err != nil {
+		return nil, err
+	}
+
+	return logs.NewFilter(opts)
+}
+
 func poll(ctx context.Context, eg *errgroup.Group, client *fly.Client, opts *logs.LogOptions) <-chan logs.LogEntry {
 	c := make(chan logs.LogEntry)
 
@@ -114,7 +331,20 @@ func poll(ctx context.Context, eg *errgroup.Group, client *fly.Client, opts *log
 	return c
 }
 
-func nats(ctx context.Context, eg *errgroup.Group, client *fly.Client, opts *logs.LogOptions, cancelPolling context.CancelFunc) <-chan logs.LogEntry {
+// tail polls the logs of an app until NATS caught up with polling. Every app
+// falls back from NATS to polling on its own.
+func tail(ctx context.Context, eg *errgroup.Group, client *fly.Client, opts *logs.LogOptions) <-chan logs.LogEntry {
+	pollingCtx, cancelPolling := context.WithCancel(ctx)
+
+	return logs.Handoff(ctx,
+		poll(pollingCtx, eg, client, opts),
+		nats(ctx, eg, client, opts),
+		cancelPolling,
+		handoffTimeout,
+	)
+}
+
+func nats(ctx context.Context, eg *errgroup.Group, client *fly.Client, opts *logs.LogOptions) <-chan logs.LogEntry {
 	c := make(chan logs.LogEntry)
 
 	eg.Go(func() error {
@@ -130,13 +360,21 @@ func nats(ctx context.Context, eg *errgroup.Group, client *fly.Client, opts *log
 			return nil
 		}
 
-		// we wait for 2 seconds before canceling the polling context so that
-		// we get a few records
-		pause.For(ctx, 2*time.Second)
-		cancelPolling()
-
 		for entry := range stream.Stream(ctx, opts) {
-			c <- entry
+			select {
+			case c <- entry:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		// Reconnecting is pointless, but polling might still work
+		if err := stream.Err(); logs.IsFatal(err) && ctx.Err() == nil {
+			terminal.Warnf("%v, falling back to polling\n", err)
+
+			if err := logs.Poll(ctx, c, client, opts); err != nil && !errors.Is(err, context.Canceled) {
+				return err
+			}
 		}
 
 		return nil
@@ -145,25 +383,55 @@ func nats(ctx context.Context, eg *errgroup.Group, client *fly.Client, opts *log
 	return c
 }
 
-func printStreams(ctx context.Context, streams ...<-chan logs.LogEntry) error {
+// newLogWriter returns a writer for the format selected with --format or
+// --json. It is safe to use from several goroutines.
+func newLogWriter(ctx context.Context, w io.Writer, renderOpts ...render.LogOption) (render.LogWriter, error) {
+	format := flag.GetString(ctx, "format")
+	if config.FromContext(ctx).JSONOutput {
+		if format != "" && format != "json" {
+			return nil, errors.New("--json can't be used with --format")
+		}
+		format = "json"
+	}
+
+	if keys := flag.GetStringSlice(ctx, "message-keys"); flag.GetBool(ctx, "parse-json") || len(keys) > 0 {
+		renderOpts = append(renderOpts, render.ParseJSONMessage(keys...))
+	}
+
+	write, err := render.NewLogWriter(w, format, append([]render.LogOption{
+		render.HideAllocID(),
+		render.RemoveNewlines(),
+		render.HideRegion(),
+	}, renderOpts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	return func(entry logs.LogEntry) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return write(entry)
+	}, nil
+}
+
+func printStreams(ctx context.Context, filter logs.Filter, write render.LogWriter, streams ...<-chan logs.LogEntry) error {
 	var eg *errgroup.Group
 	eg, ctx = errgroup.WithContext(ctx)
 
-	out := iostreams.FromContext(ctx).Out
-	json := config.FromContext(ctx).JSONOutput
-
 	for _, stream := range streams {
 		stream := stream
 
 		eg.Go(func() error {
-			return printStream(ctx, out, stream, json)
+			return printStream(ctx, stream, filter, write)
 		})
 	}
 
 	return eg.Wait()
 }
 
-func printStream(ctx context.Context, w io.Writer, stream <-chan logs.LogEntry, json bool) error {
+func printStream(ctx context.Context, stream <-chan logs.LogEntry, filter logs.Filter, write render.LogWriter) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -172,19 +440,10 @@ func printStream(ctx context.Context, w io.Writer, stream <-chan logs.LogEntry,
 			if !ok {
 				return nil
 			}
-
-			var err error
-			if json {
-				err = render.JSON(w, entry)
-			} else {
-				err = render.LogEntry(w, entry,
-					render.HideAllocID(),
-					render.RemoveNewlines(),
-					render.HideRegion(),
-				)
+			if filter != nil && !filter(entry) {
+				continue
 			}
-
-			if err != nil {
+			if err := write(entry); err != nil {
 				return err
 			}
 		}
@@ -0,0 +1,195 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/logs"
+)
+
+func entryAt(sec, msg string) logs.LogEntry {
+	return logs.LogEntry{
+		Instance:  "148e21f1",
+		Level:     "info",
+		Region:    "ord",
+		Timestamp: "2024-03-01T12:00:" + sec + "Z",
+		Message:   msg,
+	}
+}
+
+func feed(entries ...logs.LogEntry) <-chan logs.LogEntry {
+	c := make(chan logs.LogEntry, len(entries))
+	for _, entry := range entries {
+		c <- entry
+	}
+	close(c)
+	return c
+}
+
+func printedMessages(t *testing.T, out string) (messages []string) {
+	dec := json.NewDecoder(strings.NewReader(out))
+	for dec.More() {
+		var entry logs.LogEntry
+		require.NoError(t, dec.Decode(&entry))
+		messages = append(messages, entry.Message)
+	}
+	return
+}
+
+func TestPrintStreams_Handoff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var out bytes.Buffer
+	write, err := render.NewLogWriter(&out, "json")
+	require.NoError(t, err)
+
+	history := make(chan logs.LogEntry)
+	live := make(chan logs.LogEntry)
+	stream := logs.Merge(ctx, 10*time.Millisecond,
+		logs.Handoff(ctx, history, live, func() {}, time.Minute),
+	)
+
+	go func() {
+		history <- entryAt("01", "one")
+		live <- entryAt("03", "three")
+		history <- entryAt("02", "two")
+		history <- entryAt("03", "three")
+		history <- entryAt("04", "four")
+		close(history)
+		live <- entryAt("04", "four")
+		live <- entryAt("05", "five")
+		close(live)
+	}()
+
+	require.NoError(t, printStreams(ctx, nil, write, stream))
+	require.Equal(t, []string{"one", "two", "three", "four", "five"}, printedMessages(t, out.String()))
+}
+
+func TestPrintStreams(t *testing.T) {
+	warn := entryAt("02", "disk almost full")
+	warn.Level = "warn"
+
+	cases := []struct {
+		name     string
+		filter   logs.Filter
+		streams  []<-chan logs.LogEntry
+		expected []string
+	}{
+		{
+			name:     "single stream",
+			streams:  []<-chan logs.LogEntry{feed(entryAt("01", "one"), entryAt("02", "two"))},
+			expected: []string{"one", "two"},
+		},
+		{
+			name: "filtered",
+			filter: func(entry logs.LogEntry) bool {
+				return entry.Level == "warn"
+			},
+			streams:  []<-chan logs.LogEntry{feed(entryAt("01", "one"), warn, entryAt("03", "three"))},
+			expected: []string{"disk almost full"},
+		},
+		{
+			name:     "no entries",
+			streams:  []<-chan logs.LogEntry{feed(), feed()},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var out bytes.Buffer
+			write, err := render.NewLogWriter(&out, "json")
+			require.NoError(t, err)
+
+			require.NoError(t, printStreams(ctx, tc.filter, write, tc.streams...))
+			require.Equal(t, tc.expected, printedMessages(t, out.String()))
+		})
+	}
+}
+
+func TestPrintStreams_Text(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var out bytes.Buffer
+	write, err := render.NewLogWriter(&out, "text", render.HideAllocID())
+	require.NoError(t, err)
+
+	require.NoError(t, printStreams(ctx, nil, write, feed(entryAt("01", "hello"))))
+	require.Contains(t, out.String(), "148e21f1")
+	require.Contains(t, out.String(), "hello")
+	require.NotContains(t, out.String(), "{")
+}
+
+func TestLastEntries(t *testing.T) {
+	entries := func() []logs.LogEntry {
+		return []logs.LogEntry{entryAt("03", "three"), entryAt("01", "one"), entryAt("02", "two")}
+	}
+	messages := func(entries []logs.LogEntry) []string {
+		return lo.Map(entries, func(e logs.LogEntry, _ int) string { return e.Message })
+	}
+
+	require.Equal(t, []string{"one", "two", "three"}, messages(lastEntries(entries(), 0)))
+	require.Equal(t, []string{"two", "three"}, messages(lastEntries(entries(), 2)))
+	require.Equal(t, []string{"one", "two", "three"}, messages(lastEntries(entries(), 5)))
+}
+
+func TestEntriesSince(t *testing.T) {
+	entries := []logs.LogEntry{entryAt("01", "one"), entryAt("02", "two"), entryAt("03", "three")}
+	at := func(sec int) time.Time {
+		return time.Date(2024, 3, 1, 12, 0, sec, 0, time.UTC)
+	}
+
+	require.Len(t, entriesSince(entries, at(0)), 3)
+	require.Equal(t, "two", entriesSince(entries, at(2))[0].Message)
+	require.Empty(t, entriesSince(entries, at(4)))
+}
+
+func TestPrintStats_Partial(t *testing.T) {
+	ctx := config.NewContext(context.Background(), &config.Config{JSONOutput: true})
+	availableSince := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var out struct {
+		Requests       int        `json:"requests"`
+		Partial        bool       `json:"partial"`
+		AvailableSince *time.Time `json:"available_since"`
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, printStats(ctx, &buf, logs.NewHTTPStats(), availableSince))
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	require.True(t, out.Partial)
+	require.True(t, availableSince.Equal(*out.AvailableSince))
+
+	buf.Reset()
+	out.AvailableSince = nil
+	require.NoError(t, printStats(ctx, &buf, logs.NewHTTPStats(), time.Time{}))
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	require.False(t, out.Partial)
+	require.Nil(t, out.AvailableSince)
+}
+
+func TestGroupRows(t *testing.T) {
+	rows := groupRows(map[string]*logs.GroupStats{
+		"cdg": {Requests: 1},
+		"ord": {Requests: 8, ClientErrors: 1, ServerErrors: 2},
+	})
+
+	require.Equal(t, [][]string{
+		{"ord", "8", "1", "2", "25.0%"},
+		{"cdg", "1", "0", "0", "0.0%"},
+	}, rows)
+}
@@ -0,0 +1,231 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/cmdutil"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/state"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/logs"
+	"github.com/superfly/flyctl/logs/ship"
+	"github.com/superfly/flyctl/terminal"
+)
+
+func newShip() *cobra.Command {
+	const (
+		short = "Ship app logs to files, syslog or an OpenTelemetry collector"
+		long  = short + `
+
+Tails the logs of the app and writes them to one or more sinks until the
+command is aborted. --file writes newline delimited JSON to a file rotated by
+size and age, --syslog sends RFC 5424 messages to a syslog server over TCP or
+UDP and --otlp exports them to an OpenTelemetry collector over HTTP.
+
+Entries are delivered at least once: a batch is retried until every sink
+accepted it, and the timestamp of the newest shipped entry is saved to a
+checkpoint file. After a restart, the logs the platform still retains are
+back-filled from the checkpoint on, so entries logged while the command wasn't
+running are only lost once they fell out of the retention window.
+`
+		usage = "ship"
+	)
+
+	cmd := command.New(usage, short, long, runShip,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Region(),
+		flag.String{
+			Name:        "instance",
+			Shorthand:   "i",
+			Description: "Filter by instance ID",
+		},
+		flag.String{
+			Name:        "file",
+			Description: "Write logs to this file",
+		},
+		flag.Int{
+			Name:        "file-max-size-mb",
+			Description: "Rotate the file once it grows larger than this many megabytes, 0 to disable",
+			Default:     100,
+		},
+		flag.Duration{
+			Name:        "file-max-age",
+			Description: "Rotate the file once it is older than this, 0 to disable",
+			Default:     24 * time.Hour,
+		},
+		flag.Bool{
+			Name:        "file-compress",
+			Description: "Compress rotated files with gzip",
+		},
+		flag.Int{
+			Name:        "file-max-backups",
+			Description: "How many rotated files to keep, 0 to keep all of them",
+		},
+		flag.String{
+			Name:        "syslog",
+			Description: "Send logs to this syslog server, e.g. tcp://host:514 or udp://host:514",
+		},
+		flag.String{
+			Name:        "otlp",
+			Description: "Export logs to this OTLP/HTTP endpoint",
+		},
+		flag.StringArray{
+			Name:        "otlp-header",
+			Description: "Header to send to the OTLP endpoint in the form of NAME=VALUE. Can be specified multiple times.",
+		},
+		flag.String{
+			Name:        "checkpoint",
+			Description: "File keeping track of the shipped logs, defaults to one per app in the flyctl config directory",
+		},
+		flag.Int{
+			Name:        "batch-size",
+			Description: "Maximum number of entries written to the sinks at once",
+			Default:     100,
+		},
+		flag.Duration{
+			Name:        "flush-interval",
+			Description: "How often to write incomplete batches to the sinks",
+			Default:     time.Second,
+		},
+	)
+
+	return cmd
+}
+
+func runShip(ctx context.Context) error {
+	var (
+		io      = iostreams.FromContext(ctx)
+		client  = fly.ClientFromContext(ctx)
+		appName = appconfig.NameFromContext(ctx)
+	)
+
+	sinks, err := newSinks(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, sink := range sinks {
+			sink.Close()
+		}
+	}()
+
+	checkpointPath := flag.GetString(ctx, "checkpoint")
+	if checkpointPath == "" {
+		checkpointPath = filepath.Join(state.ConfigDirectory(ctx), "logs", appName+".checkpoint.json")
+	}
+	if err := os.MkdirAll(filepath.Dir(checkpointPath), 0o700); err != nil {
+		return err
+	}
+	checkpoint, err := ship.LoadCheckpoint(checkpointPath)
+	if err != nil {
+		return fmt.Errorf("failed loading checkpoint %s: %w", checkpointPath, err)
+	}
+
+	shipper := &ship.Shipper{
+		Sinks:         sinks,
+		Checkpoint:    checkpoint,
+		BatchSize:     flag.GetInt(ctx, "batch-size"),
+		FlushInterval: flag.GetDuration(ctx, "flush-interval"),
+		OnError: func(sink ship.Sink, err error) {
+			terminal.Warnf("Failed writing logs to %s, retrying: %v\n", sink.Name(), err)
+		},
+	}
+
+	opts := &logs.LogOptions{
+		AppName:    appName,
+		RegionCode: config.FromContext(ctx).Region,
+		VMID:       flag.GetString(ctx, "instance"),
+	}
+
+	shipper.Backfill = func(ctx context.Context, since time.Time) ([]logs.LogEntry, error) {
+		result, err := logs.History(ctx, client, opts, logs.HistoryOptions{Since: since})
+		if err != nil {
+			return nil, err
+		}
+		if !result.Oldest.IsZero() && result.Oldest.After(since) {
+			terminal.Warnf("Logs before %s are no longer retained, entries logged since %s may be missing\n",
+				result.Oldest.UTC().Format(time.RFC3339Nano), since.UTC().Format(time.RFC3339Nano))
+		}
+		return result.Entries, nil
+	}
+
+	var eg *errgroup.Group
+	eg, ctx = errgroup.WithContext(ctx)
+
+	entries := logs.DropMarkers(ctx, logs.Merge(ctx, reorderWindow, tail(ctx, eg, client, opts)))
+
+	if !checkpoint.Timestamp.IsZero() {
+		fmt.Fprintf(io.ErrOut, "Back-filling logs since %s\n", checkpoint.Timestamp.UTC().Format(time.RFC3339Nano))
+	}
+
+	eg.Go(func() error {
+		err := shipper.Run(ctx, entries)
+		if errors.Is(err, context.Canceled) {
+			err = nil
+		}
+		return err
+	})
+
+	return eg.Wait()
+}
+
+func newSinks(ctx context.Context) ([]ship.Sink, error) {
+	var sinks []ship.Sink
+
+	if addr := flag.GetString(ctx, "syslog"); addr != "" {
+		sink, err := ship.NewSyslogSink(addr)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if endpoint := flag.GetString(ctx, "otlp"); endpoint != "" {
+		headers, err := cmdutil.ParseKVStringsToMap(flag.GetStringArray(ctx, "otlp-header"))
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing --otlp-header: %w", err)
+		}
+		sinks = append(sinks, ship.NewOTLPSink(endpoint, headers))
+	}
+
+	// The file is opened right away, so it goes last to not leak it when
+	// other flags are invalid.
+	if path := flag.GetString(ctx, "file"); path != "" {
+		sink, err := ship.NewFileSink(ship.FileSinkOptions{
+			Path:       path,
+			MaxSize:    int64(flag.GetInt(ctx, "file-max-size-mb")) * 1024 * 1024,
+			MaxAge:     flag.GetDuration(ctx, "file-max-age"),
+			Compress:   flag.GetBool(ctx, "file-compress"),
+			MaxBackups: flag.GetInt(ctx, "file-max-backups"),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed opening %s: %w", path, err)
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if len(sinks) == 0 {
+		return nil, errors.New("at least one of --file, --syslog or --otlp is required")
+	}
+	return sinks, nil
+}
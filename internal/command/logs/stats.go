@@ -0,0 +1,312 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/morikuni/aec"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/logs"
+	"github.com/superfly/flyctl/terminal"
+)
+
+func newStats() *cobra.Command {
+	const (
+		short = "Show HTTP request statistics from app logs"
+		long  = short + `
+
+Aggregates the HTTP requests logged by the Fly proxy for the app within a time
+window: request counts, status code distribution, the most requested paths and
+error rates per region and instance. Error rates count 5xx responses.
+
+--since and --until take a timestamp (2024-03-01T12:00:00Z), a date
+(2024-03-01) or a duration relative to now (30m, 2h). Only the logs the platform
+still retains can be analyzed.
+
+With --watch, new logs are tailed and the statistics of the window ending now
+are redrawn every --interval.
+`
+		usage = "stats"
+	)
+
+	cmd := command.New(usage, short, long, runStats,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Region(),
+		flag.JSONOutput(),
+		flag.String{
+			Name:        "instance",
+			Shorthand:   "i",
+			Description: "Filter by instance ID",
+		},
+		flag.String{
+			Name:        "since",
+			Description: "Start of the window",
+			Default:     "15m",
+		},
+		flag.String{
+			Name:        "until",
+			Description: "End of the window, defaults to now",
+		},
+		flag.Int{
+			Name:        "top",
+			Description: "Number of paths to show",
+			Default:     10,
+		},
+		flag.Bool{
+			Name:        "watch",
+			Shorthand:   "w",
+			Description: "Keep tailing logs and redraw the statistics",
+		},
+		flag.Duration{
+			Name:        "interval",
+			Description: "How often to redraw the statistics with --watch",
+			Default:     2 * time.Second,
+		},
+	)
+
+	return cmd
+}
+
+func runStats(ctx context.Context) error {
+	var (
+		client  = fly.ClientFromContext(ctx)
+		appName = appconfig.NameFromContext(ctx)
+		opts    = &logs.LogOptions{
+			AppName:    appName,
+			RegionCode: config.FromContext(ctx).Region,
+			VMID:       flag.GetString(ctx, "instance"),
+		}
+	)
+
+	since, err := flag.GetTime(ctx, "since")
+	if err != nil {
+		return err
+	}
+	if since.IsZero() {
+		return errors.New("--since is required to bound the window")
+	}
+	until, err := flag.GetTime(ctx, "until")
+	if err != nil {
+		return err
+	}
+	watch := flag.GetBool(ctx, "watch")
+	if watch && !until.IsZero() {
+		return errors.New("--until can't be used with --watch")
+	}
+
+	result, err := logs.History(ctx, client, opts, logs.HistoryOptions{
+		Since: since,
+		Until: until,
+		Filter: func(entry logs.LogEntry) bool {
+			return entry.Meta.HTTP.Response.StatusCode != 0
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed fetching logs: %w", err)
+	}
+
+	// Logs before the most recent page can't be fetched
+	var availableSince time.Time
+	if result.Oldest.After(since) {
+		availableSince = result.Oldest
+		terminal.Warnf("Logs of %s can only be fetched since %s\n", appName, result.Oldest.UTC().Format(time.RFC3339))
+	}
+
+	if !watch {
+		stats := logs.NewHTTPStats()
+		for _, entry := range result.Entries {
+			stats.Add(entry)
+		}
+		return printStats(ctx, iostreams.FromContext(ctx).Out, stats, availableSince)
+	}
+
+	return watchStats(ctx, opts, time.Since(since), result.Entries, availableSince)
+}
+
+// watchStats tails logs and redraws the statistics of the last window of
+// entries every --interval. Windows starting before availableSince are
+// partial.
+func watchStats(ctx context.Context, opts *logs.LogOptions, window time.Duration, entries []logs.LogEntry, availableSince time.Time) error {
+	var (
+		io       = iostreams.FromContext(ctx)
+		client   = fly.ClientFromContext(ctx)
+		interval = flag.GetDuration(ctx, "interval")
+	)
+
+	var eg *errgroup.Group
+	eg, ctx = errgroup.WithContext(ctx)
+
+	stream := logs.DropMarkers(ctx, tail(ctx, eg, client, opts))
+
+	// The first page polled by tail overlaps with the history
+	seen := logs.NewDedupe()
+	for _, entry := range entries {
+		seen.Seen(entry)
+	}
+
+	eg.Go(func() error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case entry, ok := <-stream:
+				if !ok {
+					return nil
+				}
+				if entry.Meta.HTTP.Response.StatusCode != 0 && !seen.Seen(entry) {
+					entries = append(entries, entry)
+				}
+				continue
+			case <-ticker.C:
+			}
+
+			start := time.Now().Add(-window)
+			entries = entriesSince(entries, start)
+			if !availableSince.After(start) {
+				availableSince = time.Time{}
+			}
+
+			stats := logs.NewHTTPStats()
+			for _, entry := range entries {
+				stats.Add(entry)
+			}
+
+			var buf bytes.Buffer
+			if io.IsStdoutTTY() {
+				fmt.Fprint(&buf, aec.EraseDisplay(aec.EraseModes.All), aec.Position(0, 0))
+			}
+			fmt.Fprintf(&buf, "HTTP requests of the last %s, as of %s\n\n", window.Round(time.Second), time.Now().Format(time.TimeOnly))
+			if err := printStats(ctx, &buf, stats, availableSince); err != nil {
+				return err
+			}
+			if _, err := buf.WriteTo(io.Out); err != nil {
+				return err
+			}
+		}
+	})
+
+	return eg.Wait()
+}
+
+// entriesSince drops the entries older than since, assuming entries are
+// mostly sorted from oldest to newest.
+func entriesSince(entries []logs.LogEntry, since time.Time) []logs.LogEntry {
+	for i, entry := range entries {
+		if ts, err := entry.Time(); err != nil || !ts.Before(since) {
+			return entries[i:]
+		}
+	}
+	return nil
+}
+
+// statsOutput is the JSON output of the statistics of a window.
+type statsOutput struct {
+	*logs.HTTPStats
+	// Partial is set when the window starts before the oldest logs that
+	// could be fetched, AvailableSince.
+	Partial        bool       `json:"partial"`
+	AvailableSince *time.Time `json:"available_since,omitempty"`
+}
+
+// printStats prints stats, noting that they only cover the logs since
+// availableSince unless it's zero.
+func printStats(ctx context.Context, w io.Writer, stats *logs.HTTPStats, availableSince time.Time) error {
+	if config.FromContext(ctx).JSONOutput {
+		out := statsOutput{HTTPStats: stats}
+		if !availableSince.IsZero() {
+			out.Partial = true
+			out.AvailableSince = &availableSince
+		}
+		return render.JSON(w, out)
+	}
+
+	if !availableSince.IsZero() {
+		fmt.Fprintf(w, "Partial window, logs are only available since %s\n", availableSince.UTC().Format(time.RFC3339))
+	}
+
+	if stats.Requests == 0 {
+		_, err := fmt.Fprintln(w, "No HTTP requests found")
+		return err
+	}
+
+	total := stats.Total()
+	fmt.Fprintf(w, "%d requests between %s and %s, %s failed\n\n",
+		stats.Requests,
+		stats.First.UTC().Format(time.RFC3339),
+		stats.Last.UTC().Format(time.RFC3339),
+		percent(total.ServerErrors, total.Requests),
+	)
+
+	var rows [][]string
+	for _, c := range logs.TopCounts(stats.StatusCodes, 0) {
+		rows = append(rows, []string{strconv.Itoa(c.Key), strconv.Itoa(c.Count), percent(c.Count, stats.Requests)})
+	}
+	if err := render.Table(w, "Status codes", rows, "Status", "Requests", "Share"); err != nil {
+		return err
+	}
+
+	rows = nil
+	for _, c := range logs.TopCounts(stats.Paths, flag.GetInt(ctx, "top")) {
+		rows = append(rows, []string{c.Key, strconv.Itoa(c.Count), percent(c.Count, stats.Requests)})
+	}
+	if err := render.Table(w, "Top paths", rows, "Path", "Requests", "Share"); err != nil {
+		return err
+	}
+
+	if err := render.Table(w, "Regions", groupRows(stats.Regions), "Region", "Requests", "4xx", "5xx", "Error rate"); err != nil {
+		return err
+	}
+	return render.Table(w, "Instances", groupRows(stats.Instances), "Instance", "Requests", "4xx", "5xx", "Error rate")
+}
+
+func groupRows(groups map[string]*logs.GroupStats) [][]string {
+	requests := make(map[string]int, len(groups))
+	for key, g := range groups {
+		requests[key] = g.Requests
+	}
+
+	var rows [][]string
+	for _, c := range logs.TopCounts(requests, 0) {
+		g := groups[c.Key]
+		rows = append(rows, []string{
+			c.Key,
+			strconv.Itoa(g.Requests),
+			strconv.Itoa(g.ClientErrors),
+			strconv.Itoa(g.ServerErrors),
+			percent(g.ServerErrors, g.Requests),
+		})
+	}
+	return rows
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "0.0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
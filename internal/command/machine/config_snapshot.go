@@ -0,0 +1,279 @@
+package machine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+	mach "github.com/superfly/flyctl/internal/machine"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+// configSnapshot is the on-disk format written by snapshot-config and read
+// by restore-config.
+type configSnapshot struct {
+	AppName   string            `json:"app_name"`
+	CreatedAt time.Time         `json:"created_at"`
+	Machines  []machineSnapshot `json:"machines"`
+}
+
+type machineSnapshot struct {
+	ID       string              `json:"id"`
+	Name     string              `json:"name"`
+	Region   string              `json:"region"`
+	State    string              `json:"state"`
+	ImageRef fly.MachineImageRef `json:"image_ref"`
+	Config   *fly.MachineConfig  `json:"config"`
+}
+
+func newConfigSnapshot(appName string, createdAt time.Time, machines []*fly.Machine) configSnapshot {
+	snapshot := configSnapshot{
+		AppName:   appName,
+		CreatedAt: createdAt,
+	}
+	for _, m := range machines {
+		snapshot.Machines = append(snapshot.Machines, machineSnapshot{
+			ID:       m.ID,
+			Name:     m.Name,
+			Region:   m.Region,
+			State:    m.State,
+			ImageRef: m.ImageRef,
+			Config:   m.Config,
+		})
+	}
+	return snapshot
+}
+
+func newSnapshotConfig() *cobra.Command {
+	const (
+		short = "Save the config of all machines to a local file"
+		long  = short + `
+
+The snapshot contains the full config, image reference and metadata of every
+machine of the app. Use 'fly machine restore-config' to restore it.
+`
+		usage = "snapshot-config [file]"
+	)
+
+	cmd := command.New(usage, short, long, runSnapshotConfig,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+
+	cmd.Args = cobra.MaximumNArgs(1)
+
+	flag.Add(
+		cmd,
+		flag.App(),
+		flag.AppConfig(),
+	)
+
+	return cmd
+}
+
+func runSnapshotConfig(ctx context.Context) error {
+	var (
+		io      = iostreams.FromContext(ctx)
+		appName = appconfig.NameFromContext(ctx)
+		now     = time.Now().UTC()
+	)
+
+	path := flag.FirstArg(ctx)
+	if path == "" {
+		path = fmt.Sprintf("%s-machines-%s.json", appName, now.Format("20060102T150405Z"))
+	}
+
+	ctx, err := buildContextFromAppName(ctx, appName)
+	if err != nil {
+		return err
+	}
+
+	machines, err := listActiveAppMachines(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get a list of machines: %w", err)
+	}
+
+	snapshot := newConfigSnapshot(appName, now, machines)
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed encoding snapshot: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed writing snapshot: %w", err)
+	}
+
+	fmt.Fprintf(io.Out, "Saved the config of %d machine(s) to %s\n", len(snapshot.Machines), path)
+
+	return nil
+}
+
+func newRestoreConfig() *cobra.Command {
+	const (
+		short = "Restore machine configs from a snapshot file"
+		long  = short + `
+
+Compares every machine in a snapshot taken with 'fly machine snapshot-config'
+against its current state and updates the machines whose config differs.
+Machines that no longer exist are skipped.
+`
+		usage = "restore-config <file>"
+	)
+
+	cmd := command.New(usage, short, long, runRestoreConfig,
+		command.RequireSession,
+		command.LoadAppNameIfPresent,
+	)
+
+	cmd.Args = cobra.ExactArgs(1)
+
+	flag.Add(
+		cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Yes(),
+		flag.Bool{
+			Name:        "dry-run",
+			Description: "Only show the changes that would be applied",
+		},
+		flag.Bool{
+			Name:        "skip-health-checks",
+			Description: "Updates machines without waiting for health checks.",
+		},
+	)
+
+	return cmd
+}
+
+func runRestoreConfig(ctx context.Context) error {
+	var (
+		io       = iostreams.FromContext(ctx)
+		colorize = io.ColorScheme()
+
+		autoConfirm = flag.GetBool(ctx, "yes")
+		dryRun      = flag.GetBool(ctx, "dry-run")
+	)
+
+	data, err := os.ReadFile(flag.FirstArg(ctx))
+	if err != nil {
+		return fmt.Errorf("failed reading snapshot: %w", err)
+	}
+
+	var snapshot configSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed decoding snapshot: %w", err)
+	}
+
+	appName := appconfig.NameFromContext(ctx)
+	switch {
+	case appName == "":
+		appName = snapshot.AppName
+	case snapshot.AppName != "" && appName != snapshot.AppName:
+		return fmt.Errorf("snapshot was taken from app '%s', not '%s'", snapshot.AppName, appName)
+	}
+	if appName == "" {
+		return errors.New("snapshot doesn't name an app, specify one with --app")
+	}
+
+	ctx, err = buildContextFromAppName(ctx, appName)
+	if err != nil {
+		return err
+	}
+	ctx = appconfig.WithName(ctx, appName)
+
+	current, err := flaps.FromContext(ctx).List(ctx, "")
+	if err != nil {
+		return fmt.Errorf("could not get a list of machines: %w", err)
+	}
+	targets := selectRestoreTargets(ctx, snapshot, current)
+	if len(targets) == 0 {
+		fmt.Fprintln(io.Out, "All machines match the snapshot, no changes to apply")
+		return nil
+	}
+
+	if dryRun {
+		for _, t := range targets {
+			fmt.Fprintf(io.Out, "Configuration changes to be applied to machine: %s (%s)\n", colorize.Bold(t.machine.ID), colorize.Bold(t.machine.Name))
+			fmt.Fprintf(io.Out, "\n%s\n\n", mach.ConfigDiff(ctx, *t.machine.Config, *t.config))
+		}
+		return nil
+	}
+
+	var restored int
+	for _, t := range targets {
+		m, target := t.machine, t.config
+
+		if !autoConfirm {
+			confirmed, err := mach.ConfirmConfigChanges(ctx, m, *target, "")
+			var noChangesErr *mach.ErrNoConfigChangesFound
+			switch {
+			case errors.As(err, &noChangesErr):
+				continue
+			case err != nil:
+				return err
+			case !confirmed:
+				continue
+			}
+		}
+
+		input := &fly.LaunchMachineInput{
+			Name:             m.Name,
+			Region:           m.Region,
+			Config:           target,
+			SkipLaunch:       len(target.Standbys) > 0 || m.State == fly.MachineStateStopped,
+			SkipHealthChecks: flag.GetBool(ctx, "skip-health-checks"),
+		}
+		ok, err := updateWithLease(ctx, m, input)
+		if err != nil {
+			return rewriteUpdateErr(err)
+		}
+		if ok {
+			restored++
+		}
+	}
+
+	fmt.Fprintf(io.Out, "\n%s\n", colorize.Green(fmt.Sprintf("Restored the config of %d machine(s)", restored)))
+
+	return nil
+}
+
+type restoreTarget struct {
+	machine *fly.Machine
+	config  *fly.MachineConfig
+}
+
+// selectRestoreTargets pairs the current machines with their config in the
+// snapshot, leaving out machines that no longer exist or already match it.
+func selectRestoreTargets(ctx context.Context, snapshot configSnapshot, current []*fly.Machine) []restoreTarget {
+	io := iostreams.FromContext(ctx)
+	currentByID := lo.KeyBy(current, func(m *fly.Machine) string { return m.ID })
+
+	var targets []restoreTarget
+	for _, snap := range snapshot.Machines {
+		m, ok := currentByID[snap.ID]
+		if !ok || m.Config == nil || m.State == fly.MachineStateDestroyed || m.State == fly.MachineStateDestroying {
+			fmt.Fprintf(io.ErrOut, "Machine %s no longer exists, skipping\n", snap.ID)
+			continue
+		}
+		if snap.Config == nil {
+			fmt.Fprintf(io.ErrOut, "Snapshot of machine %s has no config, skipping\n", snap.ID)
+			continue
+		}
+		if mach.ConfigDiff(ctx, *m.Config, *snap.Config) == "" {
+			continue
+		}
+		targets = append(targets, restoreTarget{machine: m, config: snap.Config})
+	}
+	return targets
+}
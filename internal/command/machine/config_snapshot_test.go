@@ -0,0 +1,87 @@
+package machine
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func TestConfigSnapshotRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	machines := []*fly.Machine{
+		{
+			ID:       "m1",
+			Name:     "web",
+			Region:   "ord",
+			State:    fly.MachineStateStarted,
+			ImageRef: fly.MachineImageRef{Registry: "registry.fly.io", Repository: "my-app", Tag: "v1"},
+			Config: &fly.MachineConfig{
+				Image:    "registry.fly.io/my-app:v1",
+				Env:      map[string]string{"FOO": "bar"},
+				Metadata: map[string]string{"fly_process_group": "app"},
+				Guest:    &fly.MachineGuest{CPUKind: "shared", CPUs: 1, MemoryMB: 256},
+			},
+		},
+		{
+			ID:     "m2",
+			Name:   "worker",
+			Region: "ams",
+			State:  fly.MachineStateStopped,
+			Config: &fly.MachineConfig{Image: "registry.fly.io/my-app:v1"},
+		},
+	}
+
+	snapshot := newConfigSnapshot("my-app", createdAt, machines)
+
+	data, err := json.Marshal(snapshot)
+	require.NoError(t, err)
+
+	var decoded configSnapshot
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, snapshot, decoded)
+	require.Equal(t, "my-app", decoded.AppName)
+	require.Len(t, decoded.Machines, 2)
+	require.Equal(t, machines[0].Config, decoded.Machines[0].Config)
+	require.Equal(t, fly.MachineStateStopped, decoded.Machines[1].State)
+}
+
+func TestSelectRestoreTargets(t *testing.T) {
+	io, _, _, errOut := iostreams.Test()
+	ctx := iostreams.NewContext(context.Background(), io)
+
+	config := func(image string) *fly.MachineConfig {
+		return &fly.MachineConfig{Image: image, Env: map[string]string{"FOO": "bar"}}
+	}
+
+	snapshot := configSnapshot{
+		AppName: "my-app",
+		Machines: []machineSnapshot{
+			{ID: "unchanged", Config: config("app:v1")},
+			{ID: "changed", Config: config("app:v1")},
+			{ID: "gone", Config: config("app:v1")},
+			{ID: "destroyed", Config: config("app:v1")},
+			{ID: "noconfig"},
+		},
+	}
+	current := []*fly.Machine{
+		{ID: "unchanged", State: fly.MachineStateStarted, Config: config("app:v1")},
+		{ID: "changed", State: fly.MachineStateStarted, Config: config("app:v2")},
+		{ID: "destroyed", State: fly.MachineStateDestroyed, Config: config("app:v2")},
+		{ID: "noconfig", State: fly.MachineStateStarted, Config: config("app:v2")},
+		{ID: "extra", State: fly.MachineStateStarted, Config: config("app:v2")},
+	}
+
+	targets := selectRestoreTargets(ctx, snapshot, current)
+	require.Len(t, targets, 1)
+	require.Equal(t, "changed", targets[0].machine.ID)
+	require.Equal(t, "app:v1", targets[0].config.Image)
+
+	require.Equal(t, "Machine gone no longer exists, skipping\n"+
+		"Machine destroyed no longer exists, skipping\n"+
+		"Snapshot of machine noconfig has no config, skipping\n", errOut.String())
+}
@@ -0,0 +1,237 @@
+package machine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	mach "github.com/superfly/flyctl/internal/machine"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+func newEvents() *cobra.Command {
+	const (
+		short = "Show the events of machines as a single timeline"
+		long  = short + `
+
+Merges the events of all machines of the app, or of the given machines, into
+one chronological list. Use --follow to keep polling for new events.
+
+--since and --until take a timestamp (2024-03-01T12:00:00Z), a date
+(2024-03-01) or a duration relative to now (30m, 2h).
+`
+		usage = "events [machine_id...]"
+	)
+
+	cmd := command.New(usage, short, long, runEvents,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+
+	cmd.Args = cobra.ArbitraryArgs
+
+	flag.Add(
+		cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.JSONOutput(),
+		flag.ProcessGroup("Only show events of machines in this process group"),
+		flag.StringSlice{
+			Name:        "type",
+			Description: "Only show events of these types, e.g. start,exit",
+		},
+		flag.StringSlice{
+			Name:        "source",
+			Description: "Only show events from these sources, e.g. user,flyd",
+		},
+		flag.Int{
+			Name:        "exit-code",
+			Description: "Only show exit events with this exit code",
+		},
+		flag.String{
+			Name:        "since",
+			Description: "Only show events after this time",
+		},
+		flag.String{
+			Name:        "until",
+			Description: "Only show events before this time",
+		},
+		flag.Bool{
+			Name:        "follow",
+			Shorthand:   "f",
+			Description: "Keep polling for new events",
+		},
+		flag.Duration{
+			Name:        "interval",
+			Description: "How often to poll for new events with --follow",
+			Default:     5 * time.Second,
+		},
+	)
+
+	return cmd
+}
+
+func runEvents(ctx context.Context) error {
+	var (
+		io       = iostreams.FromContext(ctx)
+		appName  = appconfig.NameFromContext(ctx)
+		jsonOut  = config.FromContext(ctx).JSONOutput
+		follow   = flag.GetBool(ctx, "follow")
+		interval = flag.GetDuration(ctx, "interval")
+	)
+
+	filter := mach.EventFilter{
+		Types:   flag.GetStringSlice(ctx, "type"),
+		Sources: flag.GetStringSlice(ctx, "source"),
+	}
+	if flag.IsSpecified(ctx, "exit-code") {
+		filter.ExitCode = lo.ToPtr(flag.GetInt(ctx, "exit-code"))
+	}
+
+	var err error
+	if filter.Since, err = flag.GetTime(ctx, "since"); err != nil {
+		return err
+	}
+	if filter.Until, err = flag.GetTime(ctx, "until"); err != nil {
+		return err
+	}
+	if follow && !filter.Until.IsZero() {
+		return fmt.Errorf("--until can't be used with --follow")
+	}
+
+	flapsClient, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{
+		AppName: appName,
+	})
+	if err != nil {
+		return err
+	}
+
+	timeline, err := listTimeline(ctx, flapsClient, filter)
+	if err != nil {
+		return err
+	}
+
+	if !follow {
+		if jsonOut {
+			return render.JSON(io.Out, timeline)
+		}
+		if len(timeline) == 0 {
+			fmt.Fprintf(io.Out, "No events found\n")
+			return nil
+		}
+		return render.Table(io.Out, "", eventRows(timeline), "Time", "Machine", "Region", "Type", "Status", "Source", "Info")
+	}
+
+	// With --follow, events are printed one per line as they show up.
+	// Events older than the last printed one are skipped, and only the keys
+	// of events sharing its timestamp are remembered to avoid printing them
+	// twice, so that memory use doesn't grow while following.
+	var (
+		last time.Time
+		seen = map[string]bool{}
+	)
+	for {
+		for _, e := range timeline {
+			if e.Time.Before(last) || seen[e.Key()] {
+				continue
+			}
+			if e.Time.After(last) {
+				last = e.Time
+				clear(seen)
+			}
+			seen[e.Key()] = true
+
+			if err := printEvent(io.Out, e, jsonOut); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+
+		if timeline, err = listTimeline(ctx, flapsClient, filter); err != nil {
+			return err
+		}
+	}
+}
+
+func listTimeline(ctx context.Context, flapsClient *flaps.Client, filter mach.EventFilter) ([]mach.TimelineEvent, error) {
+	var (
+		machineIDs   = flag.Args(ctx)
+		processGroup = flag.GetProcessGroup(ctx)
+	)
+
+	machines, err := flapsClient.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("could not get a list of machines: %w", err)
+	}
+
+	machines = lo.Filter(machines, func(m *fly.Machine, _ int) bool {
+		switch {
+		case len(machineIDs) > 0 && !slices.Contains(machineIDs, m.ID):
+			return false
+		case processGroup != "" && m.ProcessGroup() != processGroup:
+			return false
+		}
+		return true
+	})
+
+	return mach.Timeline(machines, filter), nil
+}
+
+func eventRows(timeline []mach.TimelineEvent) [][]string {
+	return lo.Map(timeline, func(e mach.TimelineEvent, _ int) []string {
+		return []string{
+			e.Time.UTC().Format(time.RFC3339Nano),
+			e.MachineID,
+			e.Region,
+			e.Type,
+			e.Status,
+			e.Source,
+			eventInfo(e),
+		}
+	})
+}
+
+func eventInfo(e mach.TimelineEvent) string {
+	if e.ExitCode == nil {
+		return ""
+	}
+
+	info := []string{
+		fmt.Sprintf("exit_code=%d", *e.ExitCode),
+		fmt.Sprintf("oom_killed=%t", e.OOMKilled),
+		fmt.Sprintf("requested_stop=%t", e.RequestedStop),
+	}
+	if e.Restarting {
+		info = append(info, fmt.Sprintf("restart_count=%d", e.RestartCount))
+	}
+	return strings.Join(info, ",")
+}
+
+func printEvent(w io.Writer, e mach.TimelineEvent, jsonOut bool) error {
+	if jsonOut {
+		return json.NewEncoder(w).Encode(e)
+	}
+
+	_, err := fmt.Fprintf(w, "%s %s %s %s %s %s %s\n",
+		e.Time.UTC().Format(time.RFC3339Nano), e.MachineID, e.Region, e.Type, e.Status, e.Source, eventInfo(e))
+	return err
+}
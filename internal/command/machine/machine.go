@@ -37,6 +37,10 @@ Machines REST fly.`
 		newMachineExec(),
 		newMachineCordon(),
 		newMachineUncordon(),
+		newSnapshotConfig(),
+		newRestoreConfig(),
+		newTask(),
+		newEvents(),
 	)
 
 	return cmd
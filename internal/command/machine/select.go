@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/superfly/flyctl/internal/appconfig"
 	"github.com/superfly/flyctl/internal/flag"
 	"github.com/superfly/flyctl/internal/flapsutil"
+	mach "github.com/superfly/flyctl/internal/machine"
 	"github.com/superfly/flyctl/internal/prompt"
 	"github.com/superfly/flyctl/iostreams"
 )
@@ -125,6 +127,18 @@ func buildContextFromAppName(ctx context.Context, appName string) (context.Conte
 	return ctx, nil
 }
 
+// listActiveAppMachines returns the active machines of the app, leaving out
+// the machines of [[jobs]], which are managed by deploys and the scheduler.
+func listActiveAppMachines(ctx context.Context) ([]*fly.Machine, error) {
+	machines, err := mach.ListActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(machines, func(m *fly.Machine) bool {
+		return m.Config.Metadata[appconfig.JobMetadataKey] != ""
+	}), nil
+}
+
 func buildContextFromAppNameOrMachineID(ctx context.Context, machineIDs ...string) (context.Context, error) {
 	var (
 		appName = appconfig.NameFromContext(ctx)
@@ -0,0 +1,315 @@
+package machine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/azazeal/pause"
+	"github.com/spf13/cobra"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/cmdutil"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flapsutil"
+	"github.com/superfly/flyctl/internal/flyerr"
+	"github.com/superfly/flyctl/internal/logger"
+	mach "github.com/superfly/flyctl/internal/machine"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/logs"
+)
+
+// taskLogsGracePeriod is how long logs keep streaming after the task exited,
+// as log shipping lags behind the machine.
+const taskLogsGracePeriod = 3 * time.Second
+
+func newTask() *cobra.Command {
+	const (
+		short = "Run a one-off task on an ephemeral machine"
+		long  = short + `
+
+The machine is created from the image and config of the app's current release,
+using the process group given with --process-group or the app's default one.
+Its logs are streamed until it exits and its exit code becomes the exit code
+of flyctl. The machine is destroyed once the task finishes, and also when flyctl
+is interrupted.
+
+Arguments replace the command of the process group, e.g.
+
+  fly machine task -- bin/rails db:migrate
+`
+		usage = "task [command...]"
+	)
+
+	cmd := command.New(usage, short, long, runTask,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+
+	cmd.Args = cobra.ArbitraryArgs
+
+	flag.Add(
+		cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Region(),
+		flag.ProcessGroup("The process group whose config is used to run the task"),
+		flag.StringArray{
+			Name:        "env",
+			Shorthand:   "e",
+			Description: "Set of environment variables in the form of NAME=VALUE pairs. Can be specified multiple times.",
+		},
+		flag.Duration{
+			Name:        "timeout",
+			Description: "Destroy the machine when the task runs for longer than this",
+		},
+		flag.VMSizeFlags,
+	)
+
+	return cmd
+}
+
+func runTask(ctx context.Context) error {
+	var (
+		appName   = appconfig.NameFromContext(ctx)
+		apiClient = fly.ClientFromContext(ctx)
+	)
+
+	app, err := apiClient.GetAppCompact(ctx, appName)
+	if err != nil {
+		return fmt.Errorf("failed to get app: %w", err)
+	}
+
+	flapsClient, err := flapsutil.NewClientWithOptions(ctx, flaps.NewClientOpts{
+		AppCompact: app,
+		AppName:    app.Name,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create flaps client: %w", err)
+	}
+	ctx = flaps.NewContext(ctx, flapsClient)
+
+	appConfig := appconfig.ConfigFromContext(ctx)
+	if appConfig == nil {
+		appConfig, err = appconfig.FromRemoteApp(ctx, appName)
+		if err != nil {
+			return fmt.Errorf("failed to fetch app config from backend: %w", err)
+		}
+	}
+
+	currentRelease, err := apiClient.GetAppCurrentReleaseMachines(ctx, appName)
+	if err != nil {
+		return err
+	}
+	if currentRelease == nil {
+		return errors.New("can't run a task since the app has not yet been released")
+	}
+
+	mConfig, err := taskMachineConfig(ctx, appConfig, currentRelease.ImageRef)
+	if err != nil {
+		return err
+	}
+
+	region := config.FromContext(ctx).Region
+	if region == "" {
+		region = appConfig.PrimaryRegion
+	}
+
+	machine, cleanup, err := mach.LaunchEphemeral(ctx, &mach.EphemeralInput{
+		LaunchInput: fly.LaunchMachineInput{
+			Config: mConfig,
+			Region: region,
+		},
+		What: "to run the task",
+	})
+	var exitErr mach.EphemeralExitErr
+	switch {
+	case errors.As(err, &exitErr):
+		// The task finished before the machine was seen running
+		return taskResult(ctx, exitErr.ExitCode)
+	case err != nil:
+		return fmt.Errorf("failed to launch task machine: %w", err)
+	}
+
+	// The machine destroys itself when the task exits, it only has to be
+	// cleaned up when we stop waiting for it early.
+	var finished bool
+	defer func() {
+		if !finished {
+			cleanup()
+		}
+	}()
+
+	waitCtx := ctx
+	if timeout := flag.GetDuration(ctx, "timeout"); timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	logsCtx, cancelLogs := context.WithCancel(ctx)
+	logsDone := make(chan struct{})
+	go func() {
+		defer close(logsDone)
+		streamTaskLogs(logsCtx, apiClient, appName, machine.ID)
+	}()
+
+	exitCode, err := waitForTaskExit(waitCtx, machine)
+	if err == nil {
+		pause.For(ctx, taskLogsGracePeriod)
+	}
+	cancelLogs()
+	<-logsDone
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("task didn't finish within %s", flag.GetDuration(ctx, "timeout"))
+	case err != nil:
+		return err
+	}
+	finished = true
+
+	return taskResult(ctx, exitCode)
+}
+
+func taskResult(ctx context.Context, exitCode int) error {
+	if exitCode != 0 {
+		return flyerr.ExitCodeErr{
+			Err:  fmt.Errorf("task exited with code %d", exitCode),
+			Code: exitCode,
+		}
+	}
+
+	fmt.Fprintf(iostreams.FromContext(ctx).ErrOut, "Task finished successfully\n")
+
+	return nil
+}
+
+// taskMachineConfig returns the config of a process group with everything
+// that doesn't make sense for a one-off machine stripped out.
+func taskMachineConfig(ctx context.Context, appConfig *appconfig.Config, image string) (*fly.MachineConfig, error) {
+	processGroup := flag.GetProcessGroup(ctx)
+	if processGroup != "" && !slices.Contains(appConfig.ProcessNames(), processGroup) {
+		return nil, fmt.Errorf("process group '%s' doesn't exist", processGroup)
+	}
+
+	mConfig, err := appConfig.ToMachineConfig(processGroup, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate task machine configuration: %w", err)
+	}
+
+	mConfig.Image = image
+	mConfig.AutoDestroy = true
+	mConfig.Restart = fly.MachineRestart{Policy: fly.MachineRestartPolicyNo}
+	mConfig.Services = nil
+	mConfig.Checks = nil
+	mConfig.Mounts = nil
+	mConfig.Standbys = nil
+	// Keep the task out of deployments of its process group
+	delete(mConfig.Metadata, fly.MachineConfigMetadataKeyFlyPlatformVersion)
+
+	if args := flag.Args(ctx); len(args) > 0 {
+		mConfig.Init.Cmd = args
+	}
+
+	if env := flag.GetStringArray(ctx, "env"); len(env) > 0 {
+		parsedEnv, err := cmdutil.ParseKVStringsToMap(env)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing environment: %w", err)
+		}
+		maps.Copy(mConfig.Env, parsedEnv)
+	}
+
+	mConfig.Guest, err = flag.GetMachineGuest(ctx, mConfig.Guest)
+	if err != nil {
+		return nil, err
+	}
+	if hdid := appConfig.HostDedicationID; hdid != "" {
+		mConfig.Guest.HostDedicationID = hdid
+	}
+
+	return mConfig, nil
+}
+
+// waitForTaskExit waits for the task machine to stop and returns the exit code
+// of its main process.
+func waitForTaskExit(ctx context.Context, machine *fly.Machine) (int, error) {
+	flapsClient := flaps.FromContext(ctx)
+
+	for {
+		// Wait for the stopped rather than the destroyed state: the exit
+		// event has to be read before auto-destroy removes the machine.
+		waitErr := flapsClient.Wait(ctx, machine, fly.MachineStateStopped, 60*time.Second)
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+
+		// The wait fails with a timeout while the task is running and might
+		// fail when the machine is already gone, so check for ourselves.
+		current, err := flapsClient.Get(ctx, machine.ID)
+		if err != nil {
+			if waitErr != nil {
+				return 0, fmt.Errorf("failed waiting for task machine: %w", waitErr)
+			}
+			return 0, fmt.Errorf("failed to get task machine: %w", err)
+		}
+
+		switch current.State {
+		case fly.MachineStateDestroyed, fly.MachineStateDestroying, fly.MachineStateStopped:
+			return taskExitCode(current)
+		}
+	}
+}
+
+func taskExitCode(machine *fly.Machine) (int, error) {
+	// Events are sorted from newest to oldest
+	for _, event := range machine.Events {
+		if event.Type != "exit" || event.Request == nil {
+			continue
+		}
+		exitCode, err := event.Request.GetExitCode()
+		if err != nil {
+			break
+		}
+		return exitCode, nil
+	}
+	return 0, fmt.Errorf("machine %s stopped without reporting an exit code", machine.ID)
+}
+
+func streamTaskLogs(ctx context.Context, apiClient *fly.Client, appName, machineID string) {
+	var (
+		out     = iostreams.FromContext(ctx).Out
+		opts    = &logs.LogOptions{AppName: appName, VMID: machineID}
+		entries <-chan logs.LogEntry
+	)
+
+	if stream, err := logs.NewNatsStream(ctx, apiClient, opts); err == nil {
+		entries = stream.Stream(ctx, opts)
+	} else {
+		logger := logger.FromContext(ctx)
+		logger.Debugf("could not connect to wireguard tunnel: %v\n", err)
+		logger.Debug("falling back to log polling...")
+
+		c := make(chan logs.LogEntry)
+		go func() {
+			defer close(c)
+			_ = logs.Poll(ctx, c, apiClient, opts)
+		}()
+		entries = c
+	}
+
+	for entry := range entries {
+		_ = render.LogEntry(out, entry,
+			render.HideAllocID(),
+			render.RemoveNewlines(),
+			render.HideRegion(),
+		)
+	}
+}
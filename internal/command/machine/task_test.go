@@ -0,0 +1,126 @@
+package machine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/appconfig"
+	"github.com/superfly/flyctl/internal/flag"
+)
+
+func TestTaskExitCode(t *testing.T) {
+	exit := func(code int) *fly.MachineEvent {
+		return &fly.MachineEvent{
+			Type:    "exit",
+			Request: &fly.MachineRequest{ExitEvent: &fly.MachineExitEvent{ExitCode: code}},
+		}
+	}
+
+	testcases := []struct {
+		name      string
+		events    []*fly.MachineEvent
+		expected  int
+		expectErr bool
+	}{
+		{
+			name:     "success",
+			events:   []*fly.MachineEvent{{Type: "destroy"}, exit(0), {Type: "start"}},
+			expected: 0,
+		},
+		{
+			name:     "failure",
+			events:   []*fly.MachineEvent{exit(3), {Type: "start"}},
+			expected: 3,
+		},
+		{
+			name:     "newest exit wins",
+			events:   []*fly.MachineEvent{exit(1), {Type: "start"}, exit(0)},
+			expected: 1,
+		},
+		{
+			name: "monitor event",
+			events: []*fly.MachineEvent{{
+				Type: "exit",
+				Request: &fly.MachineRequest{
+					MonitorEvent: &fly.MachineMonitorEvent{ExitEvent: &fly.MachineExitEvent{ExitCode: 137}},
+				},
+			}},
+			expected: 137,
+		},
+		{
+			name:      "no exit event",
+			events:    []*fly.MachineEvent{{Type: "start"}, {Type: "launch"}},
+			expectErr: true,
+		},
+		{
+			name:      "exit event without code",
+			events:    []*fly.MachineEvent{{Type: "exit", Request: &fly.MachineRequest{}}},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, err := taskExitCode(&fly.Machine{ID: "m1", Events: tc.events})
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, code)
+		})
+	}
+}
+
+func TestTaskMachineConfig(t *testing.T) {
+	appConfig := appconfig.NewConfig()
+	appConfig.AppName = "my-app"
+	appConfig.Env = map[string]string{"FOO": "bar"}
+	appConfig.Processes = map[string]string{
+		"app":    "bin/server",
+		"worker": "bin/worker",
+	}
+	appConfig.HTTPService = &appconfig.HTTPService{InternalPort: 8080, Processes: []string{"app"}}
+	appConfig.Mounts = []appconfig.Mount{{Source: "data", Destination: "/data"}}
+
+	newContext := func(t *testing.T, args ...string) context.Context {
+		cmd := newTask()
+		require.NoError(t, cmd.ParseFlags(args))
+		return flag.NewContext(context.Background(), cmd.Flags())
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		mConfig, err := taskMachineConfig(newContext(t), appConfig, "registry.fly.io/my-app:v1")
+		require.NoError(t, err)
+
+		require.Equal(t, "registry.fly.io/my-app:v1", mConfig.Image)
+		require.True(t, mConfig.AutoDestroy)
+		require.Equal(t, fly.MachineRestartPolicyNo, mConfig.Restart.Policy)
+		require.Empty(t, mConfig.Services)
+		require.Empty(t, mConfig.Checks)
+		require.Empty(t, mConfig.Mounts)
+		require.Empty(t, mConfig.Standbys)
+		require.NotContains(t, mConfig.Metadata, fly.MachineConfigMetadataKeyFlyPlatformVersion)
+		require.Equal(t, "bar", mConfig.Env["FOO"])
+		require.NotNil(t, mConfig.Guest)
+	})
+
+	t.Run("process group, command and env", func(t *testing.T) {
+		ctx := newContext(t, "--process-group", "worker", "--env", "FOO=baz", "--env", "EXTRA=1", "--vm-memory", "1024", "--", "bin/rake", "db:migrate")
+		mConfig, err := taskMachineConfig(ctx, appConfig, "registry.fly.io/my-app:v1")
+		require.NoError(t, err)
+
+		require.Equal(t, "worker", mConfig.ProcessGroup())
+		require.Equal(t, []string{"bin/rake", "db:migrate"}, mConfig.Init.Cmd)
+		require.Equal(t, "baz", mConfig.Env["FOO"])
+		require.Equal(t, "1", mConfig.Env["EXTRA"])
+		require.Equal(t, 1024, mConfig.Guest.MemoryMB)
+	})
+
+	t.Run("unknown process group", func(t *testing.T) {
+		_, err := taskMachineConfig(newContext(t, "--process-group", "nope"), appConfig, "registry.fly.io/my-app:v1")
+		require.ErrorContains(t, err, "process group 'nope' doesn't exist")
+	})
+}
@@ -3,8 +3,11 @@ package machine
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 
 	fly "github.com/superfly/fly-go"
@@ -21,7 +24,18 @@ import (
 func newUpdate() *cobra.Command {
 	const (
 		short = "Update a machine"
-		long  = short + "\n"
+		long  = short + `
+
+Use --all to apply the same changes to every machine of the app, optionally
+limited to a process group with --process-group. Machines are updated one at
+a time, and each one has to become healthy before the next one is updated.
+The machines of [[jobs]] are left out, as deploys manage them.
+
+Use --patch to apply a JSON merge patch (RFC 7396) or a JSON Patch (RFC 6902)
+document to the machine configuration, e.g.
+
+  {"restart": {"policy": "on-failure"}, "metadata": {"team": "infra"}}
+`
 
 		usage = "update [machine_id]"
 	)
@@ -61,6 +75,15 @@ func newUpdate() *cobra.Command {
 			Description: "Seconds to wait for individual machines to transition states and become healthy. (default 300)",
 			Default:     300,
 		},
+		flag.String{
+			Name:        "patch",
+			Description: "Path to a JSON merge patch or JSON Patch file to apply to the machine config, or '-' to read it from stdin",
+		},
+		flag.Bool{
+			Name:        "all",
+			Description: "Update all machines of the app",
+		},
+		flag.ProcessGroup("Only update machines of this process group, used with --all"),
 	)
 
 	cmd.Args = cobra.RangeArgs(0, 1)
@@ -80,6 +103,18 @@ func runUpdate(ctx context.Context) (err error) {
 		dockerfile       = flag.GetString(ctx, flag.Dockerfile().Name)
 	)
 
+	if flag.GetBool(ctx, "all") {
+		return runUpdateAll(ctx)
+	}
+	if flag.GetProcessGroup(ctx) != "" {
+		return errors.New("--process-group can only be used with --all")
+	}
+
+	patch, err := readConfigPatch(ctx)
+	if err != nil {
+		return err
+	}
+
 	machineID := flag.FirstArg(ctx)
 	haveMachineID := len(flag.Args(ctx)) > 0
 	machine, ctx, err := selectOneMachine(ctx, "", machineID, haveMachineID)
@@ -121,6 +156,12 @@ func runUpdate(ctx context.Context) (err error) {
 		machineConf.Mounts[0].Path = mp
 	}
 
+	if patch != nil {
+		if machineConf, err = mach.PatchConfig(machineConf, patch); err != nil {
+			return err
+		}
+	}
+
 	// Prompt user to confirm changes
 	if !autoConfirm {
 		confirmed, err := mach.ConfirmConfigChanges(ctx, machine, *machineConf, "")
@@ -143,16 +184,7 @@ func runUpdate(ctx context.Context) (err error) {
 		Timeout:          flag.GetInt(ctx, "wait-timeout"),
 	}
 	if err := mach.Update(ctx, machine, input); err != nil {
-		var timeoutErr mach.WaitTimeoutErr
-		if errors.As(err, &timeoutErr) {
-			return flyerr.GenericErr{
-				Err:      timeoutErr.Error(),
-				Descript: timeoutErr.Description(),
-				Suggest:  "Try increasing the --wait-timeout",
-			}
-
-		}
-		return err
+		return rewriteUpdateErr(err)
 	}
 
 	if !(input.SkipLaunch || flag.GetDetach(ctx)) {
@@ -168,3 +200,209 @@ func runUpdate(ctx context.Context) (err error) {
 
 	return nil
 }
+
+func runUpdateAll(ctx context.Context) error {
+	var (
+		io       = iostreams.FromContext(ctx)
+		colorize = io.ColorScheme()
+
+		autoConfirm      = flag.GetBool(ctx, "yes")
+		skipHealthChecks = flag.GetBool(ctx, "skip-health-checks")
+		skipStart        = flag.GetBool(ctx, "skip-start")
+		image            = flag.GetString(ctx, "image")
+		dockerfile       = flag.GetString(ctx, flag.Dockerfile().Name)
+		processGroup     = flag.GetProcessGroup(ctx)
+		appName          = appconfig.NameFromContext(ctx)
+	)
+
+	switch {
+	case len(flag.Args(ctx)) > 0:
+		return errors.New("machine IDs can't be used with --all")
+	case appName == "":
+		return errors.New("an app name must be specified to use --all")
+	case flag.IsSpecified(ctx, "mount-point"):
+		return errors.New("--mount-point can't be used with --all")
+	}
+
+	patch, err := readConfigPatch(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx, err = buildContextFromAppName(ctx, appName)
+	if err != nil {
+		return err
+	}
+
+	machines, err := listActiveAppMachines(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get a list of machines: %w", err)
+	}
+	if processGroup != "" {
+		machines = lo.Filter(machines, func(m *fly.Machine, _ int) bool {
+			return m.ProcessGroup() == processGroup
+		})
+	}
+	if len(machines) == 0 {
+		fmt.Fprintf(io.Out, "No machines to update\n")
+		return nil
+	}
+
+	// Resolve the image once instead of once per machine, as this might
+	// involve building it.
+	var imageRef string
+	switch {
+	case image != "":
+		imageRef = image
+	case dockerfile != "":
+		imageRef = "."
+	}
+	if imageRef != "" {
+		img, err := command.DetermineImage(ctx, appName, imageRef)
+		if err != nil {
+			return err
+		}
+		imageRef = img.Tag
+	}
+
+	type target struct {
+		machine *fly.Machine
+		config  *fly.MachineConfig
+	}
+	var targets []target
+
+	for _, machine := range machines {
+		machineConf, err := determineMachineConfig(ctx, &determineMachineConfigInput{
+			initialMachineConf: *machine.Config,
+			appName:            appName,
+			region:             machine.Region,
+			updating:           true,
+		})
+		if err != nil {
+			return fmt.Errorf("failed determining config for machine %s: %w", machine.ID, err)
+		}
+
+		if imageRef != "" {
+			machineConf.Image = imageRef
+		}
+
+		if patch != nil {
+			if machineConf, err = mach.PatchConfig(machineConf, patch); err != nil {
+				return fmt.Errorf("failed patching config for machine %s: %w", machine.ID, err)
+			}
+		}
+
+		if mach.ConfigDiff(ctx, *machine.Config, *machineConf) == "" {
+			continue
+		}
+
+		if !autoConfirm {
+			confirmed, err := mach.ConfirmConfigChanges(ctx, machine, *machineConf, "")
+			var noChangesErr *mach.ErrNoConfigChangesFound
+			switch {
+			case errors.As(err, &noChangesErr):
+				continue
+			case err != nil:
+				return err
+			case !confirmed:
+				continue
+			}
+		}
+
+		targets = append(targets, target{machine: machine, config: machineConf})
+	}
+
+	if len(targets) == 0 {
+		fmt.Fprintf(io.Out, "No changes to apply\n")
+		return nil
+	}
+
+	// Update machines one by one so that a bad change doesn't take down
+	// the whole app; mach.Update waits for health checks before returning.
+	// Each lease is taken right before its update, as a lease taken up front
+	// would expire while earlier machines are still being updated.
+	var updated int
+	for i, t := range targets {
+		input := &fly.LaunchMachineInput{
+			Name:             t.machine.Name,
+			Region:           t.machine.Region,
+			Config:           t.config,
+			SkipLaunch:       len(t.config.Standbys) > 0 || skipStart,
+			SkipHealthChecks: skipHealthChecks,
+			Timeout:          flag.GetInt(ctx, "wait-timeout"),
+		}
+		ok, err := updateWithLease(ctx, t.machine, input)
+		if err != nil {
+			if remaining := len(targets) - i - 1; remaining > 0 {
+				fmt.Fprintf(io.ErrOut, "Aborting, %d machine(s) were left untouched\n", remaining)
+			}
+			return rewriteUpdateErr(err)
+		}
+		if ok {
+			updated++
+		}
+	}
+
+	fmt.Fprintf(io.Out, "\n%s\n", colorize.Green(fmt.Sprintf("Updated %d machine(s)", updated)))
+
+	return nil
+}
+
+// updateWithLease leases m, applies input to it and releases the lease again.
+// Machines that changed since their new config was computed are skipped, as
+// applying it would silently revert the change; ok reports whether m was
+// updated.
+func updateWithLease(ctx context.Context, m *fly.Machine, input *fly.LaunchMachineInput) (ok bool, err error) {
+	leased, releaseLeaseFunc, err := mach.AcquireLease(ctx, m)
+	defer releaseLeaseFunc()
+	if err != nil {
+		return false, err
+	}
+
+	if leased.InstanceID != m.InstanceID {
+		io := iostreams.FromContext(ctx)
+		fmt.Fprintf(io.ErrOut, "Machine %s changed since its config was computed, skipping\n", m.ID)
+		return false, nil
+	}
+
+	if err := mach.Update(ctx, leased, input); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// readConfigPatch returns the contents of the file given with --patch, or nil
+// when the flag wasn't specified.
+func readConfigPatch(ctx context.Context) ([]byte, error) {
+	path := flag.GetString(ctx, "patch")
+	if path == "" {
+		return nil, nil
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(iostreams.FromContext(ctx).In)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed reading patch file: %w", err)
+	}
+
+	return data, nil
+}
+
+func rewriteUpdateErr(err error) error {
+	var timeoutErr mach.WaitTimeoutErr
+	if errors.As(err, &timeoutErr) {
+		return flyerr.GenericErr{
+			Err:      timeoutErr.Error(),
+			Descript: timeoutErr.Description(),
+			Suggest:  "Try increasing the --wait-timeout",
+		}
+	}
+	return err
+}
@@ -4,9 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
 	fly "github.com/superfly/fly-go"
 	"github.com/superfly/flyctl/agent"
 	"github.com/superfly/flyctl/internal/appconfig"
@@ -14,20 +19,42 @@ import (
 	"github.com/superfly/flyctl/internal/flag"
 	"github.com/superfly/flyctl/internal/flag/flagnames"
 	"github.com/superfly/flyctl/internal/prompt"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/ip"
 	"github.com/superfly/flyctl/proxy"
 )
 
 func New() *cobra.Command {
 	var (
 		long = strings.Trim(`Proxies connections to a Fly Machine through a WireGuard tunnel. By default,
-connects to the first Machine address returned by an internal DNS query on the app.`, "\n")
+connects to the first Machine address returned by an internal DNS query on the app.
+
+Several mappings can be proxied at once, each in the form local:remote or
+local:host:remote, e.g. "fly proxy 5432:db.internal:5432 6379:redis.internal:6379 8080:web:80".
+Hosts without a domain are expanded to <host>.internal. Append /udp to a
+mapping to forward UDP datagrams instead, e.g. "5353:dns.internal:53/udp".
+Mappings can also be
+read from the [proxy] section of a profile file with --profile.
+
+With --socks, a SOCKS5 proxy that also accepts HTTP CONNECT requests is served
+on the given local port. It resolves .internal and .flycast names through the
+organization's private DNS, so any private address can be reached with e.g.
+"curl --proxy socks5h://localhost:1080 http://web.internal:8080".
+Connections to other hosts are refused, unless --direct is set to make them
+directly, bypassing the tunnel. Don't combine --direct with a --bind-addr
+reachable by others, as that serves an open proxy to the internet.
+
+With --balance, each mapping's connections are spread over all of the app's
+Machines, round-robin or to whichever has the fewest active connections.
+Machines are health checked with TCP dials and re-resolved periodically.
+Mappings can't name a host other than the app's with --balance.`, "\n")
 		short = `Proxies connections to a Fly Machine.`
 	)
 
-	cmd := command.New("proxy <local:remote> [remote_host]", short, long, run,
+	cmd := command.New("proxy <local:remote>... [remote_host]", short, long, run,
 		command.RequireSession, command.LoadAppNameIfPresent)
 
-	cmd.Args = cobra.RangeArgs(1, 2)
+	cmd.Args = cobra.ArbitraryArgs
 
 	flag.Add(cmd,
 		flag.App(),
@@ -50,6 +77,22 @@ connects to the first Machine address returned by an internal DNS query on the a
 			Default:     "127.0.0.1",
 			Description: "Local address to bind to",
 		},
+		flag.String{
+			Name:        "profile",
+			Description: "Path to a TOML file whose [proxy] section lists the mappings to proxy",
+		},
+		flag.String{
+			Name:        "balance",
+			Description: fmt.Sprintf("Spread connections over all of the app's Machines, health checking each one. One of %s", strings.Join(proxy.BalanceStrategies, ", ")),
+		},
+		flag.String{
+			Name:        "socks",
+			Description: "Local port to serve a SOCKS5 and HTTP CONNECT proxy into the organization's private network on",
+		},
+		flag.Bool{
+			Name:        "direct",
+			Description: "With --socks, connect directly to hosts outside of the private network instead of refusing them",
+		},
 	)
 
 	return cmd
@@ -63,10 +106,53 @@ func run(ctx context.Context) (err error) {
 
 	args := flag.Args(ctx)
 	promptInstance := flag.GetBool(ctx, "select")
+	bindAddr := flag.GetBindAddr(ctx)
+	socksPort := flag.GetString(ctx, "socks")
+	balance := flag.GetString(ctx, "balance")
+
+	if balance != "" && !slices.Contains(proxy.BalanceStrategies, balance) {
+		return fmt.Errorf("--balance must be one of %s", strings.Join(proxy.BalanceStrategies, ", "))
+	}
+	if balance != "" && promptInstance {
+		return errors.New("--balance and --select can't be used together")
+	}
+
+	var specs []string
+	if path := flag.GetString(ctx, "profile"); path != "" {
+		profile, err := proxy.LoadProfile(path)
+		if err != nil {
+			return err
+		}
+		if profile.App != "" && !flag.IsSpecified(ctx, flagnames.App) {
+			appName = profile.App
+		}
+		if profile.Org != "" && orgSlug == "" {
+			orgSlug = profile.Org
+		}
+		if profile.BindAddr != "" && !flag.IsSpecified(ctx, flagnames.BindAddr) {
+			bindAddr = profile.BindAddr
+		}
+		if profile.Socks != "" && socksPort == "" {
+			socksPort = profile.Socks
+		}
+		specs = append(specs, profile.Mappings...)
+	} else if len(args) == 0 && socksPort == "" {
+		return errors.New("at least one port mapping, --profile or --socks is required")
+	}
+
+	if flag.GetBool(ctx, "direct") && socksPort == "" {
+		return errors.New("--direct can only be used with --socks")
+	}
+
+	mappingArgs, remoteHost := splitRemoteHost(args)
+	specs = append(specs, mappingArgs...)
 
 	if promptInstance && appName == "" {
 		return errors.New("--app required when --select flag provided")
 	}
+	if balance != "" && appName == "" {
+		return errors.New("--app required when --balance flag provided")
+	}
 
 	if orgSlug != "" {
 		_, err := client.GetOrganizationBySlug(ctx, orgSlug)
@@ -108,22 +194,81 @@ func run(ctx context.Context) (err error) {
 		return err
 	}
 
-	ports := strings.Split(args[0], ":")
+	if remoteHost == "" && appName != "" {
+		remoteHost = fmt.Sprintf("%s.internal", appName)
+	}
+
+	mappings, err := proxy.ParseMappings(specs, remoteHost)
+	if err != nil {
+		return err
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
 
-	params := &proxy.ConnectParams{
-		BindAddr:         flag.GetBindAddr(ctx),
-		Ports:            ports,
-		AppName:          appName,
-		OrganizationSlug: orgSlug,
-		Dialer:           dialer,
-		PromptInstance:   promptInstance,
+	if socksPort != "" {
+		socks, err := newSocksServer(ctx, agentclient, dialer, orgSlug, bindAddr, socksPort, flag.GetBool(ctx, "direct"))
+		if err != nil {
+			return err
+		}
+		eg.Go(func() error {
+			return socks.Serve(ctx)
+		})
 	}
 
-	if len(args) > 1 {
-		params.RemoteHost = args[1]
-	} else {
-		params.RemoteHost = fmt.Sprintf("%s.internal", appName)
+	if len(mappings) > 0 {
+		params := &proxy.ConnectParams{
+			BindAddr:         bindAddr,
+			AppName:          appName,
+			OrganizationSlug: orgSlug,
+			Dialer:           dialer,
+			PromptInstance:   promptInstance,
+			Balance:          balance,
+		}
+		eg.Go(func() error {
+			return proxy.ConnectMappings(ctx, params, mappings)
+		})
 	}
 
-	return proxy.Connect(ctx, params)
+	return eg.Wait()
+}
+
+func newSocksServer(ctx context.Context, agentclient *agent.Client, dialer agent.Dialer, orgSlug, bindAddr, port string, direct bool) (*proxy.SocksServer, error) {
+	ln, err := net.Listen("tcp", net.JoinHostPort(bindAddr, port))
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(iostreams.FromContext(ctx).Out, "Serving SOCKS5 and HTTP CONNECT proxy for organization %s on %s\n", orgSlug, ln.Addr())
+
+	socks := &proxy.SocksServer{
+		Listener: ln,
+		Resolve: func(ctx context.Context, host string) (string, error) {
+			return agentclient.Resolve(ctx, orgSlug, host)
+		},
+		Dial: dialer.DialContext,
+	}
+	if direct {
+		socks.Direct = (&net.Dialer{}).DialContext
+	}
+
+	return socks, nil
+}
+
+// splitRemoteHost supports the original "<local:remote> [remote_host]" form,
+// where a trailing argument that isn't a port mapping names the host every
+// mapping without its own host is proxied to. The host may be a bare IPv6
+// address, as in "fly proxy 5432 fdaa::3".
+func splitRemoteHost(args []string) (mappings []string, remoteHost string) {
+	if len(args) != 2 {
+		return args, ""
+	}
+	if ip.IsV6(args[1]) {
+		return args[:1], strings.Trim(args[1], "[]")
+	}
+	if !strings.Contains(args[1], ":") {
+		if _, err := strconv.Atoi(args[1]); err != nil {
+			return args[:1], args[1]
+		}
+	}
+	return args, ""
 }
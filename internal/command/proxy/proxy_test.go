@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitRemoteHost(t *testing.T) {
+	cases := []struct {
+		args     []string
+		mappings []string
+		host     string
+	}{
+		{[]string{"5432"}, []string{"5432"}, ""},
+		{[]string{"5432:5433", "db.internal"}, []string{"5432:5433"}, "db.internal"},
+		{[]string{"5432", "6379"}, []string{"5432", "6379"}, ""},
+		{[]string{"5432", "fdaa::3"}, []string{"5432"}, "fdaa::3"},
+		{[]string{"5432", "[fdaa::3]"}, []string{"5432"}, "fdaa::3"},
+		{[]string{"5432:5433", "fdaa:0:1:a7b:1::2"}, []string{"5432:5433"}, "fdaa:0:1:a7b:1::2"},
+		{[]string{"5432:db:5432", "6379:redis:6379"}, []string{"5432:db:5432", "6379:redis:6379"}, ""},
+		{[]string{"5432:db:5432", "6379:redis:6379", "8080:web:80"}, []string{"5432:db:5432", "6379:redis:6379", "8080:web:80"}, ""},
+	}
+
+	for _, tc := range cases {
+		mappings, host := splitRemoteHost(tc.args)
+		assert.Equal(t, tc.mappings, mappings)
+		assert.Equal(t, tc.host, host)
+	}
+}
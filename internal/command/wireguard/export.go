@@ -0,0 +1,310 @@
+package wireguard
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/qrcode"
+	"github.com/superfly/flyctl/iostreams"
+)
+
+// Formats a WireGuard peer's configuration can be exported as.
+const (
+	formatWgQuick         = "wg-quick"
+	formatNetworkManager  = "networkmanager"
+	formatSystemdNetworkd = "systemd-networkd"
+	formatKubernetes      = "kubernetes"
+	formatQR              = "qr"
+)
+
+var peerConfigFormats = []string{formatWgQuick, formatNetworkManager, formatSystemdNetworkd, formatKubernetes, formatQR}
+
+func peerConfigFormatFlag() flag.String {
+	return flag.String{
+		Name:        "format",
+		Description: "Configuration format: " + strings.Join(peerConfigFormats, ", "),
+		Default:     formatWgQuick,
+	}
+}
+
+// validatePeerConfigFormat checks the --format flag. It's called before a
+// peer is created, since its private key is lost if it can't be written out.
+func validatePeerConfigFormat(ctx context.Context) error {
+	if format := flag.GetString(ctx, "format"); !slices.Contains(peerConfigFormats, format) {
+		return fmt.Errorf("unknown format %q, must be one of: %s", format, strings.Join(peerConfigFormats, ", "))
+	}
+	return nil
+}
+
+// peerConfig is the client side configuration of a WireGuard peer, shared
+// by every export format.
+type peerConfig struct {
+	Name       string
+	Interface  string
+	PrivateKey string
+	Address    string
+	DNS        string
+	PublicKey  string
+	AllowedIPs string
+	Endpoint   string
+	Keepalive  int
+}
+
+var nonInterfaceChars = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+func newPeerConfig(name string, peer *fly.CreatedWireGuardPeer, privkey string) *peerConfig {
+	addr := net.ParseIP(peer.Peerip).To16()
+	for i := 6; i < 16; i++ {
+		addr[i] = 0
+	}
+
+	// BUG(tqbf): can't stay this way
+	allowed := fmt.Sprintf("%s/48", addr)
+
+	addr[15] = 3
+
+	// interface names are limited to 15 characters
+	iface := "fly"
+	if name != "" {
+		iface = "fly-" + nonInterfaceChars.ReplaceAllString(name, "-")
+	}
+	if len(iface) > 15 {
+		iface = iface[:15]
+	}
+
+	return &peerConfig{
+		Name:       name,
+		Interface:  strings.TrimRight(iface, "-"),
+		PrivateKey: privkey,
+		Address:    peer.Peerip,
+		DNS:        addr.String(),
+		PublicKey:  peer.Pubkey,
+		AllowedIPs: allowed,
+		Endpoint:   net.JoinHostPort(peer.Endpointip, "51820"),
+		Keepalive:  15,
+	}
+}
+
+// exportedFile is one file of an exported configuration.
+type exportedFile struct {
+	Name     string
+	Contents []byte
+}
+
+var configTemplates = template.Must(template.New("").Parse(`
+{{define "wg-quick"}}
+[Interface]
+PrivateKey = {{.PrivateKey}}
+Address = {{.Address}}/120
+DNS = {{.DNS}}
+
+[Peer]
+PublicKey = {{.PublicKey}}
+AllowedIPs = {{.AllowedIPs}}
+Endpoint = {{.Endpoint}}
+PersistentKeepalive = {{.Keepalive}}
+
+{{end}}
+
+{{define "networkmanager"}}[connection]
+id={{.Interface}}
+type=wireguard
+interface-name={{.Interface}}
+
+[wireguard]
+private-key={{.PrivateKey}}
+
+[wireguard-peer.{{.PublicKey}}]
+endpoint={{.Endpoint}}
+allowed-ips={{.AllowedIPs}};
+persistent-keepalive={{.Keepalive}}
+
+[ipv4]
+method=disabled
+
+[ipv6]
+method=manual
+address1={{.Address}}/120
+dns={{.DNS}};
+{{end}}
+
+{{define "netdev"}}[NetDev]
+Name={{.Interface}}
+Kind=wireguard
+
+[WireGuard]
+PrivateKey={{.PrivateKey}}
+
+[WireGuardPeer]
+PublicKey={{.PublicKey}}
+AllowedIPs={{.AllowedIPs}}
+Endpoint={{.Endpoint}}
+PersistentKeepalive={{.Keepalive}}
+{{end}}
+
+{{define "network"}}[Match]
+Name={{.Interface}}
+
+[Network]
+Address={{.Address}}/120
+DNS={{.DNS}}
+
+[Route]
+Destination={{.AllowedIPs}}
+{{end}}
+
+{{define "kubernetes"}}apiVersion: v1
+kind: Secret
+type: Opaque
+metadata:
+  name: {{.Name}}
+data:
+  {{.Key}}: {{.Data}}
+{{end}}
+`))
+
+func (c *peerConfig) render(name string, data any) []byte {
+	var buf bytes.Buffer
+	if data == nil {
+		data = c
+	}
+	if err := configTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// secretName returns a DNS-1123 name for the Kubernetes Secret.
+func (c *peerConfig) secretName() string {
+	name := strings.Trim(nonInterfaceChars.ReplaceAllString(strings.ToLower(c.Name), "-"), "-")
+	if name == "" {
+		return "fly-wireguard"
+	}
+	return "fly-wireguard-" + name
+}
+
+// export renders c in format. Most formats are a single file; systemd-networkd
+// needs a .netdev and a .network file.
+func (c *peerConfig) export(format string) ([]exportedFile, error) {
+	switch format {
+	case formatWgQuick:
+		return []exportedFile{{c.Interface + ".conf", c.render("wg-quick", nil)}}, nil
+	case formatNetworkManager:
+		return []exportedFile{{c.Interface + ".nmconnection", c.render("networkmanager", nil)}}, nil
+	case formatSystemdNetworkd:
+		return []exportedFile{
+			{c.Interface + ".netdev", c.render("netdev", nil)},
+			{c.Interface + ".network", c.render("network", nil)},
+		}, nil
+	case formatKubernetes:
+		manifest := c.render("kubernetes", struct{ Name, Key, Data string }{
+			Name: c.secretName(),
+			Key:  c.Interface + ".conf",
+			Data: base64.StdEncoding.EncodeToString(c.render("wg-quick", nil)),
+		})
+		return []exportedFile{{c.secretName() + ".yaml", manifest}}, nil
+	case formatQR:
+		code, err := qrcode.Encode(bytes.TrimSpace(c.render("wg-quick", nil)))
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := code.WriteTerminal(&buf); err != nil {
+			return nil, err
+		}
+		return []exportedFile{{c.Interface + ".txt", buf.Bytes()}}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, must be one of: %s", format, strings.Join(peerConfigFormats, ", "))
+	}
+}
+
+// writePeerConfig exports cfg in the format chosen by the --format flag to
+// the file named by argument idx, prompting for one if it's missing. QR codes
+// are always printed to the terminal, and systemd-networkd files are written
+// to a directory.
+func writePeerConfig(ctx context.Context, idx int, cfg *peerConfig) error {
+	io := iostreams.FromContext(ctx)
+
+	format := flag.GetString(ctx, "format")
+
+	files, err := cfg.export(format)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case formatQR:
+		fmt.Fprintf(io.Out, "Scan this code with the WireGuard mobile app:\n\n")
+		_, err := io.Out.Write(files[0].Contents)
+		return err
+	case formatSystemdNetworkd:
+		dir, err := argOrPrompt(ctx, idx, "Directory to store systemd-networkd files in (e.g. /etc/systemd/network), or 'stdout': ")
+		if err != nil {
+			return err
+		}
+		if dir == "" || dir == "stdout" {
+			for _, f := range files {
+				fmt.Fprintf(io.Out, "# %s\n%s\n", f.Name, f.Contents)
+			}
+			return nil
+		}
+		for _, f := range files {
+			path := filepath.Join(dir, f.Name)
+			if err := writeNewFile(path, f.Contents); err != nil {
+				return err
+			}
+			fmt.Fprintf(io.Out, "Wrote %s\n", path)
+		}
+		fmt.Fprintf(io.Out, "The .netdev file must be readable by the systemd-network group; run `networkctl reload` to load it\n")
+		return nil
+	}
+
+	w, shouldClose, err := resolveOutputWriter(ctx, idx, "Filename to store WireGuard configuration in, or 'stdout': ")
+	if err != nil {
+		return err
+	}
+	if shouldClose {
+		defer w.Close() // skipcq: GO-S2307
+	}
+
+	if _, err := w.Write(files[0].Contents); err != nil {
+		return err
+	}
+
+	if shouldClose {
+		filename := w.(*os.File).Name()
+		switch format {
+		case formatNetworkManager:
+			fmt.Fprintf(io.Out, "Wrote NetworkManager connection to %s; copy it to /etc/NetworkManager/system-connections\n", filename)
+		case formatKubernetes:
+			fmt.Fprintf(io.Out, "Wrote Kubernetes Secret to %s; apply it with `kubectl apply -f %s`\n", filename, filename)
+		default:
+			fmt.Fprintf(io.Out, "Wrote WireGuard configuration to %s; load in your WireGuard client\n", filename)
+		}
+	}
+
+	return nil
+}
+
+func writeNewFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,141 @@
+package wireguard
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+)
+
+var testPeer = &fly.CreatedWireGuardPeer{
+	Peerip:     "fdaa:0:1:a7b:8aa:0:a:2",
+	Endpointip: "203.0.113.7",
+	Pubkey:     "c2VydmVyLXB1YmxpYy1rZXktYmFzZTY0LWVuY29kZWQ=",
+}
+
+const testPrivkey = "Y2xpZW50LXByaXZhdGUta2V5LWJhc2U2NC1lbmNvZGU="
+
+func TestNewPeerConfig(t *testing.T) {
+	cfg := newPeerConfig("interactive-laptop", testPeer, testPrivkey)
+
+	assert.Equal(t, "fdaa:0:1::/48", cfg.AllowedIPs)
+	assert.Equal(t, "fdaa:0:1::3", cfg.DNS)
+	assert.Equal(t, "203.0.113.7:51820", cfg.Endpoint)
+	assert.Equal(t, "fly-interactive", cfg.Interface)
+
+	assert.Equal(t, "fly", newPeerConfig("", testPeer, testPrivkey).Interface)
+	assert.Equal(t, "fly-a-b", newPeerConfig("a.b", testPeer, testPrivkey).Interface)
+	assert.Equal(t, "fly-abcdefghij", newPeerConfig("abcdefghij-klm", testPeer, testPrivkey).Interface)
+}
+
+func TestExportWgQuick(t *testing.T) {
+	files, err := newPeerConfig("laptop", testPeer, testPrivkey).export(formatWgQuick)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	assert.Equal(t, "fly-laptop.conf", files[0].Name)
+	assert.Equal(t, `
+[Interface]
+PrivateKey = Y2xpZW50LXByaXZhdGUta2V5LWJhc2U2NC1lbmNvZGU=
+Address = fdaa:0:1:a7b:8aa:0:a:2/120
+DNS = fdaa:0:1::3
+
+[Peer]
+PublicKey = c2VydmVyLXB1YmxpYy1rZXktYmFzZTY0LWVuY29kZWQ=
+AllowedIPs = fdaa:0:1::/48
+Endpoint = 203.0.113.7:51820
+PersistentKeepalive = 15
+
+`, string(files[0].Contents))
+}
+
+func TestExport(t *testing.T) {
+	cfg := newPeerConfig("laptop", testPeer, testPrivkey)
+
+	cases := []struct {
+		format string
+		names  []string
+		want   []string
+	}{
+		{
+			format: formatNetworkManager,
+			names:  []string{"fly-laptop.nmconnection"},
+			want: []string{
+				"interface-name=fly-laptop\n",
+				"private-key=" + testPrivkey + "\n",
+				"[wireguard-peer." + testPeer.Pubkey + "]\n",
+				"allowed-ips=fdaa:0:1::/48;\n",
+				"address1=fdaa:0:1:a7b:8aa:0:a:2/120\n",
+				"dns=fdaa:0:1::3;\n",
+			},
+		},
+		{
+			format: formatSystemdNetworkd,
+			names:  []string{"fly-laptop.netdev", "fly-laptop.network"},
+			want: []string{
+				"Kind=wireguard\n",
+				"PrivateKey=" + testPrivkey + "\n",
+				"Endpoint=203.0.113.7:51820\n",
+				"Address=fdaa:0:1:a7b:8aa:0:a:2/120\n",
+				"Destination=fdaa:0:1::/48\n",
+			},
+		},
+		{
+			format: formatKubernetes,
+			names:  []string{"fly-wireguard-laptop.yaml"},
+			want: []string{
+				"kind: Secret\n",
+				"  name: fly-wireguard-laptop\n",
+				"  fly-laptop.conf: ",
+			},
+		},
+		{
+			format: formatQR,
+			names:  []string{"fly-laptop.txt"},
+			want:   []string{"█"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.format, func(t *testing.T) {
+			files, err := cfg.export(tc.format)
+			require.NoError(t, err)
+
+			var names []string
+			var contents strings.Builder
+			for _, f := range files {
+				names = append(names, f.Name)
+				contents.Write(f.Contents)
+			}
+
+			assert.Equal(t, tc.names, names)
+			for _, w := range tc.want {
+				assert.Contains(t, contents.String(), w)
+			}
+		})
+	}
+
+	_, err := cfg.export("ini")
+	assert.ErrorContains(t, err, `unknown format "ini"`)
+}
+
+func TestExportKubernetesSecretData(t *testing.T) {
+	cfg := newPeerConfig("Laptop.Home", testPeer, testPrivkey)
+
+	files, err := cfg.export(formatKubernetes)
+	require.NoError(t, err)
+
+	_, encoded, found := strings.Cut(string(files[0].Contents), "fly-Laptop-Home.conf: ")
+	require.True(t, found)
+
+	conf, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	require.NoError(t, err)
+
+	wgQuick, err := cfg.export(formatWgQuick)
+	require.NoError(t, err)
+	assert.Equal(t, string(wgQuick[0].Contents), string(conf))
+	assert.Contains(t, string(files[0].Contents), "  name: fly-wireguard-laptop-home\n")
+}
@@ -0,0 +1,131 @@
+package wireguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/azazeal/pause"
+	"github.com/spf13/viper"
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/agent"
+	"github.com/superfly/flyctl/agent/server"
+	"github.com/superfly/flyctl/flyctl"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/state"
+	"github.com/superfly/flyctl/iostreams"
+	"github.com/superfly/flyctl/proxy"
+	"golang.org/x/sync/errgroup"
+)
+
+func runWireguardGateway(ctx context.Context) error {
+	io := iostreams.FromContext(ctx)
+	apiClient := fly.ClientFromContext(ctx)
+
+	org, err := orgByArg(ctx)
+	if err != nil {
+		return err
+	}
+
+	socket := flag.GetString(ctx, "socket")
+	if socket == "" {
+		socket = filepath.Join(state.ConfigDirectory(ctx), "fly-gateway.sock")
+	}
+
+	// binding removes whatever is at the socket path, so refuse to replace a
+	// gateway or agent that's still serving there
+	if _, err := agent.Dial(ctx, "unix", socket); err == nil {
+		return fmt.Errorf("an agent or gateway is already listening on %s", socket)
+	}
+
+	ln, err := net.Listen("tcp", flag.GetString(ctx, "socks"))
+	if err != nil {
+		return err
+	}
+
+	websockets := viper.GetBool(flyctl.ConfigWireGuardWebsockets)
+	if flag.IsSpecified(ctx, "websockets") {
+		websockets = flag.GetBool(ctx, "websockets")
+	}
+
+	logger := log.New(io.ErrOut, "gateway ", log.Ldate|log.Lmicroseconds|log.Lmsgprefix)
+
+	eg, ctx := errgroup.WithContext(ctx)
+
+	eg.Go(func() error {
+		err := server.Run(ctx, server.Options{
+			Socket:           socket,
+			Logger:           logger,
+			Client:           apiClient,
+			ConfigFile:       state.ConfigFile(ctx),
+			ConfigWebsockets: websockets,
+			DNSAddr:          viper.GetString(flyctl.ConfigAgentDNSAddr),
+			DNSOrg:           org.Slug,
+		})
+		if err == nil && ctx.Err() == nil {
+			err = errors.New("gateway agent stopped")
+		}
+		return err
+	})
+
+	eg.Go(func() error {
+		client, err := waitForGateway(ctx, socket)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+
+		dialer, err := client.ConnectToTunnel(ctx, org.Slug, true)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+
+		fmt.Fprintf(io.Out, "WireGuard gateway for organization %s is up (peer %s)\n", org.Slug, dialer.State().Name)
+		fmt.Fprintf(io.Out, "Serving SOCKS5 and HTTP CONNECT proxy on %s\n", ln.Addr())
+		fmt.Fprintf(io.Out, "\nTo share this tunnel with other flyctl commands, run:\n\n")
+		fmt.Fprintf(io.Out, "  export FLY_AGENT_SOCKET=%s\n\n", socket)
+
+		socks := &proxy.SocksServer{
+			Listener: ln,
+			Resolve: func(ctx context.Context, host string) (string, error) {
+				return client.Resolve(ctx, org.Slug, host)
+			},
+			Dial: dialer.DialContext,
+		}
+		if flag.GetBool(ctx, "direct") {
+			socks.Direct = (&net.Dialer{}).DialContext
+
+			fmt.Fprintf(io.Out, "Hosts outside of the private network are connected to directly, so other tools can use:\n\n")
+			fmt.Fprintf(io.Out, "  export ALL_PROXY=socks5h://%s\n\n", ln.Addr())
+		} else {
+			fmt.Fprintf(io.Out, "Only private hosts can be proxied to, so point tools at the proxy for those only, e.g.\n\n")
+			fmt.Fprintf(io.Out, "  curl --proxy socks5h://%s http://my-app.internal:8080\n\n", ln.Addr())
+		}
+
+		return socks.Serve(ctx)
+	})
+
+	return eg.Wait()
+}
+
+// waitForGateway waits for the agent server to start listening on socket.
+func waitForGateway(ctx context.Context, socket string) (*agent.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	for {
+		client, err := agent.Dial(ctx, "unix", socket)
+		if err == nil {
+			return client, nil
+		}
+
+		if pause.For(ctx, 100*time.Millisecond); ctx.Err() != nil {
+			return nil, fmt.Errorf("gateway agent didn't start: %w", err)
+		}
+	}
+}
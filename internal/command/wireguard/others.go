@@ -4,9 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net"
 	"os"
-	"text/template"
 
 	"github.com/AlecAivazis/survey/v2"
 	fly "github.com/superfly/fly-go"
@@ -75,48 +73,6 @@ func resolveOutputWriter(ctx context.Context, idx int, prompt string) (w io.Writ
 	}
 }
 
-func generateWgConf(peer *fly.CreatedWireGuardPeer, privkey string, w io.Writer) {
-	templateStr := `
-[Interface]
-PrivateKey = {{.Meta.Privkey}}
-Address = {{.Peer.Peerip}}/120
-DNS = {{.Meta.DNS}}
-
-[Peer]
-PublicKey = {{.Peer.Pubkey}}
-AllowedIPs = {{.Meta.AllowedIPs}}
-Endpoint = {{.Peer.Endpointip}}:51820
-PersistentKeepalive = 15
-
-`
-	data := struct {
-		Peer *fly.CreatedWireGuardPeer
-		Meta struct {
-			Privkey    string
-			AllowedIPs string
-			DNS        string
-		}
-	}{
-		Peer: peer,
-	}
-
-	addr := net.ParseIP(peer.Peerip).To16()
-	for i := 6; i < 16; i++ {
-		addr[i] = 0
-	}
-
-	// BUG(tqbf): can't stay this way
-	data.Meta.AllowedIPs = fmt.Sprintf("%s/48", addr)
-
-	addr[15] = 3
-
-	data.Meta.DNS = addr.String()
-	data.Meta.Privkey = privkey
-
-	tmpl := template.Must(template.New("name").Parse(templateStr))
-	tmpl.Execute(w, &data)
-}
-
 func selectWireGuardPeer(ctx context.Context, client *fly.Client, slug string) (string, error) {
 	peers, err := client.GetWireGuardPeers(ctx, slug)
 	if err != nil {
@@ -21,6 +21,7 @@ func New() *cobra.Command {
 		newWireguardReset(),
 		newWireguardWebsockets(),
 		newWireguardToken(),
+		newWireguardGateway(),
 	)
 	return cmd
 }
@@ -48,6 +49,9 @@ func newWireguardCreate() *cobra.Command {
 	cmd := command.New("create [org] [region] [name] [file]", short, long, runWireguardCreate,
 		command.RequireSession,
 	)
+	flag.Add(cmd,
+		peerConfigFormatFlag(),
+	)
 	cmd.Args = cobra.MaximumNArgs(4)
 	return cmd
 }
@@ -88,6 +92,46 @@ func newWireguardWebsockets() *cobra.Command {
 	return cmd
 }
 
+func newWireguardGateway() *cobra.Command {
+	const (
+		short = "Run a userspace WireGuard gateway in the foreground"
+		long  = `Run a userspace WireGuard gateway to an organization in the foreground.
+
+The gateway needs no kernel WireGuard support, which makes it suitable for CI.
+It serves the agent protocol on a Unix socket, so other flyctl commands that set
+FLY_AGENT_SOCKET to it share its tunnel instead of starting their own, and a
+SOCKS5 and HTTP CONNECT proxy for other tools. The proxy only routes .internal
+and .flycast names and private IPv6 addresses through the tunnel and refuses
+other hosts. With --direct, it connects to other hosts directly instead, so it
+can be set as ALL_PROXY for a whole job; keep --socks on a loopback address
+then, as it serves an open proxy to the internet.`
+	)
+	cmd := command.New("gateway [org]", short, long, runWireguardGateway,
+		command.RequireSession,
+	)
+	flag.Add(cmd,
+		flag.String{
+			Name:        "socket",
+			Description: "Path of the Unix socket to serve the agent protocol on (default fly-gateway.sock in the config directory)",
+		},
+		flag.String{
+			Name:        "socks",
+			Description: "Local address to serve the SOCKS5 and HTTP CONNECT proxy on",
+			Default:     "127.0.0.1:1080",
+		},
+		flag.Bool{
+			Name:        "direct",
+			Description: "Connect directly to hosts outside of the private network instead of refusing them",
+		},
+		flag.Bool{
+			Name:        "websockets",
+			Description: "Tunnel WireGuard over WebSockets, for networks that block UDP (defaults to the wire_guard_websockets setting)",
+		},
+	)
+	cmd.Args = cobra.MaximumNArgs(1)
+	return cmd
+}
+
 func newWireguardToken() *cobra.Command {
 	const (
 		short = "Commands that managed WireGuard delegated access tokens"
@@ -153,6 +197,9 @@ func newWireguardTokenStart() *cobra.Command {
 	cmd := command.New("start [name] [group] [region] [file]", short, long, runWireguardTokenStart,
 		command.RequireSession,
 	)
+	flag.Add(cmd,
+		peerConfigFormatFlag(),
+	)
 	cmd.Args = cobra.MaximumNArgs(4)
 	return cmd
 }
@@ -165,6 +212,9 @@ func newWireguardTokenUpdate() *cobra.Command {
 	cmd := command.New("update [name] [file]", short, long, runWireguardTokenUpdate,
 		command.RequireSession,
 	)
+	flag.Add(cmd,
+		peerConfigFormatFlag(),
+	)
 	cmd.Args = cobra.MaximumNArgs(2)
 	return cmd
 }
@@ -169,36 +169,25 @@ type PeerStatusJson struct {
 	Error  string `json:"error"`
 }
 
-func generateTokenConf(ctx context.Context, idx int, stat *PeerStatusJson, privkey string) error {
+func generateTokenConf(ctx context.Context, idx int, name string, stat *PeerStatusJson, privkey string) error {
 	fmt.Printf(`
 !!!! WARNING: Output includes private key. Private keys cannot be recovered !!!!
 !!!! after creating the peer; if you lose the key, you'll need to rekey     !!!!
 !!!! the peering connection.                                                !!!!
 `)
 
-	w, shouldClose, err := resolveOutputWriter(ctx, idx, "Filename to store WireGuard configuration in, or 'stdout': ")
-	if err != nil {
-		return err
-	}
-	if shouldClose {
-		defer w.Close() // skipcq: GO-S2307
-	}
-
-	generateWgConf(&fly.CreatedWireGuardPeer{
+	return writePeerConfig(ctx, idx, newPeerConfig(name, &fly.CreatedWireGuardPeer{
 		Peerip:     stat.Us,
 		Pubkey:     stat.Pubkey,
 		Endpointip: stat.Them,
-	}, privkey, w)
-
-	if shouldClose {
-		filename := w.(*os.File).Name()
-		fmt.Printf("Wrote WireGuard configuration to %s; load in your WireGuard client\n", filename)
-	}
-
-	return nil
+	}, privkey))
 }
 
 func runWireguardTokenStart(ctx context.Context) error {
+	if err := validatePeerConfigFormat(ctx); err != nil {
+		return err
+	}
+
 	token := os.Getenv("FLY_WIREGUARD_TOKEN")
 	if token == "" {
 		return fmt.Errorf("set FLY_WIREGUARD_TOKEN env")
@@ -246,7 +235,7 @@ func runWireguardTokenStart(ctx context.Context) error {
 		return fmt.Errorf("WireGuard API error: %s", peerStatus.Error)
 	}
 
-	if err = generateTokenConf(ctx, 3, peerStatus, privatekey); err != nil {
+	if err = generateTokenConf(ctx, 3, name, peerStatus, privatekey); err != nil {
 		return err
 	}
 
@@ -254,6 +243,10 @@ func runWireguardTokenStart(ctx context.Context) error {
 }
 
 func runWireguardTokenUpdate(ctx context.Context) error {
+	if err := validatePeerConfigFormat(ctx); err != nil {
+		return err
+	}
+
 	token := os.Getenv("FLY_WIREGUARD_TOKEN")
 	if token == "" {
 		return fmt.Errorf("set FLY_WIREGUARD_TOKEN env")
@@ -288,7 +281,7 @@ func runWireguardTokenUpdate(ctx context.Context) error {
 		return fmt.Errorf("WireGuard API error: %s", peerStatus.Error)
 	}
 
-	if err = generateTokenConf(ctx, 1, peerStatus, privatekey); err != nil {
+	if err = generateTokenConf(ctx, 1, name, peerStatus, privatekey); err != nil {
 		return err
 	}
 
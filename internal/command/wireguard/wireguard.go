@@ -3,7 +3,6 @@ package wireguard
 import (
 	"context"
 	"fmt"
-	"os"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
@@ -124,6 +123,10 @@ func runWireguardCreate(ctx context.Context) error {
 	io := iostreams.FromContext(ctx)
 	apiClient := fly.ClientFromContext(ctx)
 
+	if err := validatePeerConfigFormat(ctx); err != nil {
+		return err
+	}
+
 	org, err := orgByArg(ctx)
 	if err != nil {
 		return err
@@ -154,22 +157,7 @@ func runWireguardCreate(ctx context.Context) error {
 !!!! and re-add the peering connection.                                     !!!!
 `)
 
-	w, shouldClose, err := resolveOutputWriter(ctx, 3, "Filename to store WireGuard configuration in, or 'stdout': ")
-	if err != nil {
-		return err
-	}
-	if shouldClose {
-		defer w.Close() // skipcq: GO-S2307
-	}
-
-	generateWgConf(data, state.LocalPrivate, w)
-
-	if shouldClose {
-		filename := w.(*os.File).Name()
-		fmt.Fprintf(io.Out, "Wrote WireGuard configuration to %s; load in your WireGuard client\n", filename)
-	}
-
-	return nil
+	return writePeerConfig(ctx, 3, newPeerConfig(state.Name, data, state.LocalPrivate))
 }
 
 func runWireguardRemove(ctx context.Context) error {
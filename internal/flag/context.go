@@ -2,6 +2,7 @@ package flag
 
 import (
 	"context"
+	"fmt"
 	"slices"
 	"time"
 
@@ -101,6 +102,34 @@ func GetDuration(ctx context.Context, name string) time.Duration {
 	}
 }
 
+// GetTime returns the value of the named string flag ctx carries parsed with
+// ParseTime, or the zero time when the flag is empty.
+func GetTime(ctx context.Context, name string) (time.Time, error) {
+	t, err := ParseTime(GetString(ctx, name), time.Now())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --%s: %w", name, err)
+	}
+	return t, nil
+}
+
+// ParseTime parses an RFC 3339 timestamp, a date (2006-01-02) or a duration
+// relative to now, e.g. "90m" for 90 minutes ago.
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("'%s' is neither a timestamp, a date nor a duration", value)
+}
+
 // GetBool returns the value of the named boolean flag ctx carries.
 func GetBool(ctx context.Context, name string) bool {
 	if v, err := FromContext(ctx).GetBool(name); err != nil {
@@ -136,11 +165,23 @@ func GetYes(ctx context.Context) bool {
 	return GetBool(ctx, flagnames.Yes)
 }
 
-// GetApp is shorthand for GetString(ctx, App).
+// GetApp is shorthand for GetString(ctx, App). It returns the first app of
+// commands accepting several apps.
 func GetApp(ctx context.Context) string {
+	if apps, err := FromContext(ctx).GetStringArray(flagnames.App); err == nil {
+		if len(apps) == 0 {
+			return ""
+		}
+		return apps[0]
+	}
 	return GetString(ctx, flagnames.App)
 }
 
+// GetApps returns the apps specified with the Apps flag.
+func GetApps(ctx context.Context) []string {
+	return GetStringArray(ctx, flagnames.App)
+}
+
 // GetAppConfigFilePath is shorthand for GetString(ctx, AppConfigFilePath).
 func GetAppConfigFilePath(ctx context.Context) string {
 	if path, err := FromContext(ctx).GetString(flagnames.AppConfigFilePath); err != nil {
@@ -0,0 +1,36 @@
+package flag
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		value     string
+		expected  time.Time
+		expectErr bool
+	}{
+		{value: "", expected: time.Time{}},
+		{value: "90m", expected: now.Add(-90 * time.Minute)},
+		{value: "2024-02-28T10:30:00Z", expected: time.Date(2024, 2, 28, 10, 30, 0, 0, time.UTC)},
+		{value: "2024-02-28", expected: time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{value: "yesterday", expectErr: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := ParseTime(tc.value, now)
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, tc.expected.Equal(got), "expected %s, got %s", tc.expected, got)
+		})
+	}
+}
@@ -341,6 +341,16 @@ func App() String {
 	}
 }
 
+// Apps returns an app string array flag for commands that work on several
+// apps at once.
+func Apps() StringArray {
+	return StringArray{
+		Name:        flagnames.App,
+		Shorthand:   "a",
+		Description: "Application name, can be specified multiple times",
+	}
+}
+
 // AppConfig returns an app config string flag.
 func AppConfig() String {
 	return String{
@@ -74,6 +74,40 @@ func GetErrorSuggestion(err error) string {
 	return ""
 }
 
+// ErrorExitCode is an error that makes the CLI exit with a specific exit code
+type ErrorExitCode interface {
+	error
+	ExitCode() int
+}
+
+// GetErrorExitCode returns the exit code carried by err, if any
+func GetErrorExitCode(err error) (int, bool) {
+	var ferr ErrorExitCode
+	if errors.As(err, &ferr) {
+		return ferr.ExitCode(), true
+	}
+	return 0, false
+}
+
+// ExitCodeErr makes the CLI exit with Code, e.g. to propagate the exit code of
+// a process that ran on a machine
+type ExitCodeErr struct {
+	Err  error
+	Code int
+}
+
+func (e ExitCodeErr) Error() string {
+	return e.Err.Error()
+}
+
+func (e ExitCodeErr) Unwrap() error {
+	return e.Err
+}
+
+func (e ExitCodeErr) ExitCode() int {
+	return e.Code
+}
+
 func PrintCLIOutput(err error) {
 	if err == nil {
 		return
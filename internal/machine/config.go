@@ -133,3 +133,9 @@ func MergeFiles(machineConf *fly.MachineConfig, files []*fly.File) {
 		}
 	}
 }
+
+// ConfigDiff returns a colorized diff between two machine configs, or an
+// empty string when they are equivalent.
+func ConfigDiff(ctx context.Context, original fly.MachineConfig, new fly.MachineConfig) string {
+	return configCompare(ctx, original, new)
+}
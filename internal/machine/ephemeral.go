@@ -17,6 +17,16 @@ import (
 	"github.com/superfly/flyctl/terminal"
 )
 
+// EphemeralExitErr is returned by LaunchEphemeral when the machine exited and
+// was destroyed before it was seen running.
+type EphemeralExitErr struct {
+	ExitCode int
+}
+
+func (e EphemeralExitErr) Error() string {
+	return fmt.Sprintf("machine exited unexpectedly with code %v", e.ExitCode)
+}
+
 type EphemeralInput struct {
 	LaunchInput fly.LaunchMachineInput
 	What        string
@@ -114,7 +124,7 @@ func checkMachineDestruction(ctx context.Context, machine *fly.Machine, firstErr
 		return true, errors.New("machine exited unexpectedly")
 	}
 
-	return true, fmt.Errorf("machine exited unexpectedly with code %v", exitCode)
+	return true, EphemeralExitErr{ExitCode: exitCode}
 }
 
 func makeCleanupFunc(ctx context.Context, machine *fly.Machine) func() {
@@ -127,9 +137,9 @@ func makeCleanupFunc(ctx context.Context, machine *fly.Machine) func() {
 	return func() {
 		const stopTimeout = 15 * time.Second
 
-		// FIXME: is there a reason we *need* to use context.Background here, instead of the normal context
-		// As far as I can tell, this is the only place in the codebase that does this
-		stopCtx, cancel := context.WithTimeout(ctx, stopTimeout)
+		// ctx is likely canceled already when the cleanup runs because of an
+		// interrupt, so don't inherit its cancellation.
+		stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stopTimeout)
 		stopCtx, cancel = ctrlc.HookCancelableContext(stopCtx, cancel)
 		defer cancel()
 
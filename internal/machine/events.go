@@ -0,0 +1,152 @@
+package machine
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	fly "github.com/superfly/fly-go"
+)
+
+// TimelineEvent is a machine event along with the machine it happened on.
+type TimelineEvent struct {
+	Time          time.Time `json:"time"`
+	MachineID     string    `json:"machine_id"`
+	MachineName   string    `json:"machine_name"`
+	Region        string    `json:"region"`
+	ProcessGroup  string    `json:"process_group"`
+	Type          string    `json:"type"`
+	Status        string    `json:"status"`
+	Source        string    `json:"source"`
+	ExitCode      *int      `json:"exit_code,omitempty"`
+	OOMKilled     bool      `json:"oom_killed,omitempty"`
+	RequestedStop bool      `json:"requested_stop,omitempty"`
+	Restarting    bool      `json:"restarting,omitempty"`
+	RestartCount  int       `json:"restart_count,omitempty"`
+}
+
+// Key identifies an event across repeated listings of the same machine.
+func (e TimelineEvent) Key() string {
+	return fmt.Sprintf("%s/%d/%s/%s", e.MachineID, e.Time.UnixMilli(), e.Type, e.Status)
+}
+
+// EventFilter selects timeline events. Zero values match everything.
+type EventFilter struct {
+	Types    []string
+	Sources  []string
+	ExitCode *int
+	Since    time.Time
+	Until    time.Time
+}
+
+func (f EventFilter) Match(e TimelineEvent) bool {
+	switch {
+	case len(f.Types) > 0 && !slices.Contains(f.Types, e.Type):
+		return false
+	case len(f.Sources) > 0 && !slices.Contains(f.Sources, e.Source):
+		return false
+	case f.ExitCode != nil && (e.ExitCode == nil || *e.ExitCode != *f.ExitCode):
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && e.Time.After(f.Until):
+		return false
+	}
+	return true
+}
+
+// NewTimelineEvent annotates an event of machine.
+func NewTimelineEvent(machine *fly.Machine, event *fly.MachineEvent) TimelineEvent {
+	e := TimelineEvent{
+		Time:         event.Time(),
+		MachineID:    machine.ID,
+		MachineName:  machine.Name,
+		Region:       machine.Region,
+		ProcessGroup: machine.ProcessGroup(),
+		Type:         event.Type,
+		Status:       event.Status,
+		Source:       event.Source,
+	}
+
+	if req := event.Request; req != nil {
+		e.RestartCount = req.RestartCount
+		if exitCode, err := req.GetExitCode(); err == nil {
+			e.ExitCode = &exitCode
+		}
+		if exit := req.ExitEvent; exit != nil {
+			e.OOMKilled = exit.OOMKilled
+			e.RequestedStop = exit.RequestedStop
+			e.Restarting = exit.Restarting
+		}
+	}
+
+	return e
+}
+
+// Timeline merges the events of machines into a single list sorted from
+// oldest to newest, keeping only the events matched by filter.
+func Timeline(machines []*fly.Machine, filter EventFilter) []TimelineEvent {
+	var timeline []TimelineEvent
+	for _, machine := range machines {
+		for _, event := range machine.Events {
+			if e := NewTimelineEvent(machine, event); filter.Match(e) {
+				timeline = append(timeline, e)
+			}
+		}
+	}
+
+	slices.SortStableFunc(timeline, func(a, b TimelineEvent) int {
+		if c := a.Time.Compare(b.Time); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.MachineID, b.MachineID)
+	})
+
+	return timeline
+}
+
+// LastExit returns the most recent exit event of machine, or nil if it never
+// exited.
+func LastExit(machine *fly.Machine) *TimelineEvent {
+	// Events are sorted from newest to oldest
+	for _, event := range machine.Events {
+		if event.Type == "exit" {
+			e := NewTimelineEvent(machine, event)
+			return &e
+		}
+	}
+	return nil
+}
+
+// IsConstantlyRestarting reports whether the main process of machine keeps
+// crashing and being restarted by the restart policy.
+func IsConstantlyRestarting(machine *fly.Machine) bool {
+	exit := LastExit(machine)
+	if exit == nil {
+		return false
+	}
+
+	return !exit.RequestedStop &&
+		exit.Restarting &&
+		exit.RestartCount > 1 &&
+		exit.ExitCode != nil && *exit.ExitCode != 0
+}
+
+// RestartLoopSummary explains why a machine is considered to be constantly
+// restarting, to be shown next to errors.
+func RestartLoopSummary(machine *fly.Machine) string {
+	exit := LastExit(machine)
+	if exit == nil {
+		return ""
+	}
+
+	summary := fmt.Sprintf("machine %s restarted %d times", machine.ID, exit.RestartCount)
+	if exit.ExitCode != nil {
+		summary += fmt.Sprintf(", last exit code %d", *exit.ExitCode)
+	}
+	if exit.OOMKilled {
+		summary += ", killed after running out of memory"
+	}
+	return summary + fmt.Sprintf(" at %s", exit.Time.UTC().Format(time.RFC3339))
+}
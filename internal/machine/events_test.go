@@ -0,0 +1,115 @@
+package machine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+)
+
+func TestTimeline(t *testing.T) {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) int64 { return base.Add(d).UnixMilli() }
+	exit := func(d time.Duration, code int) *fly.MachineEvent {
+		return &fly.MachineEvent{
+			Type:      "exit",
+			Source:    "flyd",
+			Timestamp: at(d),
+			Request:   &fly.MachineRequest{ExitEvent: &fly.MachineExitEvent{ExitCode: code}},
+		}
+	}
+
+	machines := []*fly.Machine{
+		{
+			ID: "m1",
+			Events: []*fly.MachineEvent{
+				exit(3*time.Minute, 1),
+				{Type: "start", Source: "flyd", Timestamp: at(time.Minute)},
+			},
+		},
+		{
+			ID: "m2",
+			Events: []*fly.MachineEvent{
+				exit(2*time.Minute, 0),
+				{Type: "start", Source: "user", Timestamp: at(time.Minute)},
+			},
+		},
+	}
+
+	ids := func(timeline []TimelineEvent) []string {
+		return lo.Map(timeline, func(e TimelineEvent, _ int) string { return e.MachineID + ":" + e.Type })
+	}
+
+	testcases := []struct {
+		name     string
+		filter   EventFilter
+		expected []string
+	}{
+		{
+			name:     "all events in order",
+			expected: []string{"m1:start", "m2:start", "m2:exit", "m1:exit"},
+		},
+		{
+			name:     "by type",
+			filter:   EventFilter{Types: []string{"start"}},
+			expected: []string{"m1:start", "m2:start"},
+		},
+		{
+			name:     "by source",
+			filter:   EventFilter{Sources: []string{"user"}},
+			expected: []string{"m2:start"},
+		},
+		{
+			name:     "by exit code",
+			filter:   EventFilter{ExitCode: lo.ToPtr(1)},
+			expected: []string{"m1:exit"},
+		},
+		{
+			name:     "by time range",
+			filter:   EventFilter{Since: base.Add(90 * time.Second), Until: base.Add(150 * time.Second)},
+			expected: []string{"m2:exit"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, ids(Timeline(machines, tc.filter)))
+		})
+	}
+}
+
+func TestIsConstantlyRestarting(t *testing.T) {
+	crashing := &fly.Machine{
+		ID: "m1",
+		Events: []*fly.MachineEvent{
+			{
+				Type: "exit",
+				Request: &fly.MachineRequest{
+					RestartCount: 3,
+					ExitEvent:    &fly.MachineExitEvent{ExitCode: 2, Restarting: true},
+				},
+			},
+		},
+	}
+	require.True(t, IsConstantlyRestarting(crashing))
+	require.Contains(t, RestartLoopSummary(crashing), "machine m1 restarted 3 times, last exit code 2")
+
+	stopped := &fly.Machine{
+		Events: []*fly.MachineEvent{
+			{
+				Type: "exit",
+				Request: &fly.MachineRequest{
+					RestartCount: 3,
+					ExitEvent:    &fly.MachineExitEvent{ExitCode: 2, Restarting: true, RequestedStop: true},
+				},
+			},
+		},
+	}
+	require.False(t, IsConstantlyRestarting(stopped))
+
+	// An exit event without request details used to panic
+	require.False(t, IsConstantlyRestarting(&fly.Machine{Events: []*fly.MachineEvent{{Type: "exit"}}}))
+	require.False(t, IsConstantlyRestarting(&fly.Machine{}))
+}
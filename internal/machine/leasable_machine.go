@@ -224,23 +224,7 @@ func (lm *leasableMachine) WaitForState(ctx context.Context, desiredState string
 }
 
 func (lm *leasableMachine) isConstantlyRestarting(machine *fly.Machine) bool {
-	var ev *fly.MachineEvent
-
-	for _, mev := range machine.Events {
-		if mev.Type == "exit" {
-			ev = mev
-			break
-		}
-	}
-
-	if ev == nil {
-		return false
-	}
-
-	return !ev.Request.ExitEvent.RequestedStop &&
-		ev.Request.ExitEvent.Restarting &&
-		ev.Request.RestartCount > 1 &&
-		ev.Request.ExitEvent.ExitCode != 0
+	return IsConstantlyRestarting(machine)
 }
 
 func (lm *leasableMachine) WaitForSmokeChecksToPass(ctx context.Context) error {
@@ -276,7 +260,7 @@ func (lm *leasableMachine) WaitForSmokeChecksToPass(ctx context.Context) error {
 
 		switch {
 		case lm.isConstantlyRestarting(machine):
-			return fmt.Errorf("the app appears to be crashing")
+			return fmt.Errorf("the app appears to be crashing: %s", RestartLoopSummary(machine))
 		default:
 			select {
 			case <-time.After(b.Duration()):
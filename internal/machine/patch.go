@@ -0,0 +1,51 @@
+package machine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	fly "github.com/superfly/fly-go"
+)
+
+// PatchConfig applies a patch document to a copy of the given machine config.
+// The document may either be a JSON Patch (RFC 6902), which is an array of
+// operations, or a JSON Merge Patch (RFC 7396), which is an object.
+func PatchConfig(orig *fly.MachineConfig, patch []byte) (*fly.MachineConfig, error) {
+	if orig == nil {
+		return nil, fmt.Errorf("can't patch an empty machine config")
+	}
+
+	origBytes, err := json.Marshal(orig)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding machine config: %w", err)
+	}
+
+	var patched []byte
+	switch trimmed := bytes.TrimSpace(patch); {
+	case len(trimmed) == 0:
+		return nil, fmt.Errorf("patch document is empty")
+	case trimmed[0] == '[':
+		ops, err := jsonpatch.DecodePatch(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON patch: %w", err)
+		}
+		if patched, err = ops.Apply(origBytes); err != nil {
+			return nil, fmt.Errorf("failed applying JSON patch: %w", err)
+		}
+	case trimmed[0] == '{':
+		if patched, err = jsonpatch.MergePatch(origBytes, trimmed); err != nil {
+			return nil, fmt.Errorf("failed applying JSON merge patch: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("patch document must be a JSON object (merge patch) or a JSON array (JSON patch)")
+	}
+
+	var conf fly.MachineConfig
+	if err := json.Unmarshal(patched, &conf); err != nil {
+		return nil, fmt.Errorf("patched machine config is invalid: %w", err)
+	}
+
+	return &conf, nil
+}
@@ -0,0 +1,84 @@
+package machine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+)
+
+func TestPatchConfig(t *testing.T) {
+	orig := &fly.MachineConfig{
+		Image:    "registry.fly.io/app:v1",
+		Env:      map[string]string{"FOO": "bar", "KEEP": "me"},
+		Metadata: map[string]string{"fly_process_group": "app"},
+		Guest: &fly.MachineGuest{
+			CPUKind:  "shared",
+			CPUs:     1,
+			MemoryMB: 256,
+		},
+	}
+
+	testcases := []struct {
+		name      string
+		patch     string
+		expect    func(*fly.MachineConfig)
+		expectErr bool
+	}{
+		{
+			name:  "merge patch adds and removes keys",
+			patch: `{"env": {"FOO": null, "NEW": "value"}, "metadata": {"team": "infra"}}`,
+			expect: func(c *fly.MachineConfig) {
+				c.Env = map[string]string{"KEEP": "me", "NEW": "value"}
+				c.Metadata = map[string]string{"fly_process_group": "app", "team": "infra"}
+			},
+		},
+		{
+			name:  "merge patch sets restart policy",
+			patch: `{"restart": {"policy": "on-failure", "max_retries": 3}}`,
+			expect: func(c *fly.MachineConfig) {
+				c.Restart = fly.MachineRestart{Policy: fly.MachineRestartPolicyOnFailure, MaxRetries: 3}
+			},
+		},
+		{
+			name:  "json patch appends kernel arg",
+			patch: `[{"op": "add", "path": "/guest/kernel_args", "value": ["quiet"]}]`,
+			expect: func(c *fly.MachineConfig) {
+				c.Guest.KernelArgs = []string{"quiet"}
+			},
+		},
+		{
+			name:      "json patch test failure",
+			patch:     `[{"op": "test", "path": "/image", "value": "other"}]`,
+			expectErr: true,
+		},
+		{
+			name:      "empty document",
+			patch:     "  ",
+			expectErr: true,
+		},
+		{
+			name:      "not json",
+			patch:     "image = 'foo'",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			patched, err := PatchConfig(orig, []byte(tc.patch))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			expected := CloneConfig(orig)
+			tc.expect(expected)
+			require.Equal(t, expected, patched)
+		})
+	}
+
+	// the original config must never be modified
+	require.Equal(t, map[string]string{"FOO": "bar", "KEEP": "me"}, orig.Env)
+}
@@ -0,0 +1,68 @@
+// Package qrcode prints QR codes, such as WireGuard configurations, in a
+// terminal. Encoding is done by rsc.io/qr.
+package qrcode
+
+import (
+	"io"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// Code is an encoded QR code.
+type Code struct {
+	code *qr.Code
+}
+
+// Encode encodes data at error correction level L, which keeps codes for
+// payloads as large as a WireGuard configuration small enough for a
+// terminal.
+func Encode(data []byte) (*Code, error) {
+	code, err := qr.Encode(string(data), qr.L)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Code{code: code}, nil
+}
+
+// Size is the number of modules on each side of the code.
+func (c *Code) Size() int {
+	return c.code.Size
+}
+
+// quietZone is the light border required around a code.
+const quietZone = 4
+
+// WriteTerminal renders c with Unicode half blocks, two rows per line. It
+// draws light modules and leaves dark ones blank, so it reads correctly on
+// the usual light-on-dark terminal.
+func (c *Code) WriteTerminal(w io.Writer) error {
+	// Black is false outside of the code, which draws the quiet zone
+	dark := func(x, y int) bool {
+		return c.code.Black(x-quietZone, y-quietZone)
+	}
+
+	size := c.Size() + 2*quietZone
+
+	var sb strings.Builder
+	for y := 0; y < size; y += 2 {
+		for x := 0; x < size; x++ {
+			top, bottom := !dark(x, y), y+1 < size && !dark(x, y+1)
+			switch {
+			case top && bottom:
+				sb.WriteString("█")
+			case top:
+				sb.WriteString("▀")
+			case bottom:
+				sb.WriteString("▄")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,54 @@
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	cases := []struct {
+		size    int
+		version int
+	}{
+		{1, 1},
+		{17, 1},
+		{18, 2},
+		{271, 10},
+		{858, 20},
+	}
+
+	for _, tc := range cases {
+		c, err := Encode(bytes.Repeat([]byte("x"), tc.size))
+		require.NoError(t, err)
+		assert.Equal(t, tc.version*4+17, c.Size(), "%d bytes", tc.size)
+	}
+
+	_, err := Encode(bytes.Repeat([]byte("x"), 3000))
+	assert.Error(t, err)
+}
+
+func TestWriteTerminal(t *testing.T) {
+	c, err := Encode([]byte("fly"))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.WriteTerminal(&buf))
+
+	size := c.Size() + 2*quietZone
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, (size+1)/2)
+	for _, line := range lines {
+		assert.Equal(t, size, len([]rune(line)))
+	}
+
+	// the quiet zone is drawn as light modules
+	assert.Equal(t, strings.Repeat("█", size), lines[0])
+
+	// the first two rows of the top left finder pattern: a dark edge above
+	// the light ring around its center
+	assert.Equal(t, "▄▄▄▄▄", string([]rune(lines[2])[quietZone+1:quietZone+6]))
+}
@@ -0,0 +1,258 @@
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/samber/lo"
+
+	"github.com/superfly/flyctl/logs"
+)
+
+// LogFormats are the formats NewLogWriter accepts besides Go templates.
+var LogFormats = []string{"text", "json", "ndjson", "logfmt", "csv"}
+
+// LogWriter writes a log entry in some format.
+type LogWriter func(entry logs.LogEntry) error
+
+// NewLogWriter returns a LogWriter writing to w in format, which is one of
+// LogFormats or a Go template such as '{{.Timestamp}} {{.Message}}'.
+// Templates are executed with a LogTemplateData and followed by a newline.
+func NewLogWriter(w io.Writer, format string, opts ...LogOption) (LogWriter, error) {
+	options := &LogOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	switch format {
+	case "", "text":
+		return func(entry logs.LogEntry) error {
+			return LogEntry(w, entry, opts...)
+		}, nil
+	case "json":
+		return func(entry logs.LogEntry) error {
+			return JSON(w, entry)
+		}, nil
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		return func(entry logs.LogEntry) error {
+			fields := logFields(entry, options, false)
+			obj := make(map[string]any, len(fields))
+			for _, f := range fields {
+				if !f.empty() {
+					obj[f.key] = f.value
+				}
+			}
+			return enc.Encode(obj)
+		}, nil
+	case "logfmt":
+		return func(entry logs.LogEntry) error {
+			var pairs []string
+			for _, f := range logFields(entry, options, false) {
+				if !f.empty() {
+					pairs = append(pairs, f.key+"="+logfmtValue(f.value))
+				}
+			}
+			_, err := fmt.Fprintln(w, strings.Join(pairs, " "))
+			return err
+		}, nil
+	case "csv":
+		return newCSVLogWriter(w, options), nil
+	}
+
+	if !strings.Contains(format, "{{") {
+		return nil, fmt.Errorf("unknown log format '%s', expected one of %s or a Go template", format, strings.Join(LogFormats, ", "))
+	}
+
+	tmpl, err := template.New("log").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log format template: %w", err)
+	}
+
+	return func(entry logs.LogEntry) error {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, LogTemplateData{
+			LogEntry: entry,
+			Fields:   parseMessage(entry.Message, options),
+		}); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+
+		_, err := buf.WriteTo(w)
+		return err
+	}, nil
+}
+
+// LogTemplateData is what log format templates are executed with.
+type LogTemplateData struct {
+	logs.LogEntry
+	// Fields of the message when ParseJSONMessage is used and the message is
+	// a JSON object.
+	Fields map[string]any
+}
+
+func newCSVLogWriter(w io.Writer, options *LogOptions) LogWriter {
+	var (
+		cw     = csv.NewWriter(w)
+		header bool
+	)
+
+	return func(entry logs.LogEntry) error {
+		// Every row needs the columns of the header
+		fields := logFields(entry, options, true)
+
+		if !header {
+			header = true
+			if err := cw.Write(lo.Map(fields, func(f logField, _ int) string { return f.key })); err != nil {
+				return err
+			}
+		}
+
+		if err := cw.Write(lo.Map(fields, func(f logField, _ int) string {
+			if f.empty() {
+				return ""
+			}
+			return fmt.Sprint(f.value)
+		})); err != nil {
+			return err
+		}
+
+		// Flush every entry, logs are streamed
+		cw.Flush()
+		return cw.Error()
+	}
+}
+
+type logField struct {
+	key   string
+	value any
+}
+
+func (f logField) empty() bool {
+	switch v := f.value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case int:
+		return v == 0
+	}
+	return false
+}
+
+// logFields flattens entry into a fixed list of fields followed by the
+// selected fields of its message. With onlySelected, message fields are
+// limited to options.MessageKeys, so every entry has the same fields.
+func logFields(entry logs.LogEntry, options *LogOptions, onlySelected bool) []logField {
+	fields := []logField{
+		{"timestamp", entry.Timestamp},
+		{"app", entry.App},
+		{"region", entry.Region},
+		{"instance", entry.Instance},
+		{"level", entry.Level},
+		{"provider", entry.Meta.Event.Provider},
+		{"message", entry.Message},
+		{"http.request.id", entry.Meta.HTTP.Request.ID},
+		{"http.request.method", entry.Meta.HTTP.Request.Method},
+		{"http.response.status", entry.Meta.HTTP.Response.StatusCode},
+		{"url", entry.Meta.URL.Full},
+		{"error.code", entry.Meta.Error.Code},
+		{"error.message", entry.Meta.Error.Message},
+	}
+
+	if !options.ParseJSON {
+		return fields
+	}
+
+	parsed := parseMessage(entry.Message, options)
+
+	keys := options.MessageKeys
+	if !onlySelected {
+		keys = messageKeys(parsed, options)
+	}
+	for _, key := range keys {
+		var value any
+		if v, ok := parsed[key]; ok {
+			value = messageValue(v)
+		}
+		fields = append(fields, logField{"msg." + key, value})
+	}
+	return fields
+}
+
+// parseMessage returns the fields of a JSON object message, or nil.
+func parseMessage(message string, options *LogOptions) map[string]any {
+	if !options.ParseJSON || !strings.HasPrefix(strings.TrimSpace(message), "{") {
+		return nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(message), &fields); err != nil {
+		return nil
+	}
+	return fields
+}
+
+// messageKeys returns the keys of a parsed message to show, the selected ones
+// or all of them in alphabetical order.
+func messageKeys(parsed map[string]any, options *LogOptions) []string {
+	if len(options.MessageKeys) > 0 {
+		return options.MessageKeys
+	}
+
+	keys := lo.Keys(parsed)
+	slices.Sort(keys)
+	return keys
+}
+
+// messageValue returns v as a scalar, encoding objects and arrays as JSON.
+func messageValue(v any) any {
+	switch v.(type) {
+	case map[string]any, []any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return v
+}
+
+// formatMessage renders the selected fields of a JSON object message as
+// key=value pairs, or returns the message as is.
+func formatMessage(message string, options *LogOptions) string {
+	parsed := parseMessage(message, options)
+	if parsed == nil {
+		return message
+	}
+
+	var pairs []string
+	for _, key := range messageKeys(parsed, options) {
+		if v, ok := parsed[key]; ok {
+			pairs = append(pairs, key+"="+logfmtValue(messageValue(v)))
+		}
+	}
+	return strings.Join(pairs, " ")
+}
+
+func logfmtValue(v any) string {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	if s == "" || strings.ContainsAny(s, " =\"\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
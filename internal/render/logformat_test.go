@@ -0,0 +1,176 @@
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/logs"
+)
+
+func testLogEntry() logs.LogEntry {
+	entry := logs.LogEntry{
+		App:       "web",
+		Level:     "info",
+		Instance:  "148e21f1",
+		Region:    "ord",
+		Timestamp: "2024-03-01T12:00:00Z",
+		Message:   `{"msg":"request done","duration_ms":12.5,"user":{"id":1}}`,
+	}
+	entry.Meta.Event.Provider = "app"
+	entry.Meta.HTTP.Response.StatusCode = 200
+	return entry
+}
+
+func TestNewLogWriter(t *testing.T) {
+	cases := []struct {
+		name     string
+		format   string
+		opts     []LogOption
+		expected string
+	}{
+		{
+			name:     "logfmt",
+			format:   "logfmt",
+			expected: `timestamp=2024-03-01T12:00:00Z app=web region=ord instance=148e21f1 level=info provider=app message="{\"msg\":\"request done\",\"duration_ms\":12.5,\"user\":{\"id\":1}}" http.response.status=200` + "\n",
+		},
+		{
+			name:     "logfmt with message keys",
+			format:   "logfmt",
+			opts:     []LogOption{ParseJSONMessage("msg", "missing")},
+			expected: `timestamp=2024-03-01T12:00:00Z app=web region=ord instance=148e21f1 level=info provider=app message="{\"msg\":\"request done\",\"duration_ms\":12.5,\"user\":{\"id\":1}}" http.response.status=200 msg.msg="request done"` + "\n",
+		},
+		{
+			name:     "ndjson",
+			format:   "ndjson",
+			opts:     []LogOption{ParseJSONMessage("duration_ms", "user")},
+			expected: `{"app":"web","http.response.status":200,"instance":"148e21f1","level":"info","message":"{\"msg\":\"request done\",\"duration_ms\":12.5,\"user\":{\"id\":1}}","msg.duration_ms":12.5,"msg.user":"{\"id\":1}","provider":"app","region":"ord","timestamp":"2024-03-01T12:00:00Z"}` + "\n",
+		},
+		{
+			name:   "csv",
+			format: "csv",
+			opts:   []LogOption{ParseJSONMessage("msg")},
+			expected: "timestamp,app,region,instance,level,provider,message,http.request.id,http.request.method,http.response.status,url,error.code,error.message,msg.msg\n" +
+				`2024-03-01T12:00:00Z,web,ord,148e21f1,info,app,"{""msg"":""request done"",""duration_ms"":12.5,""user"":{""id"":1}}",,,200,,,,request done` + "\n" +
+				`2024-03-01T12:00:00Z,web,ord,148e21f1,info,app,"{""msg"":""request done"",""duration_ms"":12.5,""user"":{""id"":1}}",,,200,,,,request done` + "\n",
+		},
+		{
+			name:     "template",
+			format:   `{{.App}} {{.Level}}: {{.Message}}`,
+			expected: "web info: " + testLogEntry().Message + "\n",
+		},
+		{
+			name:     "template with fields",
+			format:   `{{.Timestamp}} {{index .Fields "msg"}} in {{.Fields.duration_ms}}ms`,
+			opts:     []LogOption{ParseJSONMessage()},
+			expected: "2024-03-01T12:00:00Z request done in 12.5ms\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			write, err := NewLogWriter(&buf, tc.format, tc.opts...)
+			require.NoError(t, err)
+
+			require.NoError(t, write(testLogEntry()))
+			if tc.format == "csv" {
+				require.NoError(t, write(testLogEntry()))
+			}
+			require.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
+
+func TestNewLogWriter_CSVColumns(t *testing.T) {
+	entries := []string{
+		`{"msg":"one","user":"a"}`,
+		`{"msg":"two","status":500}`,
+		"not json",
+	}
+
+	const header = "timestamp,app,region,instance,level,provider,message,http.request.id,http.request.method,http.response.status,url,error.code,error.message"
+
+	cases := []struct {
+		name   string
+		opts   []LogOption
+		header string
+		msgs   []string // the msg.* columns of each row
+	}{
+		{
+			name:   "message keys",
+			opts:   []LogOption{ParseJSONMessage("msg", "status")},
+			header: header + ",msg.msg,msg.status",
+			msgs:   []string{"one,", "two,500", ","},
+		},
+		{
+			name:   "no message keys",
+			opts:   []LogOption{ParseJSONMessage()},
+			header: header,
+			msgs:   []string{"", "", ""},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			write, err := NewLogWriter(&buf, "csv", tc.opts...)
+			require.NoError(t, err)
+
+			for _, message := range entries {
+				entry := testLogEntry()
+				entry.Message = message
+				require.NoError(t, write(entry))
+			}
+
+			records, err := csv.NewReader(&buf).ReadAll()
+			require.NoError(t, err, "every row has as many columns as the header")
+			require.Len(t, records, len(entries)+1)
+			require.Equal(t, tc.header, strings.Join(records[0], ","))
+
+			for i, record := range records[1:] {
+				require.Equal(t, entries[i], record[6])
+				require.Equal(t, tc.msgs[i], strings.Join(record[13:], ","))
+			}
+		})
+	}
+}
+
+func TestNewLogWriter_Invalid(t *testing.T) {
+	_, err := NewLogWriter(&bytes.Buffer{}, "yaml")
+	require.ErrorContains(t, err, "unknown log format 'yaml'")
+
+	_, err = NewLogWriter(&bytes.Buffer{}, "{{.Message")
+	require.ErrorContains(t, err, "invalid log format template")
+}
+
+func TestLogEntry_ParseJSONMessage(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, LogEntry(&buf, testLogEntry(), NoColor(), HideAllocID(), HideRegion(), ParseJSONMessage()))
+	require.Equal(t, `2024-03-01T12:00:00Z app[148e21f1] ord [info]response.status=200 duration_ms=12.5 msg="request done" user="{\"id\":1}"`+"\n", buf.String())
+
+	buf.Reset()
+	entry := testLogEntry()
+	entry.Message = "not json"
+	require.NoError(t, LogEntry(&buf, entry, NoColor(), HideAllocID(), HideRegion(), ParseJSONMessage("msg")))
+	require.Contains(t, buf.String(), "not json")
+}
+
+func TestShowAppNames(t *testing.T) {
+	apps := []string{"web", "api", "worker", "db", "cache", "queue"}
+
+	var options LogOptions
+	ShowAppNames(apps)(&options)
+
+	require.Equal(t, len("worker"), options.AppNameWidth)
+
+	seen := map[uint]string{}
+	for _, app := range apps {
+		color := uint(options.AppColors[app])
+		require.NotContains(t, seen, color, "%s has the same colour as %s", app, seen[color])
+		seen[color] = app
+	}
+}
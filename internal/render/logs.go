@@ -17,6 +17,11 @@ type LogOptions struct {
 	RemoveNewlines bool
 	HideRegion     bool
 	HideAllocID    bool
+	AppNameWidth   int
+	AppColors      map[string]aurora.Color
+	NoColor        bool
+	ParseJSON      bool
+	MessageKeys    []string
 }
 
 // LogOption is a func type that returns a LogOption.
@@ -43,11 +48,41 @@ func HideAllocID() LogOption {
 	}
 }
 
+// ShowAppNames prefixes the log output with the colour-coded app name, padded
+// to the longest of apps. Colours are assigned in the order of apps, so that
+// apps only share a colour when there are more apps than colours.
+func ShowAppNames(apps []string) LogOption {
+	return func(o *LogOptions) {
+		o.AppColors = make(map[string]aurora.Color, len(apps))
+		for i, app := range apps {
+			o.AppNameWidth = max(o.AppNameWidth, len(app))
+			o.AppColors[app] = appColors[i%len(appColors)] | aurora.BoldFm
+		}
+	}
+}
+
+// NoColor renders the log output without colors, e.g. for writing to files.
+func NoColor() LogOption {
+	return func(o *LogOptions) {
+		o.NoColor = true
+	}
+}
+
+// ParseJSONMessage parses messages that are JSON objects and shows the given
+// keys of them, or all keys when none are given.
+func ParseJSONMessage(keys ...string) LogOption {
+	return func(o *LogOptions) {
+		o.ParseJSON = true
+		o.MessageKeys = keys
+	}
+}
+
 func LogEntry(w io.Writer, entry logs.LogEntry, opts ...LogOption) (err error) {
 	options := &LogOptions{}
 	for _, opt := range opts {
 		opt(options)
 	}
+	au := aurora.NewAurora(!options.NoColor)
 
 	var ts time.Time
 	if ts, err = time.Parse(time.RFC3339Nano, entry.Timestamp); err != nil {
@@ -56,6 +91,10 @@ func LogEntry(w io.Writer, entry logs.LogEntry, opts ...LogOption) (err error) {
 		return
 	}
 
+	if options.AppNameWidth > 0 {
+		fmt.Fprintf(w, "%s ", au.Colorize(fmt.Sprintf("%-*s", options.AppNameWidth, entry.App), options.AppColors[entry.App]))
+	}
+
 	if !options.HideAllocID {
 		if entry.Meta.Event.Provider != "" {
 			if entry.Instance != "" {
@@ -70,11 +109,11 @@ func LogEntry(w io.Writer, entry logs.LogEntry, opts ...LogOption) (err error) {
 	}
 
 	if !options.HideRegion {
-		fmt.Fprintf(w, "%s ", aurora.Green(entry.Region))
+		fmt.Fprintf(w, "%s ", au.Green(entry.Region))
 	}
 
 	var buf bytes.Buffer
-	fmt.Fprintf(&buf, "%s ", aurora.Faint(format.Time(ts)))
+	fmt.Fprintf(&buf, "%s ", au.Faint(format.Time(ts)))
 
 	if entry.Meta.Event.Provider != "" {
 		if entry.Instance != "" {
@@ -86,17 +125,17 @@ func LogEntry(w io.Writer, entry logs.LogEntry, opts ...LogOption) (err error) {
 		fmt.Fprintf(&buf, "%s", entry.Instance)
 	}
 
-	fmt.Fprintf(&buf, " %s [%s]", aurora.Green(entry.Region), aurora.Colorize(entry.Level, levelColor(entry.Level)))
+	fmt.Fprintf(&buf, " %s [%s]", au.Green(entry.Region), au.Colorize(entry.Level, levelColor(entry.Level)))
 
-	printFieldIfPresent(&buf, "error.code", entry.Meta.Error.Code)
-	hadErrorMsg := printFieldIfPresent(w, "error.message", entry.Meta.Error.Message)
-	printFieldIfPresent(&buf, "request.method", entry.Meta.HTTP.Request.Method)
-	printFieldIfPresent(&buf, "request.url", entry.Meta.URL.Full)
-	printFieldIfPresent(&buf, "request.id", entry.Meta.HTTP.Request.ID)
-	printFieldIfPresent(&buf, "response.status", entry.Meta.HTTP.Response.StatusCode)
+	printFieldIfPresent(au, &buf, "error.code", entry.Meta.Error.Code)
+	hadErrorMsg := printFieldIfPresent(au, w, "error.message", entry.Meta.Error.Message)
+	printFieldIfPresent(au, &buf, "request.method", entry.Meta.HTTP.Request.Method)
+	printFieldIfPresent(au, &buf, "request.url", entry.Meta.URL.Full)
+	printFieldIfPresent(au, &buf, "request.id", entry.Meta.HTTP.Request.ID)
+	printFieldIfPresent(au, &buf, "response.status", entry.Meta.HTTP.Response.StatusCode)
 
 	if !hadErrorMsg {
-		buf.Write([]byte(entry.Message))
+		buf.WriteString(formatMessage(entry.Message, options))
 	}
 
 	buf.WriteByte('\n')
@@ -105,17 +144,17 @@ func LogEntry(w io.Writer, entry logs.LogEntry, opts ...LogOption) (err error) {
 	return err
 }
 
-func printFieldIfPresent(w io.Writer, name string, value interface{}) (present bool) {
+func printFieldIfPresent(au aurora.Aurora, w io.Writer, name string, value interface{}) (present bool) {
 	switch v := value.(type) {
 	case string:
 		if v != "" {
-			fmt.Fprintf(w, `%s"%s" `, aurora.Faint(name+"="), v)
+			fmt.Fprintf(w, `%s"%s" `, au.Faint(name+"="), v)
 
 			present = true
 		}
 	case int:
 		if v > 0 {
-			fmt.Fprintf(w, "%s%d ", aurora.Faint(name+"="), v)
+			fmt.Fprintf(w, "%s%d ", au.Faint(name+"="), v)
 
 			present = true
 		}
@@ -124,6 +163,15 @@ func printFieldIfPresent(w io.Writer, name string, value interface{}) (present b
 	return
 }
 
+var appColors = []aurora.Color{
+	aurora.CyanFg,
+	aurora.MagentaFg,
+	aurora.YellowFg,
+	aurora.GreenFg,
+	aurora.BlueFg,
+	aurora.RedFg,
+}
+
 func levelColor(level string) aurora.Color {
 	switch level {
 	default:
@@ -0,0 +1,149 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxAlertSamples is how many of the matching entries an alert carries.
+const maxAlertSamples = 20
+
+// Alert is raised when more entries than the threshold matched an alert rule
+// within the window.
+type Alert struct {
+	Rule      string        `json:"rule"`
+	Count     int           `json:"count"`
+	Threshold int           `json:"threshold"`
+	Window    time.Duration `json:"window"`
+	FiredAt   time.Time     `json:"fired_at"`
+	// Samples are the most recent matching entries.
+	Samples []LogEntry `json:"samples"`
+}
+
+// AlertRule configures an Alerter.
+type AlertRule struct {
+	// Query is shown in alerts, Match does the actual matching.
+	Query     string
+	Match     Filter
+	Threshold int
+	Window    time.Duration
+	// Debounce is how long to wait after an alert before raising another.
+	Debounce time.Duration
+	// NotBefore ignores entries logged before it, like the recent entries
+	// replayed when tailing starts.
+	NotBefore time.Time
+}
+
+// Alerter counts the entries matching a rule in a sliding window.
+type Alerter struct {
+	rule      AlertRule
+	matches   []LogEntry
+	times     []time.Time
+	lastFired time.Time
+}
+
+func NewAlerter(rule AlertRule) *Alerter {
+	return &Alerter{rule: rule}
+}
+
+// Observe counts entry when it matches and returns an alert when the
+// threshold is reached and no alert was raised within the debounce period.
+// The window is based on the timestamps of entries, now on the clock.
+func (a *Alerter) Observe(entry LogEntry, now time.Time) (Alert, bool) {
+	if IsMarker(entry) || (a.rule.Match != nil && !a.rule.Match(entry)) {
+		return Alert{}, false
+	}
+
+	ts, err := entry.Time()
+	if err != nil {
+		ts = now
+	}
+	if ts.Before(a.rule.NotBefore) {
+		return Alert{}, false
+	}
+
+	a.matches = append(a.matches, entry)
+	a.times = append(a.times, ts)
+
+	// Drop the matches that slid out of the window
+	var expired int
+	for expired < len(a.times) && ts.Sub(a.times[expired]) >= a.rule.Window {
+		expired++
+	}
+	a.matches = a.matches[expired:]
+	a.times = a.times[expired:]
+
+	switch {
+	case len(a.matches) < a.rule.Threshold:
+		return Alert{}, false
+	case !a.lastFired.IsZero() && now.Sub(a.lastFired) < a.rule.Debounce:
+		return Alert{}, false
+	}
+	a.lastFired = now
+
+	samples := a.matches
+	if len(samples) > maxAlertSamples {
+		samples = samples[len(samples)-maxAlertSamples:]
+	}
+
+	alert := Alert{
+		Rule:      a.rule.Query,
+		Count:     len(a.matches),
+		Threshold: a.rule.Threshold,
+		Window:    a.rule.Window,
+		FiredAt:   now,
+		Samples:   append([]LogEntry(nil), samples...),
+	}
+
+	// Start counting again, so a single burst raises a single alert
+	a.matches = nil
+	a.times = nil
+
+	return alert, true
+}
+
+// ParseThreshold parses thresholds like 10/1m, meaning 10 entries within a
+// minute. The window defaults to a minute.
+func ParseThreshold(s string) (count int, window time.Duration, err error) {
+	countStr, windowStr, hasWindow := strings.Cut(s, "/")
+
+	if count, err = strconv.Atoi(strings.TrimSpace(countStr)); err != nil || count < 1 {
+		return 0, 0, fmt.Errorf("invalid threshold '%s': count must be a positive number", s)
+	}
+
+	window = time.Minute
+	if hasWindow {
+		if window, err = time.ParseDuration(strings.TrimSpace(windowStr)); err != nil || window <= 0 {
+			return 0, 0, fmt.Errorf("invalid threshold '%s': window must be a positive duration like 1m", s)
+		}
+	}
+
+	return count, window, nil
+}
+
+// WatchAlerts passes the entries of in through as they are and calls notify
+// in the background with every alert alerter raises.
+func WatchAlerts(ctx context.Context, in <-chan LogEntry, alerter *Alerter, notify func(context.Context, Alert)) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+
+		for entry := range in {
+			if alert, ok := alerter.Observe(entry, time.Now()); ok {
+				go notify(ctx, alert)
+			}
+
+			select {
+			case out <- entry:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
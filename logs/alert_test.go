@@ -0,0 +1,144 @@
+package logs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseThreshold(t *testing.T) {
+	cases := []struct {
+		in     string
+		count  int
+		window time.Duration
+		err    bool
+	}{
+		{in: "10/1m", count: 10, window: time.Minute},
+		{in: "3/30s", count: 3, window: 30 * time.Second},
+		{in: "5", count: 5, window: time.Minute},
+		{in: "0/1m", err: true},
+		{in: "x/1m", err: true},
+		{in: "10/soon", err: true},
+		{in: "10/-1m", err: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			count, window, err := ParseThreshold(tc.in)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.count, count)
+			require.Equal(t, tc.window, window)
+		})
+	}
+}
+
+func TestAlerter(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration, level string) LogEntry {
+		return LogEntry{Level: level, Timestamp: start.Add(d).Format(time.RFC3339Nano)}
+	}
+
+	alerter := NewAlerter(AlertRule{
+		Query:     "level=error",
+		Match:     func(e LogEntry) bool { return e.Level == "error" },
+		Threshold: 3,
+		Window:    time.Minute,
+		Debounce:  5 * time.Minute,
+	})
+
+	observe := func(d time.Duration, level string) bool {
+		_, ok := alerter.Observe(at(d, level), start.Add(d))
+		return ok
+	}
+
+	require.False(t, observe(0, "error"))
+	require.False(t, observe(10*time.Second, "info"))
+	require.False(t, observe(20*time.Second, "error"))
+	// The first error slid out of the window
+	require.False(t, observe(70*time.Second, "error"))
+
+	alert, ok := alerter.Observe(at(75*time.Second, "error"), start.Add(75*time.Second))
+	require.True(t, ok)
+	require.Equal(t, 3, alert.Count)
+	require.Equal(t, "level=error", alert.Rule)
+	require.Len(t, alert.Samples, 3)
+
+	// Debounced
+	for i := 0; i < 3; i++ {
+		require.False(t, observe(80*time.Second, "error"))
+	}
+
+	require.False(t, observe(7*time.Minute, "error"))
+	require.False(t, observe(7*time.Minute, "error"))
+	require.True(t, observe(7*time.Minute, "error"))
+}
+
+func TestAlerter_NotBefore(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) LogEntry {
+		return LogEntry{Level: "error", Timestamp: start.Add(d).Format(time.RFC3339Nano)}
+	}
+
+	alerter := NewAlerter(AlertRule{
+		Threshold: 2,
+		Window:    time.Minute,
+		NotBefore: start,
+	})
+
+	// Replayed entries logged before the start don't count
+	for _, d := range []time.Duration{-30 * time.Second, -20 * time.Second, -10 * time.Second} {
+		_, ok := alerter.Observe(at(d), start)
+		require.False(t, ok)
+	}
+
+	_, ok := alerter.Observe(at(time.Second), start.Add(time.Second))
+	require.False(t, ok)
+	_, ok = alerter.Observe(at(2*time.Second), start.Add(2*time.Second))
+	require.True(t, ok)
+}
+
+func TestAlerter_IgnoresMarkers(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	alerter := NewAlerter(AlertRule{Threshold: 1, Window: time.Minute})
+
+	marker := markerEntry(&LogOptions{AppName: "app"}, "warn", "Log stream interrupted")
+	marker.Timestamp = now.Format(time.RFC3339Nano)
+
+	_, ok := alerter.Observe(marker, now)
+	require.False(t, ok)
+}
+
+func TestWatchAlerts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c := make(chan LogEntry, 3)
+	c <- LogEntry{Level: "error", Timestamp: "2024-03-01T12:00:00Z"}
+	c <- LogEntry{Level: "info", Timestamp: "2024-03-01T12:00:01Z"}
+	c <- LogEntry{Level: "error", Timestamp: "2024-03-01T12:00:02Z"}
+	close(c)
+
+	alerts := make(chan Alert, 1)
+	entries := WatchAlerts(ctx, c, NewAlerter(AlertRule{
+		Match:     func(e LogEntry) bool { return e.Level == "error" },
+		Threshold: 2,
+		Window:    time.Minute,
+	}), func(_ context.Context, alert Alert) {
+		alerts <- alert
+	})
+
+	var levels []string
+	for e := range entries {
+		levels = append(levels, e.Level)
+	}
+	require.Equal(t, []string{"error", "info", "error"}, levels)
+
+	alert := <-alerts
+	require.Equal(t, 2, alert.Count)
+}
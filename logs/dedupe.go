@@ -0,0 +1,56 @@
+package logs
+
+import "hash/fnv"
+
+// dedupeCapacity is how many recent entries are remembered to detect
+// duplicates. Streams overlap for a few seconds at most.
+const dedupeCapacity = 4096
+
+type entryKey struct {
+	instance  string
+	timestamp string
+	message   uint64
+}
+
+func keyOf(entry LogEntry) entryKey {
+	h := fnv.New64a()
+	h.Write([]byte(entry.Message))
+
+	return entryKey{
+		instance:  entry.Instance,
+		timestamp: entry.Timestamp,
+		message:   h.Sum64(),
+	}
+}
+
+// Dedupe remembers the most recent entries it was shown, identifying them by
+// instance, timestamp and message.
+type Dedupe struct {
+	seen  map[entryKey]struct{}
+	order []entryKey
+	next  int
+}
+
+// NewDedupe returns an empty Dedupe.
+func NewDedupe() *Dedupe {
+	return &Dedupe{seen: make(map[entryKey]struct{}, dedupeCapacity)}
+}
+
+// Seen reports whether entry was already shown, remembering it otherwise.
+func (d *Dedupe) Seen(entry LogEntry) bool {
+	key := keyOf(entry)
+	if _, ok := d.seen[key]; ok {
+		return true
+	}
+
+	if len(d.order) < dedupeCapacity {
+		d.order = append(d.order, key)
+	} else {
+		delete(d.seen, d.order[d.next])
+		d.order[d.next] = key
+		d.next = (d.next + 1) % dedupeCapacity
+	}
+	d.seen[key] = struct{}{}
+
+	return false
+}
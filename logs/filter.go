@@ -0,0 +1,470 @@
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Filter reports whether a log entry should be kept.
+type Filter func(entry LogEntry) bool
+
+// FilterOptions are the client side filters of `fly logs`. All the options
+// that are set must match for an entry to be kept.
+type FilterOptions struct {
+	// Level is the minimum level, e.g. "warn" keeps warnings and errors.
+	Level string
+	// Grep is a regular expression the message must match.
+	Grep string
+	// ProcessGroup is the process group of the machine that logged the entry.
+	ProcessGroup string
+	// Status is an HTTP response status, a class like "5xx" or a comparison
+	// like ">=400".
+	Status string
+	Since  time.Time
+	Until  time.Time
+	// Query is an expression combining conditions with and, or, not and
+	// parentheses, e.g. `level>=warn and (status>=500 or msg~"timeout")`.
+	Query string
+
+	// ProcessGroupOf returns the process group of an instance. It's required
+	// to filter by process group.
+	ProcessGroupOf func(instance string) string
+}
+
+// NewFilter compiles opts into a Filter. It returns nil when opts don't
+// filter anything.
+func NewFilter(opts FilterOptions) (Filter, error) {
+	var conds []Filter
+
+	add := func(field, op, value string) error {
+		cond, err := newCondition(field, op, value, opts.ProcessGroupOf)
+		if err != nil {
+			return err
+		}
+		conds = append(conds, cond)
+		return nil
+	}
+
+	if opts.Level != "" {
+		if err := add("level", ">=", opts.Level); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Grep != "" {
+		if err := add("message", "~", opts.Grep); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ProcessGroup != "" {
+		if err := add("group", "=", opts.ProcessGroup); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Status != "" {
+		op, value := splitOperator(opts.Status)
+		if err := add("status", op, value); err != nil {
+			return nil, err
+		}
+	}
+	if since := opts.Since; !since.IsZero() {
+		conds = append(conds, func(entry LogEntry) bool {
+			ts, err := entry.Time()
+			return err == nil && !ts.Before(since)
+		})
+	}
+	if until := opts.Until; !until.IsZero() {
+		conds = append(conds, func(entry LogEntry) bool {
+			ts, err := entry.Time()
+			return err == nil && !ts.After(until)
+		})
+	}
+	if opts.Query != "" {
+		cond, err := parseQuery(opts.Query, opts.ProcessGroupOf)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+
+	if len(conds) == 0 {
+		return nil, nil
+	}
+	return and(conds...), nil
+}
+
+// Time returns the parsed timestamp of the entry.
+func (entry LogEntry) Time() (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, entry.Timestamp)
+}
+
+func and(conds ...Filter) Filter {
+	return func(entry LogEntry) bool {
+		for _, cond := range conds {
+			if !cond(entry) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func or(conds ...Filter) Filter {
+	return func(entry LogEntry) bool {
+		for _, cond := range conds {
+			if cond(entry) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func not(cond Filter) Filter {
+	return func(entry LogEntry) bool {
+		return !cond(entry)
+	}
+}
+
+// levelRanks orders log levels by severity.
+var levelRanks = map[string]int{
+	"trace":     0,
+	"debug":     1,
+	"info":      2,
+	"notice":    3,
+	"warn":      4,
+	"warning":   4,
+	"error":     5,
+	"err":       5,
+	"fatal":     6,
+	"critical":  6,
+	"crit":      6,
+	"panic":     6,
+	"alert":     6,
+	"emergency": 6,
+}
+
+// validLevels lists the levels accepted by level filters.
+const validLevels = "trace, debug, info, notice, warn, error, fatal"
+
+// levelRank returns the severity of the level of an entry. Unknown levels
+// rank as info.
+func levelRank(level string) int {
+	if rank, ok := levelRanks[strings.ToLower(level)]; ok {
+		return rank
+	}
+	return levelRanks["info"]
+}
+
+func newCondition(field, op, value string, processGroupOf func(string) string) (Filter, error) {
+	switch field {
+	case "level":
+		rank, ok := levelRanks[strings.ToLower(value)]
+		if !ok {
+			return nil, fmt.Errorf("unknown log level '%s', valid levels are %s", value, validLevels)
+		}
+		return newIntCondition(field, op, strconv.Itoa(rank), func(e LogEntry) int {
+			return levelRank(e.Level)
+		})
+	case "status":
+		if class, ok := statusClass(value); ok && (op == "=" || op == "!=") {
+			matches := func(e LogEntry) bool {
+				code := e.Meta.HTTP.Response.StatusCode
+				return code >= class && code < class+100
+			}
+			return pick(op == "=", matches, not(matches)), nil
+		}
+		cond, err := newIntCondition(field, op, value, func(e LogEntry) int {
+			return e.Meta.HTTP.Response.StatusCode
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Entries that aren't HTTP requests never match a status condition
+		return and(func(e LogEntry) bool { return e.Meta.HTTP.Response.StatusCode > 0 }, cond), nil
+	case "group", "process_group":
+		if processGroupOf == nil {
+			return nil, fmt.Errorf("filtering by process group is not supported here")
+		}
+		return newStringCondition(field, op, value, func(e LogEntry) string {
+			return processGroupOf(e.Instance)
+		})
+	}
+
+	get, ok := stringFields[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field '%s'", field)
+	}
+	return newStringCondition(field, op, value, get)
+}
+
+var stringFields = map[string]func(LogEntry) string{
+	"message":  func(e LogEntry) string { return e.Message },
+	"msg":      func(e LogEntry) string { return e.Message },
+	"region":   func(e LogEntry) string { return e.Region },
+	"instance": func(e LogEntry) string { return e.Instance },
+	"provider": func(e LogEntry) string { return e.Meta.Event.Provider },
+	"method":   func(e LogEntry) string { return e.Meta.HTTP.Request.Method },
+	"url":      func(e LogEntry) string { return e.Meta.URL.Full },
+}
+
+func newStringCondition(field, op, value string, get func(LogEntry) string) (Filter, error) {
+	switch op {
+	case "=":
+		return func(e LogEntry) bool { return get(e) == value }, nil
+	case "!=":
+		return func(e LogEntry) bool { return get(e) != value }, nil
+	case ":":
+		return func(e LogEntry) bool { return strings.Contains(get(e), value) }, nil
+	case "~", "!~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression for %s: %w", field, err)
+		}
+		matches := func(e LogEntry) bool { return re.MatchString(get(e)) }
+		return pick(op == "~", matches, not(matches)), nil
+	default:
+		return nil, fmt.Errorf("operator '%s' can't be used with %s", op, field)
+	}
+}
+
+func newIntCondition(field, op, value string, get func(LogEntry) int) (Filter, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be compared to a number, got '%s'", field, value)
+	}
+
+	switch op {
+	case "=":
+		return func(e LogEntry) bool { return get(e) == n }, nil
+	case "!=":
+		return func(e LogEntry) bool { return get(e) != n }, nil
+	case ">":
+		return func(e LogEntry) bool { return get(e) > n }, nil
+	case ">=":
+		return func(e LogEntry) bool { return get(e) >= n }, nil
+	case "<":
+		return func(e LogEntry) bool { return get(e) < n }, nil
+	case "<=":
+		return func(e LogEntry) bool { return get(e) <= n }, nil
+	default:
+		return nil, fmt.Errorf("operator '%s' can't be used with %s", op, field)
+	}
+}
+
+// statusClass parses classes of status codes like "5xx".
+func statusClass(value string) (int, bool) {
+	if len(value) != 3 || !strings.HasSuffix(strings.ToLower(value), "xx") {
+		return 0, false
+	}
+	digit := value[0]
+	if digit < '1' || digit > '5' {
+		return 0, false
+	}
+	return int(digit-'0') * 100, true
+}
+
+// splitOperator splits a leading comparison operator from value, defaulting
+// to equality.
+func splitOperator(value string) (op, rest string) {
+	for _, op := range []string{">=", "<=", "!=", ">", "<", "="} {
+		if strings.HasPrefix(value, op) {
+			return op, value[len(op):]
+		}
+	}
+	return "=", value
+}
+
+func pick(cond bool, a, b Filter) Filter {
+	if cond {
+		return a
+	}
+	return b
+}
+
+// parseQuery parses a filter expression:
+//
+//	expr       = and { "or" and }
+//	and        = unary { "and" unary }
+//	unary      = "not" unary | "(" expr ")" | field op value
+//	op         = "=" | "!=" | ":" | "~" | "!~" | ">" | ">=" | "<" | "<="
+//	value      = word | quoted string
+func parseQuery(query string, processGroupOf func(string) string) (Filter, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens, processGroupOf: processGroupOf}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid query: unexpected '%s'", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+type queryToken struct {
+	text   string
+	quoted bool
+}
+
+func tokenizeQuery(query string) ([]queryToken, error) {
+	var (
+		tokens []queryToken
+		runes  = []rune(query)
+	)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, queryToken{text: string(r)})
+			i++
+		case r == '"':
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("invalid query: unterminated string")
+			}
+			s, err := strconv.Unquote(string(runes[i : j+1]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid query: bad string %s", string(runes[i:j+1]))
+			}
+			tokens = append(tokens, queryToken{text: s, quoted: true})
+			i = j + 1
+		case strings.ContainsRune(queryOperatorRunes, r):
+			j := i
+			for j < len(runes) && strings.ContainsRune(queryOperatorRunes, runes[j]) {
+				j++
+			}
+			tokens = append(tokens, queryToken{text: string(runes[i:j])})
+			i = j
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune("()\""+queryOperatorRunes, runes[j]) {
+				j++
+			}
+			tokens = append(tokens, queryToken{text: string(runes[i:j])})
+			i = j
+		}
+	}
+
+	return tokens, nil
+}
+
+const queryOperatorRunes = "=!~<>:"
+
+type queryParser struct {
+	tokens         []queryToken
+	pos            int
+	processGroupOf func(string) string
+}
+
+func (p *queryParser) peekKeyword(keyword string) bool {
+	if p.pos >= len(p.tokens) {
+		return false
+	}
+	t := p.tokens[p.pos]
+	return !t.quoted && strings.EqualFold(t.text, keyword)
+}
+
+func (p *queryParser) next() (queryToken, error) {
+	if p.pos >= len(p.tokens) {
+		return queryToken{}, fmt.Errorf("unexpected end of query")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, nil
+}
+
+func (p *queryParser) parseOr() (Filter, error) {
+	f, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	conds := []Filter{f}
+	for p.peekKeyword("or") {
+		p.pos++
+		if f, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		conds = append(conds, f)
+	}
+	if len(conds) == 1 {
+		return conds[0], nil
+	}
+	return or(conds...), nil
+}
+
+func (p *queryParser) parseAnd() (Filter, error) {
+	f, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	conds := []Filter{f}
+	for p.peekKeyword("and") {
+		p.pos++
+		if f, err = p.parseUnary(); err != nil {
+			return nil, err
+		}
+		conds = append(conds, f)
+	}
+	if len(conds) == 1 {
+		return conds[0], nil
+	}
+	return and(conds...), nil
+}
+
+func (p *queryParser) parseUnary() (Filter, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return not(f), nil
+	}
+
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if !t.quoted && t.text == "(" {
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t, err := p.next(); err != nil || t.quoted || t.text != ")" {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		return f, nil
+	}
+
+	field := strings.ToLower(t.text)
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if !value.quoted && (value.text == "(" || value.text == ")") {
+		return nil, fmt.Errorf("missing value for %s", field)
+	}
+
+	return newCondition(field, op.text, value.text, p.processGroupOf)
+}
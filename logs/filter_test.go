@@ -0,0 +1,145 @@
+package logs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	entry := func(level, message string, status int, instance string) LogEntry {
+		e := LogEntry{
+			Level:     level,
+			Message:   message,
+			Instance:  instance,
+			Region:    "ams",
+			Timestamp: "2024-03-01T12:00:00.000Z",
+		}
+		e.Meta.HTTP.Response.StatusCode = status
+		return e
+	}
+
+	var (
+		debug     = entry("debug", "cache warmed", 0, "m1")
+		info      = entry("info", "GET /health", 200, "m1")
+		warn      = entry("warning", "slow request", 404, "m2")
+		errTimout = entry("error", "upstream timeout", 502, "m2")
+		all       = []LogEntry{debug, info, warn, errTimout}
+	)
+
+	groups := map[string]string{"m1": "web", "m2": "worker"}
+
+	testcases := []struct {
+		name      string
+		opts      FilterOptions
+		expected  []LogEntry
+		expectErr bool
+	}{
+		{
+			name:     "no filters",
+			expected: all,
+		},
+		{
+			name:     "level threshold",
+			opts:     FilterOptions{Level: "warn"},
+			expected: []LogEntry{warn, errTimout},
+		},
+		{
+			name:     "grep",
+			opts:     FilterOptions{Grep: "time(out)?$"},
+			expected: []LogEntry{errTimout},
+		},
+		{
+			name:     "process group",
+			opts:     FilterOptions{ProcessGroup: "web"},
+			expected: []LogEntry{debug, info},
+		},
+		{
+			name:     "status class",
+			opts:     FilterOptions{Status: "5xx"},
+			expected: []LogEntry{errTimout},
+		},
+		{
+			name:     "status comparison skips non HTTP entries",
+			opts:     FilterOptions{Status: "<400"},
+			expected: []LogEntry{info},
+		},
+		{
+			name:     "since",
+			opts:     FilterOptions{Since: time.Date(2024, 3, 1, 12, 0, 1, 0, time.UTC)},
+			expected: nil,
+		},
+		{
+			name:     "until",
+			opts:     FilterOptions{Until: time.Date(2024, 3, 1, 12, 0, 1, 0, time.UTC)},
+			expected: all,
+		},
+		{
+			name:     "query with boolean operators",
+			opts:     FilterOptions{Query: `level>=warn and (status>=500 or msg:"slow") and not region=fra`},
+			expected: []LogEntry{warn, errTimout},
+		},
+		{
+			name:     "query combined with flags",
+			opts:     FilterOptions{Level: "info", Query: `group=worker or message~"^GET"`},
+			expected: []LogEntry{info, warn, errTimout},
+		},
+		{
+			name:     "query negated regex",
+			opts:     FilterOptions{Query: `msg!~"health|cache"`},
+			expected: []LogEntry{warn, errTimout},
+		},
+		{
+			name:      "unknown field",
+			opts:      FilterOptions{Query: `color=red`},
+			expectErr: true,
+		},
+		{
+			name:      "unbalanced parentheses",
+			opts:      FilterOptions{Query: `(level=info`},
+			expectErr: true,
+		},
+		{
+			name:      "trailing tokens",
+			opts:      FilterOptions{Query: `level=info region`},
+			expectErr: true,
+		},
+		{
+			name:      "unknown level",
+			opts:      FilterOptions{Level: "loud"},
+			expectErr: true,
+		},
+		{
+			name:      "unknown level in query",
+			opts:      FilterOptions{Query: `level>=verbose`},
+			expectErr: true,
+		},
+		{
+			name:      "bad status",
+			opts:      FilterOptions{Status: "teapot"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.ProcessGroupOf = func(instance string) string { return groups[instance] }
+
+			filter, err := NewFilter(tc.opts)
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var got []LogEntry
+			for _, e := range all {
+				if filter == nil || filter(e) {
+					got = append(got, e)
+				}
+			}
+			require.Equal(t, tc.expected, got)
+		})
+	}
+}
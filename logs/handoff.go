@@ -0,0 +1,120 @@
+package logs
+
+import (
+	"context"
+	"time"
+)
+
+// Handoff joins a history stream, like polling, with a live stream, like
+// NATS, that tails the same logs.
+//
+// Entries of the history stream are forwarded until it caught up with the
+// live stream, i.e. until it returned an entry at least as recent as the first
+// live entry. stopHistory is then called and the live stream takes over.
+// Live entries are held back until then, so no entry is lost or printed out of
+// order at the handoff, and entries both streams returned are only forwarded
+// once. If the history stream doesn't catch up within timeout of the first
+// live entry, the handoff happens anyway.
+//
+// When the live stream closes before the handoff, e.g. because it failed to
+// connect, the history stream keeps going on its own.
+func Handoff(ctx context.Context, history, live <-chan LogEntry, stopHistory func(), timeout time.Duration) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+
+		var (
+			seen      = NewDedupe()
+			held      []LogEntry
+			liveStart time.Time
+			handedOff bool
+			deadline  <-chan time.Time
+		)
+
+		emit := func(entry LogEntry) bool {
+			if seen.Seen(entry) {
+				return true
+			}
+			select {
+			case out <- entry:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		flush := func() bool {
+			for _, entry := range held {
+				if !emit(entry) {
+					return false
+				}
+			}
+			held = nil
+			return true
+		}
+
+		handoff := func() bool {
+			handedOff = true
+			deadline = nil
+			stopHistory()
+			return flush()
+		}
+
+		for history != nil || live != nil {
+			select {
+			case <-ctx.Done():
+				return
+
+			case entry, ok := <-history:
+				if !ok {
+					history = nil
+					handedOff = true
+					deadline = nil
+					if !flush() {
+						return
+					}
+					continue
+				}
+				if !emit(entry) {
+					return
+				}
+				if ts, err := entry.Time(); !handedOff && !liveStart.IsZero() && err == nil && !ts.Before(liveStart) {
+					if !handoff() {
+						return
+					}
+				}
+
+			case entry, ok := <-live:
+				switch {
+				case !ok:
+					live = nil
+					deadline = nil
+					// The history stream is all we have left
+					if !flush() {
+						return
+					}
+				case handedOff:
+					if !emit(entry) {
+						return
+					}
+				default:
+					if deadline == nil && len(held) == 0 {
+						deadline = time.After(timeout)
+					}
+					if ts, err := entry.Time(); err == nil && liveStart.IsZero() {
+						liveStart = ts
+					}
+					held = append(held, entry)
+				}
+
+			case <-deadline:
+				if !handoff() {
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
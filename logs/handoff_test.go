@@ -0,0 +1,91 @@
+package logs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func entryAt(sec, msg string) LogEntry {
+	return LogEntry{Instance: "a", Timestamp: "2024-03-01T12:00:" + sec + "Z", Message: msg}
+}
+
+func collect(stream <-chan LogEntry) (messages []string) {
+	for e := range stream {
+		messages = append(messages, e.Message)
+	}
+	return
+}
+
+func TestHandoff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	history := make(chan LogEntry)
+	live := make(chan LogEntry)
+
+	var stopped atomic.Bool
+	out := Handoff(ctx, history, live, func() { stopped.Store(true) }, time.Minute)
+
+	go func() {
+		history <- entryAt("01", "one")
+		// NATS connects while polling is behind
+		live <- entryAt("03", "three")
+		live <- entryAt("04", "four")
+		history <- entryAt("02", "two")
+		assert.False(t, stopped.Load())
+		history <- entryAt("03", "three")
+		close(history)
+		live <- entryAt("05", "five")
+		close(live)
+	}()
+
+	require.Equal(t, []string{"one", "two", "three", "four", "five"}, collect(out))
+	require.True(t, stopped.Load())
+}
+
+func TestHandoffTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	history := make(chan LogEntry)
+	live := make(chan LogEntry)
+
+	stopped := make(chan struct{})
+	out := Handoff(ctx, history, live, func() { close(stopped) }, 50*time.Millisecond)
+
+	go func() {
+		history <- entryAt("01", "one")
+		live <- entryAt("03", "three")
+		// Polling never catches up
+		<-stopped
+		close(history)
+		live <- entryAt("04", "four")
+		close(live)
+	}()
+
+	require.Equal(t, []string{"one", "three", "four"}, collect(out))
+}
+
+func TestHandoffLiveFails(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	history := make(chan LogEntry)
+	live := make(chan LogEntry)
+
+	out := Handoff(ctx, history, live, func() { t.Error("history must not be stopped") }, time.Minute)
+
+	go func() {
+		close(live)
+		history <- entryAt("01", "one")
+		history <- entryAt("02", "two")
+		close(history)
+	}()
+
+	require.Equal(t, []string{"one", "two"}, collect(out))
+}
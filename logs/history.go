@@ -0,0 +1,98 @@
+package logs
+
+import (
+	"context"
+	"time"
+
+	fly "github.com/superfly/fly-go"
+)
+
+// maxHistoryPages bounds how many pages History fetches, in case the API
+// keeps handing out tokens while the app is logging heavily.
+const maxHistoryPages = 100
+
+// HistoryOptions selects the entries History returns. Zero values match
+// everything.
+type HistoryOptions struct {
+	Since  time.Time
+	Until  time.Time
+	Filter Filter
+}
+
+// HistoryResult holds the entries History found.
+type HistoryResult struct {
+	Entries []LogEntry
+	// Oldest is the timestamp of the oldest entry History could fetch,
+	// matching or not. Entries before it can't be retrieved.
+	Oldest time.Time
+}
+
+type pageFunc func(ctx context.Context, token string) ([]fly.LogEntry, string, error)
+
+// History returns the recent logs of the app selected by hopts.
+//
+// The logs API only pages forwards, like Poll does: a request without a token
+// returns the most recent page, and the token it hands out leads to entries
+// logged after that page. History therefore starts from the most recent page
+// and follows the tokens to pick up entries logged in the meantime, until the
+// entries are newer than hopts.Until or no more pages are returned. Entries
+// older than the most recent page can't be reached, result.Oldest tells how
+// far back the result goes.
+func History(ctx context.Context, client *fly.Client, opts *LogOptions, hopts HistoryOptions) (*HistoryResult, error) {
+	return history(ctx, func(ctx context.Context, token string) ([]fly.LogEntry, string, error) {
+		return client.GetAppLogs(ctx, opts.AppName, token, opts.RegionCode, opts.VMID)
+	}, opts.AppName, hopts)
+}
+
+func history(ctx context.Context, fetch pageFunc, appName string, hopts HistoryOptions) (*HistoryResult, error) {
+	var (
+		result = &HistoryResult{}
+		token  string
+	)
+
+	for page := 0; page < maxHistoryPages; page++ {
+		entries, nextToken, err := fetch(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			entry := LogEntry{
+				App:       appName,
+				Instance:  e.Instance,
+				Level:     e.Level,
+				Message:   e.Message,
+				Region:    e.Region,
+				Timestamp: e.Timestamp,
+				Meta:      e.Meta,
+			}
+
+			ts, err := entry.Time()
+			if err != nil {
+				continue
+			}
+			if result.Oldest.IsZero() || ts.Before(result.Oldest) {
+				result.Oldest = ts
+			}
+
+			switch {
+			case !hopts.Until.IsZero() && ts.After(hopts.Until):
+				// Entries are sorted from oldest to newest, within pages
+				// and across the pages the tokens lead to.
+				return result, nil
+			case !hopts.Since.IsZero() && ts.Before(hopts.Since):
+				continue
+			case hopts.Filter != nil && !hopts.Filter(entry):
+				continue
+			}
+			result.Entries = append(result.Entries, entry)
+		}
+
+		if len(entries) == 0 || nextToken == "" || nextToken == token {
+			break
+		}
+		token = nextToken
+	}
+
+	return result, nil
+}
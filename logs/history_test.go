@@ -0,0 +1,107 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	fly "github.com/superfly/fly-go"
+)
+
+func page(secs ...string) []fly.LogEntry {
+	var entries []fly.LogEntry
+	for _, sec := range secs {
+		entries = append(entries, fly.LogEntry{
+			Timestamp: "2024-03-01T12:00:" + sec + "Z",
+			Message:   sec,
+		})
+	}
+	return entries
+}
+
+// fakeLogsAPI follows the contract of GetAppLogs that Poll relies on: a
+// request without a token returns the most recent retained page, and the
+// tokens lead forward to the pages logged after it.
+func fakeLogsAPI(retained [][]fly.LogEntry, later ...[]fly.LogEntry) pageFunc {
+	return func(_ context.Context, token string) ([]fly.LogEntry, string, error) {
+		if token == "" {
+			return retained[len(retained)-1], "0", nil
+		}
+		i, _ := strconv.Atoi(token)
+		if i >= len(later) {
+			return nil, token, nil
+		}
+		return later[i], strconv.Itoa(i + 1), nil
+	}
+}
+
+func TestHistory(t *testing.T) {
+	at := func(sec int) time.Time {
+		return time.Date(2024, 3, 1, 12, 0, sec, 0, time.UTC)
+	}
+	fetch := fakeLogsAPI(
+		[][]fly.LogEntry{page("01", "02"), page("03", "04")},
+		page("05"), page("06"),
+	)
+
+	cases := []struct {
+		name     string
+		opts     HistoryOptions
+		expected []string
+	}{
+		{
+			name:     "everything",
+			expected: []string{"03", "04", "05", "06"},
+		},
+		{
+			name:     "since",
+			opts:     HistoryOptions{Since: at(4)},
+			expected: []string{"04", "05", "06"},
+		},
+		{
+			name:     "since before the most recent page",
+			opts:     HistoryOptions{Since: at(1)},
+			expected: []string{"03", "04", "05", "06"},
+		},
+		{
+			name:     "until",
+			opts:     HistoryOptions{Until: at(5)},
+			expected: []string{"03", "04", "05"},
+		},
+		{
+			name: "filter",
+			opts: HistoryOptions{Filter: func(e LogEntry) bool {
+				return e.Message != "05"
+			}},
+			expected: []string{"03", "04", "06"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := history(context.Background(), fetch, "app", tc.opts)
+			require.NoError(t, err)
+
+			var got []string
+			for _, e := range result.Entries {
+				require.Equal(t, "app", e.App)
+				got = append(got, e.Message)
+			}
+			require.Equal(t, tc.expected, got)
+			// Pages before the most recent one can't be reached
+			require.True(t, result.Oldest.Equal(at(3)))
+		})
+	}
+}
+
+func TestHistoryError(t *testing.T) {
+	fetch := func(context.Context, string) ([]fly.LogEntry, string, error) {
+		return nil, "", errors.New("boom")
+	}
+
+	_, err := history(context.Background(), fetch, "app", HistoryOptions{})
+	require.EqualError(t, err, "boom")
+}
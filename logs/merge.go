@@ -0,0 +1,136 @@
+package logs
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// Merge merges streams into a single stream ordered by timestamp. Every entry
+// is held back for window, so that entries of different streams arriving
+// slightly out of order are emitted in order. Entries several streams returned
+// are only emitted once. The merged stream is closed once all streams are
+// closed or ctx is done.
+func Merge(ctx context.Context, window time.Duration, streams ...<-chan LogEntry) <-chan LogEntry {
+	var (
+		in  = make(chan LogEntry)
+		out = make(chan LogEntry)
+		wg  sync.WaitGroup
+	)
+
+	for _, stream := range streams {
+		stream := stream
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for entry := range stream {
+				select {
+				case in <- entry:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(in)
+	}()
+
+	go func() {
+		defer close(out)
+
+		var (
+			pending = &entryHeap{}
+			seen    = NewDedupe()
+			timer   = time.NewTimer(window)
+			input   = in
+		)
+		defer timer.Stop()
+
+		for input != nil || pending.Len() > 0 {
+			// Release the entries that were held back long enough, or all
+			// of them once the input is exhausted.
+			for pending.Len() > 0 && (input == nil || time.Since((*pending)[0].received) >= window) {
+				held := heap.Pop(pending).(heldEntry)
+				if seen.Seen(held.entry) {
+					continue
+				}
+				select {
+				case out <- held.entry:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if input == nil {
+				continue
+			}
+
+			var wake <-chan time.Time
+			if pending.Len() > 0 {
+				timer.Reset(window - time.Since((*pending)[0].received))
+				wake = timer.C
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case entry, ok := <-input:
+				if !ok {
+					input = nil
+					continue
+				}
+				heap.Push(pending, newHeldEntry(entry))
+			case <-wake:
+			}
+
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+type heldEntry struct {
+	entry    LogEntry
+	ts       time.Time
+	received time.Time
+}
+
+func newHeldEntry(entry LogEntry) heldEntry {
+	held := heldEntry{entry: entry, received: time.Now()}
+	if ts, err := entry.Time(); err == nil {
+		held.ts = ts
+	} else {
+		held.ts = held.received
+	}
+	return held
+}
+
+// entryHeap is a min-heap of entries ordered by timestamp.
+type entryHeap []heldEntry
+
+func (h entryHeap) Len() int           { return len(h) }
+func (h entryHeap) Less(i, j int) bool { return h[i].ts.Before(h[j].ts) }
+func (h entryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *entryHeap) Push(x any) {
+	*h = append(*h, x.(heldEntry))
+}
+
+func (h *entryHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
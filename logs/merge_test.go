@@ -0,0 +1,79 @@
+package logs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entry := func(app, ts string) LogEntry {
+		return LogEntry{App: app, Timestamp: "2024-03-01T12:00:" + ts + "Z"}
+	}
+
+	api := make(chan LogEntry, 3)
+	worker := make(chan LogEntry, 3)
+
+	api <- entry("api", "01")
+	api <- entry("api", "04")
+	worker <- entry("worker", "02")
+	worker <- entry("worker", "03")
+	api <- entry("api", "05")
+	close(api)
+	close(worker)
+
+	var got []string
+	for e := range Merge(ctx, 50*time.Millisecond, api, worker) {
+		got = append(got, e.App+"@"+e.Timestamp[17:19])
+	}
+
+	require.Equal(t, []string{"api@01", "worker@02", "worker@03", "api@04", "api@05"}, got)
+}
+
+func TestMergeHoldsEntriesBack(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream := make(chan LogEntry)
+	merged := Merge(ctx, 100*time.Millisecond, stream)
+
+	start := time.Now()
+	stream <- LogEntry{Timestamp: "2024-03-01T12:00:01Z"}
+
+	e := <-merged
+	require.Equal(t, "2024-03-01T12:00:01Z", e.Timestamp)
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	close(stream)
+	_, ok := <-merged
+	require.False(t, ok)
+}
+
+func TestMergeDeduplicates(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	polling := make(chan LogEntry, 3)
+	nats := make(chan LogEntry, 3)
+
+	polling <- LogEntry{Instance: "a", Timestamp: "2024-03-01T12:00:01Z", Message: "one"}
+	polling <- LogEntry{Instance: "a", Timestamp: "2024-03-01T12:00:02Z", Message: "two"}
+	nats <- LogEntry{Instance: "a", Timestamp: "2024-03-01T12:00:02Z", Message: "two"}
+	nats <- LogEntry{Instance: "b", Timestamp: "2024-03-01T12:00:02Z", Message: "two"}
+	nats <- LogEntry{Instance: "a", Timestamp: "2024-03-01T12:00:02Z", Message: "three"}
+	close(polling)
+	close(nats)
+
+	var got []string
+	for e := range Merge(ctx, 50*time.Millisecond, polling, nats) {
+		got = append(got, e.Instance+":"+e.Message)
+	}
+
+	require.ElementsMatch(t, []string{"a:one", "a:two", "b:two", "a:three"}, got)
+	require.Equal(t, "a:one", got[0])
+}
@@ -3,8 +3,11 @@ package logs
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/nats-io/nats.go"
 
@@ -13,8 +16,28 @@ import (
 	"github.com/superfly/flyctl/internal/config"
 )
 
+const (
+	natsMinReconnectWait = time.Second
+	natsMaxReconnectWait = 30 * time.Second
+	// natsPingInterval is how often the connection is checked, which bounds
+	// how long a dropped tunnel goes unnoticed.
+	natsPingInterval = 15 * time.Second
+)
+
+// natsSubscription is the part of a NATS subscription fromNats uses.
+type natsSubscription interface {
+	NextMsgWithContext(ctx context.Context) (*nats.Msg, error)
+	Unsubscribe() error
+}
+
 type natsLogStream struct {
-	nc  *nats.Conn
+	// subscribe connects to NATS, if needed, and subscribes to subject.
+	subscribe func(ctx context.Context, subject string) (natsSubscription, error)
+	// backfill returns the entries logged between since and until, to fill
+	// the gaps left by disconnections, as far back as they can be fetched.
+	backfill func(ctx context.Context, opts *LogOptions, since, until time.Time) (*HistoryResult, error)
+
+	mu  sync.Mutex
 	err error
 }
 
@@ -24,26 +47,49 @@ func NewNatsStream(ctx context.Context, apiClient *fly.Client, opts *LogOptions)
 		return nil, fmt.Errorf("failed fetching target app: %w", err)
 	}
 
-	agentclient, err := agent.Establish(ctx, apiClient)
-	if err != nil {
-		return nil, fmt.Errorf("failed establishing agent: %w", err)
+	connect := func(ctx context.Context) (*nats.Conn, error) {
+		agentclient, err := agent.Establish(ctx, apiClient)
+		if err != nil {
+			return nil, fmt.Errorf("failed establishing agent: %w", err)
+		}
+
+		dialer, err := agentclient.Dialer(ctx, app.Organization.Slug)
+		if err != nil {
+			return nil, fmt.Errorf("failed establishing wireguard connection for %s organization: %w", app.Organization.Slug, err)
+		}
+
+		if err = agentclient.WaitForTunnel(ctx, app.Organization.Slug); err != nil {
+			return nil, fmt.Errorf("failed connecting to WireGuard tunnel: %w", err)
+		}
+
+		nc, err := newNatsClient(ctx, dialer, app.Organization.RawSlug)
+		if err != nil {
+			return nil, fmt.Errorf("failed creating nats connection: %w", err)
+		}
+		return nc, nil
 	}
 
-	dialer, err := agentclient.Dialer(ctx, app.Organization.Slug)
+	nc, err := connect(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed establishing wireguard connection for %s organization: %w", app.Organization.Slug, err)
+		return nil, err
 	}
 
-	if err = agentclient.WaitForTunnel(ctx, app.Organization.Slug); err != nil {
-		return nil, fmt.Errorf("failed connecting to WireGuard tunnel: %w", err)
+	subscribe := func(ctx context.Context, subject string) (natsSubscription, error) {
+		if nc.IsClosed() {
+			conn, err := connect(ctx)
+			if err != nil {
+				return nil, err
+			}
+			nc = conn
+		}
+		return nc.SubscribeSync(subject)
 	}
 
-	nc, err := newNatsClient(ctx, dialer, app.Organization.RawSlug)
-	if err != nil {
-		return nil, fmt.Errorf("failed creating nats connection: %w", err)
+	backfill := func(ctx context.Context, opts *LogOptions, since, until time.Time) (*HistoryResult, error) {
+		return History(ctx, apiClient, opts, HistoryOptions{Since: since, Until: until})
 	}
 
-	return &natsLogStream{nc: nc}, nil
+	return &natsLogStream{subscribe: subscribe, backfill: backfill}, nil
 }
 
 // natsLogStream implements LogStream
@@ -53,16 +99,54 @@ func (s *natsLogStream) Stream(ctx context.Context, opts *LogOptions) <-chan Log
 	go func() {
 		defer close(out)
 
-		s.err = fromNats(ctx, out, s.nc, opts)
+		if err := s.fromNats(ctx, out, opts); err != nil && !errors.Is(err, context.Canceled) {
+			s.setErr(&StreamError{Err: err, Fatal: true})
+		}
 	}()
 
 	return out
 }
 
+// Err returns the error the stream stopped with, which is fatal, or the error
+// it is recovering from while it reconnects.
 func (s *natsLogStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	return s.err
 }
 
+func (s *natsLogStream) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+// StreamError is an error a LogStream ran into.
+type StreamError struct {
+	Err error
+	// Fatal errors stopped the stream, other ones are being recovered from.
+	Fatal bool
+}
+
+func (e *StreamError) Error() string {
+	if e.Fatal {
+		return fmt.Sprintf("log stream failed: %v", e.Err)
+	}
+	return fmt.Sprintf("log stream interrupted: %v", e.Err)
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// IsFatal reports whether err stopped a LogStream for good.
+func IsFatal(err error) bool {
+	var se *StreamError
+	return errors.As(err, &se) && se.Fatal
+}
+
 func newNatsClient(ctx context.Context, dialer agent.Dialer, orgSlug string) (*nats.Conn, error) {
 	state := dialer.State()
 
@@ -75,7 +159,14 @@ func newNatsClient(ctx context.Context, dialer agent.Dialer, orgSlug string) (*n
 	natsIP := net.IP(natsIPBytes[:])
 
 	url := fmt.Sprintf("nats://[%s]:4223", natsIP.String())
-	conn, err := nats.Connect(url, nats.SetCustomDialer(&natsDialer{dialer, ctx}), nats.UserInfo(orgSlug, config.Tokens(ctx).NATS()))
+	conn, err := nats.Connect(url,
+		nats.SetCustomDialer(&natsDialer{dialer, ctx}),
+		nats.UserInfo(orgSlug, config.Tokens(ctx).NATS()),
+		// Reconnections are handled by fromNats, which back-fills the logs
+		// missed in the meantime.
+		nats.NoReconnect(),
+		nats.PingInterval(natsPingInterval),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed connecting to nats: %w", err)
 	}
@@ -92,27 +183,153 @@ func (d *natsDialer) Dial(network, address string) (net.Conn, error) {
 	return d.Dialer.DialContext(d.ctx, network, address)
 }
 
-func fromNats(ctx context.Context, out chan<- LogEntry, nc *nats.Conn, opts *LogOptions) (err error) {
-	var sub *nats.Subscription
-	if sub, err = nc.SubscribeSync(opts.toNatsSubject()); err != nil {
-		return
+// isFatalNatsError reports whether reconnecting after err is pointless.
+func isFatalNatsError(err error) bool {
+	return errors.Is(err, nats.ErrAuthorization) ||
+		errors.Is(err, nats.ErrAuthExpired) ||
+		errors.Is(err, nats.ErrAuthRevoked) ||
+		fly.IsNotAuthenticatedError(err) ||
+		fly.IsNotFoundError(err)
+}
+
+// fromNats streams the logs published to NATS. When the connection drops, it
+// reconnects with backoff and back-fills the logs missed in the meantime by
+// polling, announcing both with marker entries.
+func (s *natsLogStream) fromNats(ctx context.Context, out chan<- LogEntry, opts *LogOptions) error {
+	send := func(entry LogEntry) error {
+		select {
+		case out <- entry:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	sub, err := s.subscribe(ctx, opts.toNatsSubject())
+	if err != nil {
+		return err
+	}
+
+	var lastSeen time.Time
+	for {
+		entry, err := nextNatsEntry(ctx, sub)
+		switch {
+		case err == nil:
+			if ts, err := entry.Time(); err == nil && ts.After(lastSeen) {
+				lastSeen = ts
+			}
+			if err := send(entry); err != nil {
+				sub.Unsubscribe()
+				return err
+			}
+			continue
+		case ctx.Err() != nil:
+			sub.Unsubscribe()
+			return ctx.Err()
+		case isFatalNatsError(err):
+			sub.Unsubscribe()
+			return err
+		}
+
+		// The connection dropped
+		sub.Unsubscribe()
+		s.setErr(&StreamError{Err: err})
+		if err := send(markerEntry(opts, "warn", fmt.Sprintf("Log stream interrupted, reconnecting: %v", err))); err != nil {
+			return err
+		}
+
+		if sub, err = s.resubscribe(ctx, opts); err != nil {
+			return err
+		}
+		// Entries logged from now on arrive through the new subscription,
+		// so the back-fill stops here.
+		resubscribedAt := time.Now()
+
+		marker := markerEntry(opts, "info", "Log stream reconnected")
+		if !lastSeen.IsZero() {
+			result, err := s.backfill(ctx, opts, lastSeen, resubscribedAt)
+			switch {
+			case ctx.Err() != nil:
+				sub.Unsubscribe()
+				return ctx.Err()
+			case err != nil:
+				// The gap is announced, but not fatal
+				marker = markerEntry(opts, "warn", fmt.Sprintf("Log stream reconnected, failed fetching the logs missed in the meantime: %v", err))
+				result = &HistoryResult{}
+			case result.Oldest.After(lastSeen):
+				// Only the most recent page of logs can be fetched
+				lost := markerEntry(opts, "warn", fmt.Sprintf("Logs between %s and %s can't be recovered",
+					lastSeen.UTC().Format(time.RFC3339Nano), result.Oldest.UTC().Format(time.RFC3339Nano)))
+				if err := send(lost); err != nil {
+					sub.Unsubscribe()
+					return err
+				}
+				fallthrough
+			default:
+				marker.Message = fmt.Sprintf("Log stream reconnected, back-filled %d entries", len(result.Entries))
+			}
+
+			for _, entry := range result.Entries {
+				if err := send(entry); err != nil {
+					sub.Unsubscribe()
+					return err
+				}
+				if ts, err := entry.Time(); err == nil && ts.After(lastSeen) {
+					lastSeen = ts
+				}
+			}
+		}
+
+		s.setErr(nil)
+		if err := send(marker); err != nil {
+			sub.Unsubscribe()
+			return err
+		}
 	}
-	defer sub.Unsubscribe()
+}
 
-	var log natsLog
+// resubscribe subscribes again, waiting longer and longer between attempts.
+func (s *natsLogStream) resubscribe(ctx context.Context, opts *LogOptions) (natsSubscription, error) {
+	wait := natsMinReconnectWait
 	for {
-		var msg *nats.Msg
-		if msg, err = sub.NextMsgWithContext(ctx); err != nil {
-			break
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
 		}
 
-		if err = json.Unmarshal(msg.Data, &log); err != nil {
-			err = fmt.Errorf("failed parsing log: %w", err)
+		sub, err := s.subscribe(ctx, opts.toNatsSubject())
+		switch {
+		case err == nil:
+			return sub, nil
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case isFatalNatsError(err):
+			return nil, err
+		}
+		s.setErr(&StreamError{Err: err})
 
-			break
+		if wait *= 2; wait > natsMaxReconnectWait {
+			wait = natsMaxReconnectWait
 		}
+	}
+}
 
-		out <- LogEntry{
+func nextNatsEntry(ctx context.Context, sub natsSubscription) (LogEntry, error) {
+	for {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return LogEntry{}, err
+		}
+
+		var log natsLog
+		if err := json.Unmarshal(msg.Data, &log); err != nil {
+			// A malformed message doesn't break the stream
+			continue
+		}
+
+		return LogEntry{
+			App:       log.Fly.App.Name,
 			Instance:  log.Fly.App.Instance,
 			Level:     log.Log.Level,
 			Message:   log.Message,
@@ -123,8 +340,50 @@ func fromNats(ctx context.Context, out chan<- LogEntry, nc *nats.Conn, opts *Log
 				Region:   log.Fly.Region,
 				Event:    struct{ Provider string }{log.Event.Provider},
 			},
-		}
+		}, nil
 	}
+}
+
+// MarkerProvider is the provider of the entries flyctl adds to log streams to
+// announce interruptions.
+const MarkerProvider = "flyctl"
+
+// IsMarker reports whether entry was added by flyctl to announce an
+// interruption, rather than logged by the app.
+func IsMarker(entry LogEntry) bool {
+	return entry.Meta.Event.Provider == MarkerProvider
+}
+
+// DropMarkers passes the entries of in through, except for marker entries,
+// for consumers that only deal with the logs of the app.
+func DropMarkers(ctx context.Context, in <-chan LogEntry) <-chan LogEntry {
+	out := make(chan LogEntry)
+
+	go func() {
+		defer close(out)
+
+		for entry := range in {
+			if IsMarker(entry) {
+				continue
+			}
+			select {
+			case out <- entry:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-	return
+	return out
+}
+
+func markerEntry(opts *LogOptions, level, message string) LogEntry {
+	entry := LogEntry{
+		App:       opts.AppName,
+		Level:     level,
+		Message:   message,
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	entry.Meta.Event.Provider = MarkerProvider
+	return entry
 }
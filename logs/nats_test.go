@@ -0,0 +1,182 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSubscription struct {
+	msgs []string
+	err  error
+}
+
+func (s *fakeSubscription) NextMsgWithContext(ctx context.Context) (*nats.Msg, error) {
+	if len(s.msgs) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	msg := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return &nats.Msg{Data: []byte(msg)}, nil
+}
+
+func (s *fakeSubscription) Unsubscribe() error { return nil }
+
+func natsMsg(ts, msg string) string {
+	return fmt.Sprintf(`{"fly":{"app":{"name":"app","instance":"a"}},"timestamp":%q,"message":%q}`, ts, msg)
+}
+
+func TestNatsLogStream_Reconnects(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	subs := []natsSubscription{
+		&fakeSubscription{
+			msgs: []string{natsMsg("2024-03-01T12:00:01Z", "one"), "not json"},
+			err:  nats.ErrConnectionClosed,
+		},
+		&fakeSubscription{msgs: []string{natsMsg("2024-03-01T12:00:04Z", "four")}},
+	}
+
+	var since, until time.Time
+	s := &natsLogStream{
+		subscribe: func(context.Context, string) (natsSubscription, error) {
+			sub := subs[0]
+			subs = subs[1:]
+			return sub, nil
+		},
+		backfill: func(_ context.Context, _ *LogOptions, s, u time.Time) (*HistoryResult, error) {
+			since, until = s, u
+			return &HistoryResult{
+				Entries: []LogEntry{
+					{Timestamp: "2024-03-01T12:00:02Z", Message: "two"},
+					{Timestamp: "2024-03-01T12:00:03Z", Message: "three"},
+				},
+				Oldest: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+			}, nil
+		},
+	}
+
+	stream := s.Stream(ctx, &LogOptions{AppName: "app"})
+
+	var got []string
+	for entry := range stream {
+		provider := entry.Meta.Event.Provider
+		got = append(got, provider+":"+entry.Message)
+		if entry.Message == "four" {
+			cancel()
+		}
+		if provider == MarkerProvider && len(got) == 2 {
+			require.Error(t, s.Err())
+			require.False(t, IsFatal(s.Err()))
+		}
+	}
+
+	require.Equal(t, []string{
+		":one",
+		"flyctl:Log stream interrupted, reconnecting: nats: connection closed",
+		":two",
+		":three",
+		"flyctl:Log stream reconnected, back-filled 2 entries",
+		":four",
+	}, got)
+	require.True(t, since.Equal(time.Date(2024, 3, 1, 12, 0, 1, 0, time.UTC)))
+	require.False(t, until.IsZero())
+	require.NoError(t, s.Err())
+}
+
+func TestNatsLogStream_TruncatedBackfill(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	subs := []natsSubscription{
+		&fakeSubscription{
+			msgs: []string{natsMsg("2024-03-01T12:00:01Z", "one")},
+			err:  nats.ErrConnectionClosed,
+		},
+		&fakeSubscription{msgs: []string{natsMsg("2024-03-01T12:10:01Z", "three")}},
+	}
+
+	s := &natsLogStream{
+		subscribe: func(context.Context, string) (natsSubscription, error) {
+			sub := subs[0]
+			subs = subs[1:]
+			return sub, nil
+		},
+		// The most recent page starts well after the disconnection
+		backfill: func(context.Context, *LogOptions, time.Time, time.Time) (*HistoryResult, error) {
+			return &HistoryResult{
+				Entries: []LogEntry{{Timestamp: "2024-03-01T12:10:00Z", Message: "two"}},
+				Oldest:  time.Date(2024, 3, 1, 12, 9, 0, 0, time.UTC),
+			}, nil
+		},
+	}
+
+	var got []string
+	for entry := range s.Stream(ctx, &LogOptions{AppName: "app"}) {
+		got = append(got, entry.Level+":"+entry.Message)
+		if entry.Message == "three" {
+			cancel()
+		}
+	}
+
+	require.Equal(t, []string{
+		":one",
+		"warn:Log stream interrupted, reconnecting: nats: connection closed",
+		"warn:Logs between 2024-03-01T12:00:01Z and 2024-03-01T12:09:00Z can't be recovered",
+		":two",
+		"info:Log stream reconnected, back-filled 1 entries",
+		":three",
+	}, got)
+}
+
+func TestNatsLogStream_Fatal(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s := &natsLogStream{
+		subscribe: func(context.Context, string) (natsSubscription, error) {
+			return &fakeSubscription{err: nats.ErrAuthorization}, nil
+		},
+	}
+
+	for range s.Stream(ctx, &LogOptions{AppName: "app"}) {
+		t.Fatal("no entries expected")
+	}
+
+	require.True(t, IsFatal(s.Err()))
+	require.True(t, errors.Is(s.Err(), nats.ErrAuthorization))
+}
+
+func TestDropMarkers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	in := make(chan LogEntry, 3)
+	in <- LogEntry{Message: "one"}
+	in <- markerEntry(&LogOptions{AppName: "app"}, "warn", "Log stream interrupted")
+	in <- LogEntry{Message: "two"}
+	close(in)
+
+	var got []string
+	for entry := range DropMarkers(ctx, in) {
+		got = append(got, entry.Message)
+	}
+	require.Equal(t, []string{"one", "two"}, got)
+}
+
+func TestIsFatal(t *testing.T) {
+	require.False(t, IsFatal(nil))
+	require.False(t, IsFatal(errors.New("boom")))
+	require.False(t, IsFatal(&StreamError{Err: errors.New("boom")}))
+	require.True(t, IsFatal(fmt.Errorf("wrapped: %w", &StreamError{Err: errors.New("boom"), Fatal: true})))
+}
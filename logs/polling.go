@@ -82,13 +82,18 @@ func Poll(ctx context.Context, out chan<- LogEntry, client *fly.Client, opts *Lo
 		}
 
 		for _, entry := range entries {
-			out <- LogEntry{
+			select {
+			case out <- LogEntry{
+				App:       opts.AppName,
 				Instance:  entry.Instance,
 				Level:     entry.Level,
 				Message:   entry.Message,
 				Region:    entry.Region,
 				Timestamp: entry.Timestamp,
 				Meta:      entry.Meta,
+			}:
+			case <-ctx.Done():
+				return ctx.Err()
 			}
 		}
 
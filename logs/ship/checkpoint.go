@@ -0,0 +1,83 @@
+package ship
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/superfly/flyctl/logs"
+)
+
+// Checkpoint records the timestamp of the newest shipped entry so that a new
+// run back-fills from where the previous one stopped. Entries with the same
+// timestamp as the checkpoint are shipped again, trading duplicates for not
+// losing any.
+type Checkpoint struct {
+	path      string
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// LoadCheckpoint reads the checkpoint at path. A missing file is an empty
+// checkpoint.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	c := &Checkpoint{path: path}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return c, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Shipped reports whether entry is older than the checkpoint, i.e. whether a
+// back-filled entry was shipped by a previous run.
+func (c *Checkpoint) Shipped(entry logs.LogEntry) bool {
+	ts, err := entry.Time()
+	return err == nil && ts.Before(c.Timestamp)
+}
+
+// Advance moves the checkpoint to the newest entry of batch and saves it.
+func (c *Checkpoint) Advance(batch []logs.LogEntry) error {
+	newest := c.Timestamp
+	for _, entry := range batch {
+		if ts, err := entry.Time(); err == nil && ts.After(newest) {
+			newest = ts
+		}
+	}
+	if newest.Equal(c.Timestamp) {
+		return nil
+	}
+	c.Timestamp = newest
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a crash doesn't leave a
+	// truncated checkpoint behind.
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
@@ -0,0 +1,226 @@
+package ship
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/superfly/flyctl/logs"
+)
+
+// FileSinkOptions configures a FileSink.
+type FileSinkOptions struct {
+	// Path of the active log file, rotated files are kept next to it.
+	Path string
+	// MaxSize in bytes after which the file is rotated, 0 disables it.
+	MaxSize int64
+	// MaxAge after which the file is rotated, 0 disables it.
+	MaxAge time.Duration
+	// Compress rotated files with gzip.
+	Compress bool
+	// MaxBackups is how many rotated files are kept, 0 keeps all of them.
+	MaxBackups int
+}
+
+// FileSink writes entries as newline delimited JSON to a local file that is
+// rotated by size and age.
+type FileSink struct {
+	opts   FileSinkOptions
+	file   *os.File
+	size   int64
+	opened time.Time
+	now    func() time.Time
+}
+
+// NewFileSink opens the file at opts.Path for appending, creating it and its
+// parent directories when needed.
+func NewFileSink(opts FileSinkOptions) (*FileSink, error) {
+	s := &FileSink{opts: opts, now: time.Now}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) Name() string {
+	return "file " + s.opts.Path
+}
+
+func (s *FileSink) open() error {
+	if err := os.MkdirAll(filepath.Dir(s.opts.Path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.opts.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	s.file = f
+	s.size = info.Size()
+	s.opened = s.now()
+	return nil
+}
+
+func (s *FileSink) Write(ctx context.Context, entries []logs.LogEntry) error {
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+
+	if s.shouldRotate() {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("failed rotating %s: %w", s.opts.Path, err)
+		}
+	}
+
+	w := bufio.NewWriter(s.file)
+	enc := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	size := int64(w.Buffered())
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	s.size += size
+
+	return s.file.Sync()
+}
+
+func (s *FileSink) shouldRotate() bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.opts.MaxSize > 0 && s.size >= s.opts.MaxSize {
+		return true
+	}
+	return s.opts.MaxAge > 0 && s.now().Sub(s.opened) >= s.opts.MaxAge
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	rotated := fmt.Sprintf("%s.%s", s.opts.Path, s.now().UTC().Format(rotationTimeFormat))
+	if err := os.Rename(s.opts.Path, rotated); err != nil {
+		return err
+	}
+
+	if s.opts.Compress {
+		if err := compressFile(rotated); err != nil {
+			return err
+		}
+	}
+
+	if err := s.prune(); err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+// prune removes the oldest rotated files beyond MaxBackups. Only the files
+// named like the ones rotate creates are considered.
+func (s *FileSink) prune() error {
+	if s.opts.MaxBackups <= 0 {
+		return nil
+	}
+
+	dir, base := filepath.Split(s.opts.Path)
+	dirEntries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return err
+	}
+
+	var backups []string
+	for _, e := range dirEntries {
+		if name, ok := strings.CutPrefix(e.Name(), base+"."); ok && !e.IsDir() && isRotationSuffix(name) {
+			backups = append(backups, filepath.Join(dir, e.Name()))
+		}
+	}
+	if len(backups) <= s.opts.MaxBackups {
+		return nil
+	}
+
+	// The timestamp suffix sorts chronologically
+	slices.Sort(backups)
+	for _, name := range backups[:len(backups)-s.opts.MaxBackups] {
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotationTimeFormat is the suffix rotated files get, optionally followed by
+// .gz when they are compressed.
+const rotationTimeFormat = "20060102T150405.000"
+
+func isRotationSuffix(suffix string) bool {
+	suffix = strings.TrimSuffix(suffix, ".gz")
+	_, err := time.Parse(rotationTimeFormat, suffix)
+	return err == nil && len(suffix) == len(rotationTimeFormat)
+}
+
+func (s *FileSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := name + ".gz.tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, name+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
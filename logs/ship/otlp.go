@@ -0,0 +1,182 @@
+package ship
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/superfly/flyctl/logs"
+)
+
+// otlpTimeout bounds a single export, so that a hung collector fails the
+// write and gets retried instead of stalling shipping.
+const otlpTimeout = 30 * time.Second
+
+// OTLPSink exports entries to an OpenTelemetry collector with the OTLP/HTTP
+// JSON encoding.
+type OTLPSink struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// NewOTLPSink returns a sink posting to the /v1/logs path of endpoint, unless
+// endpoint already ends with it.
+func NewOTLPSink(endpoint string, headers map[string]string) *OTLPSink {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/logs") {
+		endpoint += "/v1/logs"
+	}
+
+	return &OTLPSink{
+		endpoint: endpoint,
+		headers:  headers,
+		client:   &http.Client{Timeout: otlpTimeout},
+	}
+}
+
+func (s *OTLPSink) Name() string {
+	return "otlp " + s.endpoint
+}
+
+func (s *OTLPSink) Write(ctx context.Context, entries []logs.LogEntry) error {
+	body, err := json.Marshal(newOTLPRequest(entries))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector responded with %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+func (s *OTLPSink) Close() error {
+	return nil
+}
+
+type otlpRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string          `json:"timeUnixNano,omitempty"`
+	SeverityNumber int             `json:"severityNumber,omitempty"`
+	SeverityText   string          `json:"severityText,omitempty"`
+	Body           otlpValue       `json:"body"`
+	Attributes     []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// newOTLPRequest groups entries by app, each app being its own resource.
+func newOTLPRequest(entries []logs.LogEntry) otlpRequest {
+	var (
+		req   otlpRequest
+		byApp = map[string]int{}
+		scope = otlpScope{Name: "flyctl"}
+		attr  = func(k, v string) []otlpAttribute {
+			if v == "" {
+				return nil
+			}
+			return []otlpAttribute{{Key: k, Value: otlpValue{StringValue: v}}}
+		}
+	)
+
+	for _, entry := range entries {
+		i, ok := byApp[entry.App]
+		if !ok {
+			i = len(req.ResourceLogs)
+			byApp[entry.App] = i
+			req.ResourceLogs = append(req.ResourceLogs, otlpResourceLogs{
+				Resource:  otlpResource{Attributes: attr("service.name", entry.App)},
+				ScopeLogs: []otlpScopeLogs{{Scope: scope}},
+			})
+		}
+
+		r := otlpLogRecord{
+			SeverityNumber: otlpSeverity(entry.Level),
+			SeverityText:   entry.Level,
+			Body:           otlpValue{StringValue: entry.Message},
+		}
+		if ts, err := entry.Time(); err == nil {
+			r.TimeUnixNano = strconv.FormatInt(ts.UnixNano(), 10)
+		}
+		r.Attributes = append(r.Attributes, attr("fly.instance", entry.Instance)...)
+		r.Attributes = append(r.Attributes, attr("fly.region", entry.Region)...)
+		r.Attributes = append(r.Attributes, attr("fly.provider", entry.Meta.Event.Provider)...)
+
+		scopeLogs := &req.ResourceLogs[i].ScopeLogs[0]
+		scopeLogs.LogRecords = append(scopeLogs.LogRecords, r)
+	}
+
+	return req
+}
+
+// otlpSeverity maps a level to the first severity number of its OTLP range.
+func otlpSeverity(level string) int {
+	switch strings.ToLower(level) {
+	case "trace":
+		return 1
+	case "debug":
+		return 5
+	case "info", "notice":
+		return 9
+	case "warn", "warning":
+		return 13
+	case "error", "err":
+		return 17
+	case "fatal", "critical", "crit", "panic", "alert", "emergency":
+		return 21
+	default:
+		return 0
+	}
+}
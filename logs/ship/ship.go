@@ -0,0 +1,173 @@
+// Package ship ships log entries to local sinks with at-least-once delivery.
+package ship
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/superfly/flyctl/logs"
+)
+
+// Sink is a destination for log entries. Write must only return once the
+// entries are durably handed over, as the checkpoint moves past them after
+// all sinks accepted them.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, entries []logs.LogEntry) error
+	Close() error
+}
+
+// shutdownFlushTimeout bounds how long the pending batch is retried when
+// the shipper is stopped.
+const shutdownFlushTimeout = 10 * time.Second
+
+// Shipper batches entries and writes them to every sink, retrying failed
+// writes until they succeed.
+type Shipper struct {
+	Sinks         []Sink
+	Checkpoint    *Checkpoint
+	BatchSize     int
+	FlushInterval time.Duration
+	// MaxRetryInterval caps the backoff between retries of a failed write.
+	MaxRetryInterval time.Duration
+	// OnError is called with every failed write, which is retried after.
+	OnError func(sink Sink, err error)
+	// Backfill returns the retained entries since the checkpoint. It's
+	// called once on start when the checkpoint is set, to catch up on the
+	// entries logged while no shipper was running.
+	Backfill func(ctx context.Context, since time.Time) ([]logs.LogEntry, error)
+}
+
+// Run back-fills the entries logged since the checkpoint and ships entries
+// until the stream is closed or ctx is done, flushing the pending batch
+// before it returns. Entries that were already shipped by this run are
+// skipped.
+func (s *Shipper) Run(ctx context.Context, entries <-chan logs.LogEntry) error {
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	flushInterval := s.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+
+	var (
+		seen  = logs.NewDedupe()
+		batch []logs.LogEntry
+	)
+
+	if s.Backfill != nil && s.Checkpoint != nil && !s.Checkpoint.Timestamp.IsZero() {
+		backfill, err := s.Backfill(ctx, s.Checkpoint.Timestamp)
+		if err != nil {
+			return fmt.Errorf("failed back-filling logs: %w", err)
+		}
+		for _, entry := range backfill {
+			if s.Checkpoint.Shipped(entry) || seen.Seen(entry) {
+				continue
+			}
+			if batch = append(batch, entry); len(batch) < batchSize {
+				continue
+			}
+			if err := s.flush(ctx, batch); err != nil {
+				if errors.Is(err, context.Canceled) {
+					return s.shutdown(ctx, batch)
+				}
+				return err
+			}
+			batch = nil
+		}
+	}
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return s.shutdown(ctx, batch)
+		case entry, ok := <-entries:
+			if !ok {
+				return s.flush(ctx, batch)
+			}
+			if seen.Seen(entry) {
+				continue
+			}
+			if batch = append(batch, entry); len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		if err := s.flush(ctx, batch); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return s.shutdown(ctx, batch)
+			}
+			return err
+		}
+		batch = nil
+	}
+}
+
+// shutdown flushes the pending batch once ctx is done, giving up after
+// shutdownFlushTimeout.
+func (s *Shipper) shutdown(ctx context.Context, batch []logs.LogEntry) error {
+	flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownFlushTimeout)
+	defer cancel()
+
+	if err := s.flush(flushCtx, batch); err != nil {
+		return fmt.Errorf("failed flushing %d entries on shutdown: %w", len(batch), err)
+	}
+	return ctx.Err()
+}
+
+func (s *Shipper) flush(ctx context.Context, batch []logs.LogEntry) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	pending := s.Sinks
+	backoff := 100 * time.Millisecond
+	maxBackoff := s.MaxRetryInterval
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+
+	for {
+		var failed []Sink
+		for _, sink := range pending {
+			if err := sink.Write(ctx, batch); err != nil {
+				if errors.Is(err, context.Canceled) {
+					return err
+				}
+				if s.OnError != nil {
+					s.OnError(sink, err)
+				}
+				failed = append(failed, sink)
+			}
+		}
+		if len(failed) == 0 {
+			break
+		}
+		pending = failed
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+
+	if s.Checkpoint == nil {
+		return nil
+	}
+	if err := s.Checkpoint.Advance(batch); err != nil {
+		return fmt.Errorf("failed saving checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package ship
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/superfly/flyctl/logs"
+)
+
+func entry(ts, msg string) logs.LogEntry {
+	e := logs.LogEntry{
+		App:       "app",
+		Level:     "info",
+		Instance:  "148e21f1",
+		Region:    "ord",
+		Message:   msg,
+		Timestamp: ts,
+	}
+	e.Meta.Event.Provider = "app"
+	return e
+}
+
+type fakeSink struct {
+	fail    int
+	written []logs.LogEntry
+}
+
+func (s *fakeSink) Name() string { return "fake" }
+
+func (s *fakeSink) Write(_ context.Context, entries []logs.LogEntry) error {
+	if s.fail > 0 {
+		s.fail--
+		return errors.New("unavailable")
+	}
+	s.written = append(s.written, entries...)
+	return nil
+}
+
+func (s *fakeSink) Close() error { return nil }
+
+func messagesOf(entries []logs.LogEntry) []string {
+	var messages []string
+	for _, e := range entries {
+		messages = append(messages, e.Message)
+	}
+	return messages
+}
+
+func TestShipper(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	checkpoint, err := LoadCheckpoint(path)
+	require.NoError(t, err)
+	start := time.Date(2024, 3, 1, 12, 0, 1, 0, time.UTC)
+	checkpoint.Timestamp = start
+
+	var backfillSince time.Time
+	sink := &fakeSink{fail: 2}
+	shipper := &Shipper{
+		Sinks:            []Sink{sink},
+		Checkpoint:       checkpoint,
+		BatchSize:        2,
+		MaxRetryInterval: time.Millisecond,
+		Backfill: func(_ context.Context, since time.Time) ([]logs.LogEntry, error) {
+			backfillSince = since
+			return []logs.LogEntry{
+				entry("2024-03-01T12:00:00Z", "already shipped"),
+				entry("2024-03-01T12:00:01Z", "same timestamp"),
+				entry("2024-03-01T12:00:02Z", "missed"),
+			}, nil
+		},
+	}
+
+	entries := make(chan logs.LogEntry, 4)
+	entries <- entry("2024-03-01T12:00:02Z", "missed")
+	entries <- entry("2024-03-01T12:00:04Z", "new")
+	entries <- entry("2024-03-01T12:00:03Z", "late")
+	entries <- entry("2024-03-01T12:00:04Z", "new")
+	close(entries)
+
+	require.NoError(t, shipper.Run(context.Background(), entries))
+	require.True(t, backfillSince.Equal(start))
+
+	// Back-filled entries older than the checkpoint and duplicates are
+	// skipped, but live entries are shipped whatever their timestamp.
+	require.Equal(t, []string{"same timestamp", "missed", "new", "late"}, messagesOf(sink.written))
+
+	saved, err := LoadCheckpoint(path)
+	require.NoError(t, err)
+	require.True(t, saved.Timestamp.Equal(time.Date(2024, 3, 1, 12, 0, 4, 0, time.UTC)))
+}
+
+func TestShipper_FlushOnShutdown(t *testing.T) {
+	sink := &fakeSink{}
+	shipper := &Shipper{
+		Sinks:         []Sink{sink},
+		BatchSize:     100,
+		FlushInterval: time.Hour,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entries := make(chan logs.LogEntry)
+
+	done := make(chan error)
+	go func() { done <- shipper.Run(ctx, entries) }()
+
+	entries <- entry("2024-03-01T12:00:00Z", "pending")
+	cancel()
+
+	require.ErrorIs(t, <-done, context.Canceled)
+	require.Equal(t, []string{"pending"}, messagesOf(sink.written))
+}
+
+func TestFileSink_Rotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	// Files sharing the prefix that weren't rotated by the sink are kept
+	require.NoError(t, os.WriteFile(path+".old", []byte("keep me"), 0o644))
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	sink, err := NewFileSink(FileSinkOptions{
+		Path:       path,
+		MaxSize:    10,
+		Compress:   true,
+		MaxBackups: 2,
+	})
+	require.NoError(t, err)
+	sink.now = func() time.Time { return now }
+	defer sink.Close()
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, sink.Write(context.Background(), []logs.LogEntry{entry("2024-03-01T12:00:00Z", "hello")}))
+		now = now.Add(time.Second)
+	}
+
+	backups, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		path + ".20240301T120002.000.gz",
+		path + ".20240301T120003.000.gz",
+		path + ".old",
+	}, backups)
+	backups = backups[:2]
+
+	f, err := os.Open(backups[0])
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	var got logs.LogEntry
+	require.NoError(t, json.NewDecoder(gz).Decode(&got))
+	require.Equal(t, "hello", got.Message)
+}
+
+func TestFileSink_MaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	sink, err := NewFileSink(FileSinkOptions{Path: path, MaxAge: time.Hour})
+	require.NoError(t, err)
+	sink.now = func() time.Time { return now }
+	sink.opened = now
+	defer sink.Close()
+
+	require.NoError(t, sink.Write(context.Background(), []logs.LogEntry{entry("2024-03-01T12:00:00Z", "a")}))
+	now = now.Add(30 * time.Minute)
+	require.NoError(t, sink.Write(context.Background(), []logs.LogEntry{entry("2024-03-01T12:30:00Z", "b")}))
+
+	backups, _ := filepath.Glob(path + ".*")
+	require.Empty(t, backups)
+
+	now = now.Add(time.Hour)
+	require.NoError(t, sink.Write(context.Background(), []logs.LogEntry{entry("2024-03-01T13:30:00Z", "c")}))
+
+	backups, _ = filepath.Glob(path + ".*")
+	require.Len(t, backups, 1)
+}
+
+func TestFormatSyslog(t *testing.T) {
+	cases := []struct {
+		name     string
+		entry    logs.LogEntry
+		expected string
+	}{
+		{
+			name:     "info",
+			entry:    entry("2024-03-01T12:00:00.5Z", "hello world"),
+			expected: "<14>1 2024-03-01T12:00:00.500000Z 148e21f1 app - app - hello world",
+		},
+		{
+			name: "error without instance",
+			entry: logs.LogEntry{
+				App:       "my app",
+				Level:     "error",
+				Message:   "boom",
+				Timestamp: "2024-03-01T12:00:00Z",
+			},
+			expected: "<11>1 2024-03-01T12:00:00.000000Z - myapp - - - boom",
+		},
+		{
+			name:     "invalid timestamp",
+			entry:    logs.LogEntry{Level: "debug", Message: "m"},
+			expected: "<15>1 - - - - - - m",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, FormatSyslog(tc.entry))
+		})
+	}
+}
+
+func TestSyslogSink_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	sink, err := NewSyslogSink("tcp://" + ln.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, sink.Write(context.Background(), []logs.LogEntry{entry("2024-03-01T12:00:00Z", "hi")}))
+	require.NoError(t, sink.Close())
+
+	msg := "<14>1 2024-03-01T12:00:00.000000Z 148e21f1 app - app - hi"
+	require.Equal(t, "57 "+msg, <-received)
+	require.Len(t, msg, 57)
+}
+
+func TestNewSyslogSink(t *testing.T) {
+	sink, err := NewSyslogSink("udp://logs.example.com")
+	require.NoError(t, err)
+	require.Equal(t, "syslog udp://logs.example.com:514", sink.Name())
+
+	_, err = NewSyslogSink("http://logs.example.com")
+	require.Error(t, err)
+}
+
+func TestOTLPSink(t *testing.T) {
+	var (
+		got    otlpRequest
+		header string
+		status = http.StatusOK
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/logs", r.URL.Path)
+		header = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	sink := NewOTLPSink(srv.URL, map[string]string{"Authorization": "Bearer x"})
+
+	other := entry("2024-03-01T12:00:01Z", "from other")
+	other.App = "other"
+	other.Level = "error"
+	entries := []logs.LogEntry{
+		entry("2024-03-01T12:00:00Z", "one"),
+		other,
+		entry("2024-03-01T12:00:02Z", "two"),
+	}
+	require.NoError(t, sink.Write(context.Background(), entries))
+
+	require.Equal(t, "Bearer x", header)
+	require.Len(t, got.ResourceLogs, 2)
+	require.Equal(t, "app", got.ResourceLogs[0].Resource.Attributes[0].Value.StringValue)
+
+	records := got.ResourceLogs[0].ScopeLogs[0].LogRecords
+	require.Len(t, records, 2)
+	require.Equal(t, "one", records[0].Body.StringValue)
+	require.Equal(t, 9, records[0].SeverityNumber)
+	require.Equal(t, "1709294400000000000", records[0].TimeUnixNano)
+
+	records = got.ResourceLogs[1].ScopeLogs[0].LogRecords
+	require.Equal(t, 17, records[0].SeverityNumber)
+
+	status = http.StatusServiceUnavailable
+	require.Error(t, sink.Write(context.Background(), entries))
+}
@@ -0,0 +1,148 @@
+package ship
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/superfly/flyctl/logs"
+)
+
+const (
+	syslogFacilityUser = 1
+	syslogDialTimeout  = 10 * time.Second
+)
+
+// SyslogSink sends entries as RFC 5424 messages over TCP, using octet
+// counting framing, or UDP, one message per datagram.
+type SyslogSink struct {
+	network string
+	addr    string
+	conn    net.Conn
+}
+
+// NewSyslogSink returns a sink for an address like tcp://host:514 or
+// udp://host:514. The connection is established on the first write.
+func NewSyslogSink(addr string) (*SyslogSink, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog address '%s': %w", addr, err)
+	}
+
+	switch u.Scheme {
+	case "tcp", "udp":
+	default:
+		return nil, fmt.Errorf("invalid syslog address '%s': scheme must be tcp or udp", addr)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid syslog address '%s': missing host", addr)
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "514")
+	}
+
+	return &SyslogSink{network: u.Scheme, addr: host}, nil
+}
+
+func (s *SyslogSink) Name() string {
+	return fmt.Sprintf("syslog %s://%s", s.network, s.addr)
+}
+
+func (s *SyslogSink) Write(ctx context.Context, entries []logs.LogEntry) error {
+	if s.conn == nil {
+		dialer := net.Dialer{Timeout: syslogDialTimeout}
+		conn, err := dialer.DialContext(ctx, s.network, s.addr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	for _, entry := range entries {
+		msg := FormatSyslog(entry)
+		if s.network == "tcp" {
+			msg = fmt.Sprintf("%d %s", len(msg), msg)
+		}
+		if _, err := s.conn.Write([]byte(msg)); err != nil {
+			// Reconnect on the next attempt
+			s.Close()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SyslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// FormatSyslog formats entry as an RFC 5424 message. The hostname is the
+// instance the entry comes from and the message ID its provider.
+func FormatSyslog(entry logs.LogEntry) string {
+	pri := syslogFacilityUser*8 + syslogSeverity(entry.Level)
+
+	timestamp := "-"
+	if ts, err := entry.Time(); err == nil {
+		timestamp = ts.UTC().Format("2006-01-02T15:04:05.000000Z07:00")
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s - %s - %s",
+		pri,
+		timestamp,
+		syslogHeaderField(entry.Instance, 255),
+		syslogHeaderField(entry.App, 48),
+		syslogHeaderField(entry.Meta.Event.Provider, 32),
+		entry.Message,
+	)
+}
+
+// syslogHeaderField returns value as a valid header field: printable ASCII
+// without spaces, truncated to max, or - when empty.
+func syslogHeaderField(value string, max int) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, value)
+
+	if value == "" {
+		return "-"
+	}
+	if len(value) > max {
+		value = value[:max]
+	}
+	return value
+}
+
+func syslogSeverity(level string) int {
+	switch strings.ToLower(level) {
+	case "emergency", "panic":
+		return 0
+	case "alert":
+		return 1
+	case "critical", "crit", "fatal":
+		return 2
+	case "error", "err":
+		return 3
+	case "warn", "warning":
+		return 4
+	case "notice":
+		return 5
+	case "debug", "trace":
+		return 7
+	default:
+		return 6
+	}
+}
@@ -0,0 +1,138 @@
+package logs
+
+import (
+	"cmp"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// HTTPStats aggregates the HTTP request metadata of proxy log entries.
+type HTTPStats struct {
+	Requests    int                    `json:"requests"`
+	StatusCodes map[int]int            `json:"status_codes"`
+	Paths       map[string]int         `json:"paths"`
+	Regions     map[string]*GroupStats `json:"regions"`
+	Instances   map[string]*GroupStats `json:"instances"`
+	First       time.Time              `json:"first"`
+	Last        time.Time              `json:"last"`
+}
+
+// GroupStats counts the requests of a region or instance.
+type GroupStats struct {
+	Requests     int `json:"requests"`
+	ClientErrors int `json:"client_errors"`
+	ServerErrors int `json:"server_errors"`
+}
+
+// ErrorRate is the share of requests that failed with a 5xx status.
+func (g GroupStats) ErrorRate() float64 {
+	if g.Requests == 0 {
+		return 0
+	}
+	return float64(g.ServerErrors) / float64(g.Requests)
+}
+
+func (g *GroupStats) add(status int) {
+	g.Requests++
+	switch {
+	case status >= 500:
+		g.ServerErrors++
+	case status >= 400:
+		g.ClientErrors++
+	}
+}
+
+func NewHTTPStats() *HTTPStats {
+	return &HTTPStats{
+		StatusCodes: map[int]int{},
+		Paths:       map[string]int{},
+		Regions:     map[string]*GroupStats{},
+		Instances:   map[string]*GroupStats{},
+	}
+}
+
+// Add counts entry when it describes an HTTP request and reports whether it
+// did.
+func (s *HTTPStats) Add(entry LogEntry) bool {
+	status := entry.Meta.HTTP.Response.StatusCode
+	if status == 0 {
+		return false
+	}
+
+	s.Requests++
+	s.StatusCodes[status]++
+	s.Paths[requestPath(entry.Meta.URL.Full)]++
+	group(s.Regions, entry.Region).add(status)
+	group(s.Instances, entry.Instance).add(status)
+
+	if ts, err := entry.Time(); err == nil {
+		if s.First.IsZero() || ts.Before(s.First) {
+			s.First = ts
+		}
+		if ts.After(s.Last) {
+			s.Last = ts
+		}
+	}
+
+	return true
+}
+
+// Total returns the counts of all regions together.
+func (s *HTTPStats) Total() GroupStats {
+	var total GroupStats
+	for _, g := range s.Regions {
+		total.Requests += g.Requests
+		total.ClientErrors += g.ClientErrors
+		total.ServerErrors += g.ServerErrors
+	}
+	return total
+}
+
+func group(groups map[string]*GroupStats, key string) *GroupStats {
+	if key == "" {
+		key = "unknown"
+	}
+	g, ok := groups[key]
+	if !ok {
+		g = &GroupStats{}
+		groups[key] = g
+	}
+	return g
+}
+
+// requestPath returns the path of a request URL, without the query.
+func requestPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
+
+// Count is a key along with how often it was seen.
+type Count[K cmp.Ordered] struct {
+	Key   K
+	Count int
+}
+
+// TopCounts returns the n most frequent keys of counts, all of them when n is
+// 0, from the most to the least frequent.
+func TopCounts[K cmp.Ordered](counts map[K]int, n int) []Count[K] {
+	top := make([]Count[K], 0, len(counts))
+	for k, c := range counts {
+		top = append(top, Count[K]{Key: k, Count: c})
+	}
+
+	slices.SortFunc(top, func(a, b Count[K]) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+
+	if n > 0 && len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
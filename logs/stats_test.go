@@ -0,0 +1,44 @@
+package logs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPStats(t *testing.T) {
+	request := func(region, instance string, status int, url, ts string) LogEntry {
+		e := LogEntry{Region: region, Instance: instance, Timestamp: "2024-03-01T12:00:" + ts + "Z"}
+		e.Meta.HTTP.Response.StatusCode = status
+		e.Meta.URL.Full = url
+		return e
+	}
+
+	stats := NewHTTPStats()
+	require.False(t, stats.Add(LogEntry{Message: "not a request"}))
+	require.True(t, stats.Add(request("ord", "a", 200, "https://app.fly.dev/", "03")))
+	require.True(t, stats.Add(request("ord", "a", 500, "https://app.fly.dev/api?id=1", "01")))
+	require.True(t, stats.Add(request("ord", "b", 404, "https://app.fly.dev/api?id=2", "02")))
+	require.True(t, stats.Add(request("cdg", "c", 200, "https://app.fly.dev/api", "04")))
+
+	require.Equal(t, 4, stats.Requests)
+	require.Equal(t, map[int]int{200: 2, 404: 1, 500: 1}, stats.StatusCodes)
+	require.Equal(t, []Count[string]{{"/api", 3}, {"/", 1}}, TopCounts(stats.Paths, 0))
+	require.Equal(t, []Count[string]{{"/api", 3}}, TopCounts(stats.Paths, 1))
+
+	require.Equal(t, GroupStats{Requests: 3, ClientErrors: 1, ServerErrors: 1}, *stats.Regions["ord"])
+	require.Equal(t, GroupStats{Requests: 2, ServerErrors: 1}, *stats.Instances["a"])
+	require.InDelta(t, 0.5, stats.Instances["a"].ErrorRate(), 0.001)
+	require.Equal(t, GroupStats{Requests: 4, ClientErrors: 1, ServerErrors: 1}, stats.Total())
+
+	require.Equal(t, "2024-03-01T12:00:01Z", stats.First.UTC().Format("2006-01-02T15:04:05Z07:00"))
+	require.Equal(t, "2024-03-01T12:00:04Z", stats.Last.UTC().Format("2006-01-02T15:04:05Z07:00"))
+}
+
+func TestTopCounts(t *testing.T) {
+	require.Equal(t,
+		[]Count[int]{{200, 5}, {404, 2}, {500, 2}},
+		TopCounts(map[int]int{500: 2, 200: 5, 404: 2}, 0),
+	)
+	require.Empty(t, TopCounts(map[string]int{}, 3))
+}
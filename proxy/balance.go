@@ -0,0 +1,228 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/superfly/flyctl/terminal"
+)
+
+// Load balancing strategies for a Balancer.
+const (
+	RoundRobin       = "round-robin"
+	LeastConnections = "least-connections"
+)
+
+// BalanceStrategies lists the strategies a Balancer accepts.
+var BalanceStrategies = []string{RoundRobin, LeastConnections}
+
+var errNoHealthyTargets = errors.New("no healthy instances to proxy to")
+
+// Balancer spreads connections over the addresses returned by Lookup. Its
+// DialContext has the same signature as a dialer's, so it can be used as a
+// Server's Dial; the address passed to it is ignored in favour of a target
+// chosen by Strategy.
+type Balancer struct {
+	Strategy string
+	Port     string
+	Lookup   func(ctx context.Context) ([]string, error)
+	Dial     func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// ResolveInterval is how often Lookup is called to pick up new and
+	// removed instances. HealthInterval is how often each target is checked
+	// with a TCP dial.
+	ResolveInterval time.Duration
+	HealthInterval  time.Duration
+
+	mu      sync.Mutex
+	targets []*target
+	next    int
+}
+
+type target struct {
+	addr    string
+	active  atomic.Int64
+	healthy atomic.Bool
+}
+
+// Targets returns the current target addresses.
+func (b *Balancer) Targets() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	addrs := make([]string, 0, len(b.targets))
+	for _, t := range b.targets {
+		addrs = append(addrs, t.addr)
+	}
+	return addrs
+}
+
+// Resolve replaces the target list with the current result of Lookup. New
+// targets start out healthy; targets that are still present keep their
+// health and connection counts.
+func (b *Balancer) Resolve(ctx context.Context) error {
+	addrs, err := b.Lookup(ctx)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return errNoHealthyTargets
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	targets := make([]*target, 0, len(addrs))
+	for _, addr := range addrs {
+		addr = net.JoinHostPort(addr, b.Port)
+
+		i := slices.IndexFunc(b.targets, func(t *target) bool { return t.addr == addr })
+		if i >= 0 {
+			targets = append(targets, b.targets[i])
+			continue
+		}
+
+		t := &target{addr: addr}
+		t.healthy.Store(true)
+		targets = append(targets, t)
+	}
+	b.targets = targets
+
+	return nil
+}
+
+// Run re-resolves and health checks targets until ctx is cancelled.
+func (b *Balancer) Run(ctx context.Context) {
+	resolve := time.NewTicker(durationOr(b.ResolveInterval, 30*time.Second))
+	defer resolve.Stop()
+	health := time.NewTicker(durationOr(b.HealthInterval, 10*time.Second))
+	defer health.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-resolve.C:
+			if err := b.Resolve(ctx); err != nil {
+				terminal.Debug("failed resolving proxy targets: ", err)
+			}
+		case <-health.C:
+			b.checkHealth(ctx)
+		}
+	}
+}
+
+func (b *Balancer) checkHealth(ctx context.Context) {
+	b.mu.Lock()
+	targets := slices.Clone(b.targets)
+	b.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t *target) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+
+			conn, err := b.Dial(ctx, "tcp", t.addr)
+			if err == nil {
+				conn.Close()
+			}
+			if healthy := err == nil; t.healthy.Swap(healthy) != healthy {
+				terminal.Debugf("proxy target %s healthy: %t\n", t.addr, healthy)
+			}
+		}(t)
+	}
+	wg.Wait()
+}
+
+// pick chooses a healthy target that isn't in tried.
+func (b *Balancer) pick(tried map[*target]bool) *target {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var best *target
+	for i := range b.targets {
+		t := b.targets[(b.next+i)%len(b.targets)]
+		if !t.healthy.Load() || tried[t] {
+			continue
+		}
+		if b.Strategy != LeastConnections {
+			best = t
+			break
+		}
+		if best == nil || t.active.Load() < best.active.Load() {
+			best = t
+		}
+	}
+
+	if best != nil {
+		b.next = (slices.Index(b.targets, best) + 1) % len(b.targets)
+	}
+	return best
+}
+
+// DialContext connects to a healthy target, trying the others in turn if
+// the dial fails. Targets that fail to dial are marked unhealthy until the
+// next health check.
+func (b *Balancer) DialContext(ctx context.Context, network, _ string) (net.Conn, error) {
+	tried := map[*target]bool{}
+	for {
+		t := b.pick(tried)
+		if t == nil {
+			return nil, errNoHealthyTargets
+		}
+		tried[t] = true
+
+		conn, err := b.Dial(ctx, network, t.addr)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			terminal.Debugf("failed dialing proxy target %s: %v\n", t.addr, err)
+			t.healthy.Store(false)
+			continue
+		}
+
+		t.active.Add(1)
+		return &balancedConn{Conn: conn, target: t}, nil
+	}
+}
+
+func (b *Balancer) String() string {
+	return fmt.Sprintf("%d instances (%s)", len(b.Targets()), b.Strategy)
+}
+
+// balancedConn tracks a target's active connections.
+type balancedConn struct {
+	net.Conn
+	target *target
+	once   sync.Once
+}
+
+func (c *balancedConn) Close() error {
+	c.once.Do(func() { c.target.active.Add(-1) })
+	return c.Conn.Close()
+}
+
+func (c *balancedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(ClosableWrite); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+func durationOr(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTargets is a dial func that records which address each connection
+// went to and fails for addresses marked down.
+type fakeTargets struct {
+	mu     sync.Mutex
+	down   map[string]bool
+	dialed []string
+}
+
+func (f *fakeTargets) dial(_ context.Context, _, addr string) (net.Conn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.down[addr] {
+		return nil, errors.New("connection refused")
+	}
+	f.dialed = append(f.dialed, addr)
+
+	a, b := net.Pipe()
+	b.Close()
+	return a, nil
+}
+
+func (f *fakeTargets) setDown(addr string, down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down[addr] = down
+}
+
+func newTestBalancer(t *testing.T, strategy string, addrs ...string) (*Balancer, *fakeTargets) {
+	f := &fakeTargets{down: map[string]bool{}}
+	b := &Balancer{
+		Strategy: strategy,
+		Port:     "80",
+		Dial:     f.dial,
+		Lookup: func(context.Context) ([]string, error) {
+			return addrs, nil
+		},
+	}
+	require.NoError(t, b.Resolve(context.Background()))
+	return b, f
+}
+
+func dialN(t *testing.T, b *Balancer, n int) []net.Conn {
+	var conns []net.Conn
+	for i := 0; i < n; i++ {
+		conn, err := b.DialContext(context.Background(), "tcp", "ignored")
+		require.NoError(t, err)
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+func TestBalancer_RoundRobin(t *testing.T) {
+	b, f := newTestBalancer(t, RoundRobin, "fdaa::1", "fdaa::2", "fdaa::3")
+
+	dialN(t, b, 4)
+	assert.Equal(t, []string{"[fdaa::1]:80", "[fdaa::2]:80", "[fdaa::3]:80", "[fdaa::1]:80"}, f.dialed)
+}
+
+func TestBalancer_LeastConnections(t *testing.T) {
+	b, f := newTestBalancer(t, LeastConnections, "fdaa::1", "fdaa::2")
+
+	conns := dialN(t, b, 2)
+	// free up the second target; it should get the next connection while
+	// the first is still busy.
+	conns[1].Close()
+	dialN(t, b, 1)
+
+	assert.Equal(t, []string{"[fdaa::1]:80", "[fdaa::2]:80", "[fdaa::2]:80"}, f.dialed)
+}
+
+func TestBalancer_FailoverAndHealthCheck(t *testing.T) {
+	b, f := newTestBalancer(t, RoundRobin, "fdaa::1", "fdaa::2")
+	f.setDown("[fdaa::1]:80", true)
+
+	dialN(t, b, 3)
+	assert.Equal(t, []string{"[fdaa::2]:80", "[fdaa::2]:80", "[fdaa::2]:80"}, f.dialed)
+
+	f.setDown("[fdaa::2]:80", true)
+	_, err := b.DialContext(context.Background(), "tcp", "ignored")
+	assert.ErrorIs(t, err, errNoHealthyTargets)
+
+	// a passing health check brings the target back into rotation
+	f.setDown("[fdaa::1]:80", false)
+	b.checkHealth(context.Background())
+	f.dialed = nil
+	dialN(t, b, 1)
+	assert.Equal(t, []string{"[fdaa::1]:80"}, f.dialed)
+}
+
+func TestBalancer_ResolveKeepsState(t *testing.T) {
+	addrs := []string{"fdaa::1", "fdaa::2"}
+	f := &fakeTargets{down: map[string]bool{}}
+	b := &Balancer{
+		Strategy: LeastConnections,
+		Port:     "80",
+		Dial:     f.dial,
+		Lookup: func(context.Context) ([]string, error) {
+			return addrs, nil
+		},
+	}
+	require.NoError(t, b.Resolve(context.Background()))
+	dialN(t, b, 1)
+
+	addrs = []string{"fdaa::1", "fdaa::3"}
+	require.NoError(t, b.Resolve(context.Background()))
+	assert.Equal(t, []string{"[fdaa::1]:80", "[fdaa::3]:80"}, b.Targets())
+
+	// fdaa::1 still has an active connection, so the new target is chosen
+	f.dialed = nil
+	dialN(t, b, 1)
+	assert.Equal(t, []string{"[fdaa::3]:80"}, f.dialed)
+
+	addrs = nil
+	assert.ErrorIs(t, b.Resolve(context.Background()), errNoHealthyTargets)
+}
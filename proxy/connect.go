@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
@@ -22,6 +23,12 @@ type ConnectParams struct {
 	RemoteHost       string
 	PromptInstance   bool
 	DisableSpinner   bool
+
+	// Network is "tcp" (the default) or "udp".
+	Network string
+	// Balance, when set to one of BalanceStrategies, spreads connections
+	// over all of the app's instances instead of a single remote host.
+	Balance string
 }
 
 // Binds to a local port and runs a proxy to a remote address over Wireguard.
@@ -52,9 +59,17 @@ func Start(ctx context.Context, p *ConnectParams) error {
 }
 
 func NewServer(ctx context.Context, p *ConnectParams) (*Server, error) {
+	agentclient, err := agent.Establish(ctx, fly.ClientFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return newServer(ctx, p, agentclient)
+}
+
+func newServer(ctx context.Context, p *ConnectParams, agentclient *agent.Client) (*Server, error) {
 	var (
 		io            = iostreams.FromContext(ctx)
-		client        = fly.ClientFromContext(ctx)
 		orgSlug       = p.OrganizationSlug
 		localBindAddr = p.BindAddr
 		localPort     = p.Ports[0]
@@ -66,9 +81,32 @@ func NewServer(ctx context.Context, p *ConnectParams) (*Server, error) {
 		remotePort = p.Ports[1]
 	}
 
-	agentclient, err := agent.Establish(ctx, client)
-	if err != nil {
-		return nil, err
+	dial := p.Dialer.DialContext
+
+	if p.Balance != "" {
+		if err := checkBalance(p, p.RemoteHost, p.Network); err != nil {
+			return nil, err
+		}
+
+		balancer := &Balancer{
+			Strategy: p.Balance,
+			Port:     remotePort,
+			Dial:     p.Dialer.DialContext,
+			Lookup: func(ctx context.Context) ([]string, error) {
+				instances, err := agentclient.Instances(ctx, orgSlug, p.AppName)
+				if err != nil {
+					return nil, err
+				}
+				return instances.Addresses, nil
+			},
+		}
+		if err := balancer.Resolve(ctx); err != nil {
+			return nil, fmt.Errorf("look up %s: %w", p.AppName, err)
+		}
+		go balancer.Run(ctx)
+
+		dial = balancer.DialContext
+		remoteAddr = balancer.String()
 	}
 
 	// Prompt for a specific instance and set it as the remote target
@@ -94,6 +132,30 @@ func NewServer(ctx context.Context, p *ConnectParams) (*Server, error) {
 		remoteAddr = fmt.Sprintf("[%s]:%s", p.RemoteHost, remotePort)
 	}
 
+	if p.Network == "udp" {
+		if _, err := strconv.Atoi(localPort); err != nil {
+			return nil, fmt.Errorf("udp proxies need a numeric local port, got %q", localPort)
+		}
+
+		addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%s", localBindAddr, localPort))
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(io.Out, "Proxying local port %s/udp to remote %s\n", localPort, remoteAddr)
+
+		return &Server{
+			Addr:       remoteAddr,
+			PacketConn: conn,
+			Dial:       dial,
+		}, nil
+	}
+
 	var listener net.Listener
 
 	if _, err := strconv.Atoi(localPort); err == nil {
@@ -125,10 +187,23 @@ func NewServer(ctx context.Context, p *ConnectParams) (*Server, error) {
 	return &Server{
 		Addr:     remoteAddr,
 		Listener: listener,
-		Dial:     p.Dialer.DialContext,
+		Dial:     dial,
 	}, nil
 }
 
+// checkBalance reports whether connections to host can be balanced over the
+// instances of p.AppName, which is the only app whose instances the balancer
+// looks up.
+func checkBalance(p *ConnectParams, host, network string) error {
+	if network == "udp" {
+		return errors.New("load balancing is only supported for tcp proxies")
+	}
+	if host != "" && host != p.AppName+".internal" {
+		return fmt.Errorf("load balancing only spreads connections over the instances of %s, remove the host %s from the mapping", p.AppName, host)
+	}
+	return nil
+}
+
 func selectInstance(ctx context.Context, org, app string, c *agent.Client) (instance string, err error) {
 	instances, err := c.Instances(ctx, org, app)
 	if err != nil {
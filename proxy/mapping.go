@@ -0,0 +1,218 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/sync/errgroup"
+
+	fly "github.com/superfly/fly-go"
+	"github.com/superfly/flyctl/agent"
+)
+
+// Mapping forwards a local port (or unix socket path) to a port on a remote
+// host reachable over the organization's WireGuard network.
+type Mapping struct {
+	LocalPort  string
+	RemoteHost string
+	RemotePort string
+	Protocol   string
+}
+
+func (m Mapping) String() string {
+	s := fmt.Sprintf("%s:%s:%s", m.LocalPort, m.RemoteHost, m.RemotePort)
+	if m.Protocol == "udp" {
+		s += "/udp"
+	}
+	return s
+}
+
+// ParseMapping parses a mapping in one of the forms "local", "local:remote"
+// or "local:host:remote", optionally followed by "/tcp" or "/udp". IPv6 hosts
+// must be bracketed, e.g. "8080:[fdaa::3]:80". Bare hosts without a domain,
+// such as "web", are expanded to "web.internal". defaultHost is used when the
+// spec doesn't name a host.
+func ParseMapping(spec, defaultHost string) (Mapping, error) {
+	protocol := "tcp"
+	if i := strings.LastIndex(spec, "/"); i >= 0 {
+		switch p := spec[i+1:]; p {
+		case "tcp", "udp":
+			protocol = p
+			spec = spec[:i]
+		}
+	}
+
+	local, rest, hasRemote := strings.Cut(spec, ":")
+	if local == "" {
+		return Mapping{}, fmt.Errorf("invalid mapping %q: missing local port", spec)
+	}
+
+	m := Mapping{LocalPort: local, RemoteHost: defaultHost, RemotePort: local, Protocol: protocol}
+
+	if hasRemote {
+		host, port := "", rest
+		if strings.HasPrefix(rest, "[") {
+			end := strings.Index(rest, "]:")
+			if end < 0 {
+				return Mapping{}, fmt.Errorf("invalid mapping %q: expected [host]:port", spec)
+			}
+			host, port = rest[1:end], rest[end+2:]
+		} else if i := strings.LastIndex(rest, ":"); i >= 0 {
+			host, port = rest[:i], rest[i+1:]
+			if host == "" {
+				return Mapping{}, fmt.Errorf("invalid mapping %q: missing remote host", spec)
+			}
+		}
+
+		if host != "" {
+			m.RemoteHost = expandHost(host)
+		}
+		m.RemotePort = port
+	}
+
+	if _, err := strconv.Atoi(m.RemotePort); err != nil {
+		return Mapping{}, fmt.Errorf("invalid mapping %q: remote port %q is not a number", spec, m.RemotePort)
+	}
+	if m.RemoteHost == "" {
+		return Mapping{}, fmt.Errorf("invalid mapping %q: no remote host", spec)
+	}
+
+	return m, nil
+}
+
+// ParseMappings parses each spec with ParseMapping and rejects mappings that
+// would bind the same local port twice.
+func ParseMappings(specs []string, defaultHost string) ([]Mapping, error) {
+	var (
+		mappings = make([]Mapping, 0, len(specs))
+		seen     = map[string]bool{}
+	)
+
+	for _, spec := range specs {
+		m, err := ParseMapping(spec, defaultHost)
+		if err != nil {
+			return nil, err
+		}
+		key := m.LocalPort + "/" + m.Protocol
+		if seen[key] {
+			return nil, fmt.Errorf("local port %s/%s is mapped more than once", m.LocalPort, m.Protocol)
+		}
+		seen[key] = true
+		mappings = append(mappings, m)
+	}
+
+	return mappings, nil
+}
+
+func expandHost(host string) string {
+	if net.ParseIP(host) != nil || strings.Contains(host, ".") {
+		return host
+	}
+	return host + ".internal"
+}
+
+// Profile describes a set of tunnels that can be brought up together. It's
+// read from the [proxy] table of a TOML file:
+//
+//	[proxy]
+//	org = "personal"
+//	bind_addr = "127.0.0.1"
+//	socks = "1080"
+//	mappings = [
+//	  "5432:db.internal:5432",
+//	  "6379:redis.internal:6379",
+//	  "8080:web:80",
+//	]
+type Profile struct {
+	App      string   `toml:"app"`
+	Org      string   `toml:"org"`
+	BindAddr string   `toml:"bind_addr"`
+	Mappings []string `toml:"mappings"`
+	// Socks is the local port for a SOCKS5 proxy, as with fly proxy --socks.
+	Socks string `toml:"socks"`
+}
+
+// LoadProfile reads a proxy profile from path.
+func LoadProfile(path string) (*Profile, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Proxy *Profile `toml:"proxy"`
+	}
+	if err := toml.Unmarshal(buf, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if file.Proxy == nil || (len(file.Proxy.Mappings) == 0 && file.Proxy.Socks == "") {
+		return nil, fmt.Errorf("%s: no mappings or socks port in [proxy] section", path)
+	}
+
+	return file.Proxy, nil
+}
+
+// ConnectMappings binds a listener for each mapping and proxies them all over
+// the dialer in p, ignoring p.Ports and p.RemoteHost. Blocks until context is
+// cancelled or one of the proxies fails.
+func ConnectMappings(ctx context.Context, p *ConnectParams, mappings []Mapping) error {
+	if len(mappings) == 0 {
+		return errors.New("no port mappings to proxy")
+	}
+	if p.PromptInstance && len(mappings) > 1 {
+		return errors.New("selecting an instance is only supported with a single mapping")
+	}
+
+	if p.Balance != "" {
+		for _, m := range mappings {
+			if err := checkBalance(p, m.RemoteHost, m.Protocol); err != nil {
+				return fmt.Errorf("%s: %w", m, err)
+			}
+		}
+	}
+
+	agentclient, err := agent.Establish(ctx, fly.ClientFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	servers := make([]*Server, 0, len(mappings))
+	defer func() {
+		// ProxyServer closes its own listener; this only matters if binding a
+		// later mapping failed.
+		if len(servers) < len(mappings) {
+			for _, s := range servers {
+				s.Close()
+			}
+		}
+	}()
+
+	for _, m := range mappings {
+		params := *p
+		params.Ports = []string{m.LocalPort, m.RemotePort}
+		params.RemoteHost = m.RemoteHost
+		params.Network = m.Protocol
+
+		server, err := newServer(ctx, &params, agentclient)
+		if err != nil {
+			return fmt.Errorf("%s: %w", m, err)
+		}
+		servers = append(servers, server)
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	for _, s := range servers {
+		s := s
+		eg.Go(func() error {
+			return s.ProxyServer(ctx)
+		})
+	}
+
+	return eg.Wait()
+}
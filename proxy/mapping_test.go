@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMapping(t *testing.T) {
+	cases := []struct {
+		spec string
+		want Mapping
+		err  bool
+	}{
+		{spec: "5432", want: Mapping{"5432", "app.internal", "5432", "tcp"}},
+		{spec: "5432:5433", want: Mapping{"5432", "app.internal", "5433", "tcp"}},
+		{spec: "5432:db.internal:5432", want: Mapping{"5432", "db.internal", "5432", "tcp"}},
+		{spec: "8080:web:80", want: Mapping{"8080", "web.internal", "80", "tcp"}},
+		{spec: "8080:[fdaa::3]:80", want: Mapping{"8080", "fdaa::3", "80", "tcp"}},
+		{spec: "8080:10.0.0.1:80", want: Mapping{"8080", "10.0.0.1", "80", "tcp"}},
+		{spec: "/tmp/pg.sock:db.internal:5432", want: Mapping{"/tmp/pg.sock", "db.internal", "5432", "tcp"}},
+		{spec: "5353:dns.internal:53/udp", want: Mapping{"5353", "dns.internal", "53", "udp"}},
+		{spec: "8125/udp", want: Mapping{"8125", "app.internal", "8125", "udp"}},
+		{spec: "8080:web:80/tcp", want: Mapping{"8080", "web.internal", "80", "tcp"}},
+		{spec: "", err: true},
+		{spec: "8080:web:http", err: true},
+		{spec: "8080::80", err: true},
+		{spec: "8080:[fdaa::3]80", err: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.spec, func(t *testing.T) {
+			got, err := ParseMapping(tc.spec, "app.internal")
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseMapping_NoDefaultHost(t *testing.T) {
+	_, err := ParseMapping("5432", "")
+	assert.Error(t, err)
+
+	m, err := ParseMapping("5432:db:5432", "")
+	require.NoError(t, err)
+	assert.Equal(t, "db.internal", m.RemoteHost)
+}
+
+func TestParseMappings_DuplicateLocalPort(t *testing.T) {
+	_, err := ParseMappings([]string{"5432:db:5432", "5432:other:5432"}, "")
+	assert.ErrorContains(t, err, "mapped more than once")
+
+	_, err = ParseMappings([]string{"5353:dns:53", "5353:dns:53/udp"}, "")
+	assert.NoError(t, err)
+}
+
+func TestConnectMappings_BalanceRejectsOtherHosts(t *testing.T) {
+	p := &ConnectParams{AppName: "web", Balance: RoundRobin}
+
+	mappings, err := ParseMappings([]string{"8080:80", "5432:db:5432"}, "web.internal")
+	require.NoError(t, err)
+	err = ConnectMappings(context.Background(), p, mappings)
+	require.ErrorContains(t, err, "5432:db.internal:5432: load balancing only spreads connections over the instances of web")
+
+	mappings, err = ParseMappings([]string{"5353:53/udp"}, "web.internal")
+	require.NoError(t, err)
+	err = ConnectMappings(context.Background(), p, mappings)
+	require.ErrorContains(t, err, "only supported for tcp proxies")
+}
+
+func TestLoadProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.toml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+[proxy]
+org = "personal"
+bind_addr = "0.0.0.0"
+mappings = ["5432:db.internal:5432", "6379:redis.internal:6379"]
+`), 0o600))
+
+	profile, err := LoadProfile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "personal", profile.Org)
+	assert.Equal(t, "0.0.0.0", profile.BindAddr)
+	assert.Equal(t, []string{"5432:db.internal:5432", "6379:redis.internal:6379"}, profile.Mappings)
+
+	require.NoError(t, os.WriteFile(path, []byte("[other]\n"), 0o600))
+	_, err = LoadProfile(path)
+	assert.ErrorContains(t, err, "no mappings")
+}
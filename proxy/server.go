@@ -16,9 +16,27 @@ type Server struct {
 	Addr      string
 	Listener  net.Listener
 	Dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// PacketConn, when set instead of Listener, makes the server forward UDP
+	// datagrams to Addr. Each client address gets its own upstream session,
+	// which is closed after IdleTimeout without traffic in either direction.
+	PacketConn  net.PacketConn
+	IdleTimeout time.Duration
+}
+
+// Close closes the server's local listener or packet connection.
+func (srv *Server) Close() error {
+	if srv.PacketConn != nil {
+		return srv.PacketConn.Close()
+	}
+	return srv.Listener.Close()
 }
 
 func (srv *Server) ProxyServer(ctx context.Context) error {
+	if srv.PacketConn != nil {
+		return srv.proxyPackets(ctx)
+	}
+
 	defer srv.Listener.Close() //skipcq: GO-S2307
 
 	for {
@@ -56,29 +74,35 @@ func (srv *Server) ProxyServer(ctx context.Context) error {
 				}
 				defer target.Close() //skipcq: GO-S2307
 
-				wg := &sync.WaitGroup{}
-
-				wg.Add(2)
+				pipe(source, target)
 
-				copyFunc := func(dst net.Conn, src net.Conn) {
-					defer wg.Done()
-					io.Copy(dst, src)
+				terminal.Debug("connection closed")
+			}()
+		}
+	}
+}
 
-					// close the write half if it exports a CloseWrite() method
-					if conn, ok := dst.(ClosableWrite); ok {
-						conn.CloseWrite()
-					}
-				}
+// pipe copies data in both directions until both sides are done, closing
+// the write half of each connection once its source is exhausted.
+func pipe(source, target net.Conn) {
+	wg := &sync.WaitGroup{}
 
-				go copyFunc(target, source)
-				go copyFunc(source, target)
+	wg.Add(2)
 
-				wg.Wait()
+	copyFunc := func(dst net.Conn, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
 
-				terminal.Debug("connection closed")
-			}()
+		// close the write half if it exports a CloseWrite() method
+		if conn, ok := dst.(ClosableWrite); ok {
+			conn.CloseWrite()
 		}
 	}
+
+	go copyFunc(target, source)
+	go copyFunc(source, target)
+
+	wg.Wait()
 }
 
 type ClosableWrite interface {
@@ -0,0 +1,279 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/superfly/flyctl/terminal"
+)
+
+// SocksServer is a local SOCKS5 and HTTP CONNECT proxy into an organization's
+// private network. Both protocols are served on the same listener; the first
+// byte a client sends decides which one it speaks.
+type SocksServer struct {
+	Listener net.Listener
+
+	// Resolve maps a host name such as "db.internal" or "app.flycast" to an
+	// address on the private network.
+	Resolve func(ctx context.Context, host string) (string, error)
+	// Dial connects to addresses on the private network.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	// Direct, when set, connects to every other host, bypassing the private
+	// network. Connections to other hosts are refused otherwise, so that the
+	// proxy can't be used to reach the internet from wherever it runs.
+	Direct func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// errNotAllowed is returned for connections to public hosts without Direct.
+var errNotAllowed = errors.New("only hosts on the private network can be proxied to")
+
+// privateNet is the range of 6PN addresses, which are only reachable over
+// WireGuard.
+var privateNet = netip.MustParsePrefix("fdaa::/16")
+
+// isPrivateHost reports whether host is a private DNS name or a 6PN address.
+func isPrivateHost(host string) bool {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return privateNet.Contains(addr)
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return strings.HasSuffix(host, ".internal") || strings.HasSuffix(host, ".flycast")
+}
+
+const socksVersion = 5
+
+// SOCKS5 reply codes, RFC 1928 section 6.
+const (
+	socksSucceeded           = 0x00
+	socksGeneralFailure      = 0x01
+	socksNotAllowed          = 0x02
+	socksHostUnreachable     = 0x04
+	socksCommandNotSupported = 0x07
+	socksAddrNotSupported    = 0x08
+)
+
+// Serve accepts connections until ctx is cancelled.
+func (srv *SocksServer) Serve(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		srv.Listener.Close()
+	}()
+
+	for {
+		conn, err := srv.Listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		go func() {
+			defer conn.Close() //skipcq: GO-S2307
+
+			if err := srv.serveConn(ctx, conn); err != nil {
+				terminal.Debug("proxy connection failed: ", err)
+			}
+		}()
+	}
+}
+
+func (srv *SocksServer) serveConn(ctx context.Context, conn net.Conn) error {
+	r := bufio.NewReader(conn)
+
+	first, err := r.Peek(1)
+	if err != nil {
+		return err
+	}
+
+	client := &bufferedConn{Conn: conn, r: r}
+	if first[0] == socksVersion {
+		return srv.serveSocks(ctx, client)
+	}
+	return srv.serveHTTP(ctx, client)
+}
+
+func (srv *SocksServer) serveSocks(ctx context.Context, conn *bufferedConn) error {
+	// greeting: VER NMETHODS METHODS...
+	var hdr [2]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return err
+	}
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return err
+	}
+
+	noAuth := false
+	for _, m := range methods {
+		noAuth = noAuth || m == 0x00
+	}
+	if !noAuth {
+		conn.Write([]byte{socksVersion, 0xff})
+		return errors.New("socks: client doesn't support unauthenticated access")
+	}
+	if _, err := conn.Write([]byte{socksVersion, 0x00}); err != nil {
+		return err
+	}
+
+	// request: VER CMD RSV ATYP DST.ADDR DST.PORT
+	var req [4]byte
+	if _, err := io.ReadFull(conn, req[:]); err != nil {
+		return err
+	}
+
+	var host string
+	switch req[3] {
+	case 0x01, 0x04:
+		ip := make(net.IP, 4)
+		if req[3] == 0x04 {
+			ip = make(net.IP, 16)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return err
+		}
+		host = ip.String()
+	case 0x03:
+		var l [1]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return err
+		}
+		name := make([]byte, l[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return err
+		}
+		host = string(name)
+	default:
+		socksReply(conn, socksAddrNotSupported)
+		return fmt.Errorf("socks: unsupported address type %d", req[3])
+	}
+
+	var port [2]byte
+	if _, err := io.ReadFull(conn, port[:]); err != nil {
+		return err
+	}
+
+	if req[1] != 0x01 {
+		socksReply(conn, socksCommandNotSupported)
+		return fmt.Errorf("socks: unsupported command %d", req[1])
+	}
+
+	target, err := srv.dial(ctx, host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:]))))
+	if err != nil {
+		code := byte(socksGeneralFailure)
+		var dnsErr *net.DNSError
+		switch {
+		case errors.Is(err, errNotAllowed):
+			code = socksNotAllowed
+		case errors.As(err, &dnsErr):
+			code = socksHostUnreachable
+		}
+		socksReply(conn, code)
+		return err
+	}
+	defer target.Close() //skipcq: GO-S2307
+
+	if err := socksReply(conn, socksSucceeded); err != nil {
+		return err
+	}
+
+	pipe(conn, target)
+
+	return nil
+}
+
+func socksReply(w io.Writer, code byte) error {
+	_, err := w.Write([]byte{socksVersion, code, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+func (srv *SocksServer) serveHTTP(ctx context.Context, conn *bufferedConn) error {
+	req, err := http.ReadRequest(conn.r)
+	if err != nil {
+		return err
+	}
+
+	if req.Method != http.MethodConnect {
+		fmt.Fprint(conn, "HTTP/1.1 405 Method Not Allowed\r\nAllow: CONNECT\r\nContent-Length: 0\r\n\r\n")
+		return fmt.Errorf("http: unsupported method %s", req.Method)
+	}
+
+	host, port, err := net.SplitHostPort(req.Host)
+	if err != nil {
+		fmt.Fprint(conn, "HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\n\r\n")
+		return err
+	}
+
+	target, err := srv.dial(ctx, host, port)
+	if err != nil {
+		if errors.Is(err, errNotAllowed) {
+			fmt.Fprint(conn, "HTTP/1.1 403 Forbidden\r\nContent-Length: 0\r\n\r\n")
+		} else {
+			fmt.Fprint(conn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+		}
+		return err
+	}
+	defer target.Close() //skipcq: GO-S2307
+
+	if _, err := fmt.Fprint(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return err
+	}
+
+	pipe(conn, target)
+
+	return nil
+}
+
+// dial connects to private hosts through the private network, resolving
+// names with its DNS, and to every other host directly if srv.Direct is set.
+func (srv *SocksServer) dial(ctx context.Context, host, port string) (net.Conn, error) {
+	if !isPrivateHost(host) {
+		if srv.Direct == nil {
+			return nil, fmt.Errorf("%s: %w", host, errNotAllowed)
+		}
+
+		terminal.Debug("connecting directly to ", net.JoinHostPort(host, port))
+
+		return srv.Direct(ctx, "tcp", net.JoinHostPort(host, port))
+	}
+
+	if net.ParseIP(host) == nil {
+		addr, err := srv.Resolve(ctx, host)
+		if err != nil {
+			return nil, &net.DNSError{Err: err.Error(), Name: host, IsNotFound: true}
+		}
+		host = addr
+	}
+
+	terminal.Debug("proxying connection to ", net.JoinHostPort(host, port))
+
+	return srv.Dial(ctx, "tcp", net.JoinHostPort(host, port))
+}
+
+// bufferedConn reads through r, which may hold bytes already consumed from
+// the connection while detecting the protocol.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c *bufferedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(ClosableWrite); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	xproxy "golang.org/x/net/proxy"
+)
+
+func startTCPEcho(t *testing.T) (port string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	_, port, _ = net.SplitHostPort(ln.Addr().String())
+	return port
+}
+
+// startSocks serves a proxy whose private network is the loopback interface,
+// connecting to public hosts when direct is set. Every connection made through
+// it is recorded in dialed, prefixed with "tunnel " or "direct ".
+func startSocks(t *testing.T, direct bool) (addr string, dialed *[]string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var (
+		mu  sync.Mutex
+		log []string
+	)
+	recordDial := func(via string) func(context.Context, string, string) (net.Conn, error) {
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			mu.Lock()
+			log = append(log, via+" "+addr)
+			mu.Unlock()
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		}
+	}
+
+	srv := &SocksServer{
+		Listener: ln,
+		Resolve: func(_ context.Context, host string) (string, error) {
+			if host == "echo.internal" {
+				return "127.0.0.1", nil
+			}
+			return "", errors.New("no such host")
+		},
+		Dial: recordDial("tunnel"),
+	}
+	if direct {
+		srv.Direct = recordDial("direct")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+
+	return ln.Addr().String(), &log
+}
+
+func echoes(t *testing.T, conn net.Conn) {
+	t.Helper()
+
+	_, err := conn.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+}
+
+func TestSocksServer_SOCKS5(t *testing.T) {
+	port := startTCPEcho(t)
+	addr, dialed := startSocks(t, true)
+	dialer, err := xproxy.SOCKS5("tcp", addr, nil, xproxy.Direct)
+	require.NoError(t, err)
+
+	for _, host := range []string{"echo.internal", "127.0.0.1"} {
+		conn, err := dialer.Dial("tcp", net.JoinHostPort(host, port))
+		require.NoError(t, err, host)
+		echoes(t, conn)
+		conn.Close()
+	}
+
+	_, err = dialer.Dial("tcp", net.JoinHostPort("missing.internal", port))
+	assert.ErrorContains(t, err, "host unreachable")
+
+	assert.Equal(t, []string{
+		"tunnel " + net.JoinHostPort("127.0.0.1", port),
+		"direct " + net.JoinHostPort("127.0.0.1", port),
+	}, *dialed)
+}
+
+func TestSocksServer_RefusesPublicHosts(t *testing.T) {
+	port := startTCPEcho(t)
+	addr, dialed := startSocks(t, false)
+
+	dialer, err := xproxy.SOCKS5("tcp", addr, nil, xproxy.Direct)
+	require.NoError(t, err)
+	_, err = dialer.Dial("tcp", net.JoinHostPort("127.0.0.1", port))
+	assert.ErrorContains(t, err, "connection not allowed by ruleset")
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "CONNECT 127.0.0.1:%s HTTP/1.1\r\nHost: 127.0.0.1:%s\r\n\r\n", port, port)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	assert.Empty(t, *dialed)
+}
+
+func TestSocksServer_HTTPConnect(t *testing.T) {
+	port := startTCPEcho(t)
+
+	addr, _ := startSocks(t, false)
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "CONNECT echo.internal:%s HTTP/1.1\r\nHost: echo.internal:%s\r\n\r\n", port, port)
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	echoes(t, &bufferedConn{Conn: conn, r: r})
+}
+
+func TestSocksServer_HTTPRejectsOtherMethods(t *testing.T) {
+	addr, _ := startSocks(t, false)
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	fmt.Fprint(conn, "GET http://echo.internal/ HTTP/1.1\r\nHost: echo.internal\r\n\r\n")
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestIsPrivateHost(t *testing.T) {
+	cases := map[string]bool{
+		"db.internal":    true,
+		"DB.Internal.":   true,
+		"web.flycast":    true,
+		"fdaa:0:1::3":    true,
+		"fly.io":         false,
+		"internal.io":    false,
+		"127.0.0.1":      false,
+		"2a09:8280:1::1": false,
+	}
+
+	for host, private := range cases {
+		assert.Equal(t, private, isPrivateHost(host), host)
+	}
+}
@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/superfly/flyctl/terminal"
+)
+
+const defaultUDPIdleTimeout = time.Minute
+
+// udpSession relays datagrams between one local client and the remote
+// address.
+type udpSession struct {
+	client   net.Addr
+	target   net.Conn
+	lastSeen atomic.Int64
+}
+
+func (s *udpSession) touch() {
+	s.lastSeen.Store(time.Now().UnixNano())
+}
+
+func (s *udpSession) idle(timeout time.Duration) bool {
+	return time.Since(time.Unix(0, s.lastSeen.Load())) > timeout
+}
+
+func (srv *Server) proxyPackets(ctx context.Context) error {
+	defer srv.PacketConn.Close() //skipcq: GO-S2307
+
+	idleTimeout := srv.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultUDPIdleTimeout
+	}
+
+	var (
+		mu       sync.Mutex
+		sessions = map[string]*udpSession{}
+		wg       sync.WaitGroup
+	)
+
+	defer func() {
+		mu.Lock()
+		for _, s := range sessions {
+			s.target.Close()
+		}
+		mu.Unlock()
+		wg.Wait()
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(idleTimeout / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				srv.PacketConn.Close()
+				return
+			case <-ticker.C:
+				mu.Lock()
+				for _, s := range sessions {
+					if s.idle(idleTimeout) {
+						terminal.Debug("closing idle udp session for ", s.client)
+						s.target.Close()
+					}
+				}
+				mu.Unlock()
+			}
+		}
+	}()
+
+	relay := func(s *udpSession) {
+		defer wg.Done()
+		defer func() {
+			mu.Lock()
+			if sessions[s.client.String()] == s {
+				delete(sessions, s.client.String())
+			}
+			mu.Unlock()
+		}()
+
+		buf := make([]byte, 65535)
+		for {
+			n, err := s.target.Read(buf)
+			if err != nil {
+				return
+			}
+			s.touch()
+
+			if _, err := srv.PacketConn.WriteTo(buf[:n], s.client); err != nil {
+				terminal.Debug("failed writing udp response: ", err)
+				return
+			}
+		}
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		n, client, err := srv.PacketConn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		mu.Lock()
+		s := sessions[client.String()]
+		mu.Unlock()
+
+		if s == nil {
+			target, err := srv.Dial(ctx, "udp", srv.Addr)
+			if err != nil {
+				terminal.Debug("failed to connect to target: ", err)
+				continue
+			}
+			terminal.Debug("new udp session from: ", client)
+
+			s = &udpSession{client: client, target: target}
+			s.touch()
+
+			mu.Lock()
+			sessions[client.String()] = s
+			mu.Unlock()
+
+			wg.Add(1)
+			go relay(s)
+		}
+
+		s.touch()
+		if _, err := s.target.Write(buf[:n]); err != nil {
+			terminal.Debug("failed forwarding udp datagram: ", err)
+			s.target.Close()
+		}
+	}
+}
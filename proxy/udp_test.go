@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// udpEcho echoes datagrams back to their sender and records each distinct
+// source address it has seen.
+type udpEcho struct {
+	conn *net.UDPConn
+
+	mu      sync.Mutex
+	sources map[string]bool
+}
+
+func newUDPEcho(t *testing.T) *udpEcho {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	e := &udpEcho{conn: conn, sources: map[string]bool{}}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			e.mu.Lock()
+			e.sources[addr.String()] = true
+			e.mu.Unlock()
+			conn.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	return e
+}
+
+func (e *udpEcho) sessions() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.sources)
+}
+
+func startUDPProxy(t *testing.T, target string, idle time.Duration) string {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := &Server{
+		Addr:        target,
+		PacketConn:  conn,
+		IdleTimeout: idle,
+		Dial:        (&net.Dialer{}).DialContext,
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- srv.ProxyServer(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+
+	return conn.LocalAddr().String()
+}
+
+func roundTrip(t *testing.T, conn net.Conn, msg string) {
+	t.Helper()
+
+	_, err := conn.Write([]byte(msg))
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, msg, string(buf[:n]))
+}
+
+func TestProxyPackets_SessionPerClient(t *testing.T) {
+	echo := newUDPEcho(t)
+	addr := startUDPProxy(t, echo.conn.LocalAddr().String(), time.Minute)
+
+	a, err := net.Dial("udp", addr)
+	require.NoError(t, err)
+	defer a.Close()
+	b, err := net.Dial("udp", addr)
+	require.NoError(t, err)
+	defer b.Close()
+
+	roundTrip(t, a, "hello from a")
+	roundTrip(t, b, "hello from b")
+	roundTrip(t, a, "again from a")
+
+	assert.Equal(t, 2, echo.sessions())
+}
+
+func TestProxyPackets_IdleTimeout(t *testing.T) {
+	echo := newUDPEcho(t)
+	addr := startUDPProxy(t, echo.conn.LocalAddr().String(), 100*time.Millisecond)
+
+	client, err := net.Dial("udp", addr)
+	require.NoError(t, err)
+	defer client.Close()
+
+	roundTrip(t, client, "first")
+	assert.Equal(t, 1, echo.sessions())
+
+	// the idle session is closed, so the next datagram opens a new upstream
+	// socket with a different source port.
+	time.Sleep(300 * time.Millisecond)
+	roundTrip(t, client, "second")
+	assert.Equal(t, 2, echo.sessions())
+}
@@ -0,0 +1,76 @@
+package wg
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transports a Tunnel can carry WireGuard packets over.
+const (
+	TransportUDP       = "udp"
+	TransportWebsocket = "websocket"
+)
+
+// TunnelStats is a snapshot of a tunnel's peer and DNS activity.
+type TunnelStats struct {
+	Endpoint      string
+	Transport     string
+	LastHandshake time.Time
+	RxBytes       uint64
+	TxBytes       uint64
+	DNSQueries    uint64
+	DNSErrors     uint64
+}
+
+// Stats reports the tunnel's current statistics.
+func (t *Tunnel) Stats() (TunnelStats, error) {
+	stats := TunnelStats{
+		Endpoint:   t.endpoint,
+		Transport:  t.transport,
+		DNSQueries: t.dnsQueries.Load(),
+		DNSErrors:  t.dnsErrors.Load(),
+	}
+
+	if t.dev == nil {
+		return stats, nil
+	}
+
+	uapi, err := t.dev.IpcGet()
+	if err != nil {
+		return stats, err
+	}
+	parseIpcStats(uapi, &stats)
+
+	return stats, nil
+}
+
+// parseIpcStats reads the peer counters out of the device's UAPI "get"
+// output. The agent's tunnels only ever have a single peer.
+func parseIpcStats(uapi string, stats *TunnelStats) {
+	var sec, nsec int64
+
+	s := bufio.NewScanner(strings.NewReader(uapi))
+	for s.Scan() {
+		key, value, ok := strings.Cut(s.Text(), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "last_handshake_time_sec":
+			sec, _ = strconv.ParseInt(value, 10, 64)
+		case "last_handshake_time_nsec":
+			nsec, _ = strconv.ParseInt(value, 10, 64)
+		case "rx_bytes":
+			stats.RxBytes, _ = strconv.ParseUint(value, 10, 64)
+		case "tx_bytes":
+			stats.TxBytes, _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+
+	if sec != 0 || nsec != 0 {
+		stats.LastHandshake = time.Unix(sec, nsec)
+	}
+}
@@ -0,0 +1,30 @@
+package wg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIpcStats(t *testing.T) {
+	uapi := "private_key=abcd\n" +
+		"public_key=ef01\n" +
+		"endpoint=1.2.3.4:51820\n" +
+		"last_handshake_time_sec=1700000000\n" +
+		"last_handshake_time_nsec=500\n" +
+		"tx_bytes=1024\n" +
+		"rx_bytes=2048\n" +
+		"persistent_keepalive_interval=15\n"
+
+	var stats TunnelStats
+	parseIpcStats(uapi, &stats)
+
+	assert.Equal(t, time.Unix(1700000000, 500), stats.LastHandshake)
+	assert.EqualValues(t, 1024, stats.TxBytes)
+	assert.EqualValues(t, 2048, stats.RxBytes)
+
+	var none TunnelStats
+	parseIpcStats("last_handshake_time_sec=0\nlast_handshake_time_nsec=0\n", &none)
+	assert.True(t, none.LastHandshake.IsZero())
+}
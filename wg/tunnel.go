@@ -8,6 +8,7 @@ import (
 	"math/rand"
 	"net"
 	"net/netip"
+	"sync/atomic"
 
 	"github.com/miekg/dns"
 	"golang.zx2c4.com/wireguard/conn"
@@ -26,6 +27,11 @@ type Tunnel struct {
 
 	wscancel func()
 	resolv   *net.Resolver
+
+	endpoint   string
+	transport  string
+	dnsQueries atomic.Uint64
+	dnsErrors  atomic.Uint64
 }
 
 func Connect(ctx context.Context, state *WireGuardState) (*Tunnel, error) {
@@ -70,8 +76,11 @@ func doConnect(ctx context.Context, state *WireGuardState, wswg bool) (*Tunnel,
 
 	endpointIP := endpointIPs[rand.Intn(len(endpointIPs))]
 	endpointAddr := net.JoinHostPort(endpointIP.String(), endpointPort)
+	transport := TransportUDP
 
 	if wswg {
+		transport = TransportWebsocket
+
 		port, err := websocketConnect(ctx, endpointHost)
 		if err != nil {
 			return nil, err
@@ -94,7 +103,7 @@ func doConnect(ctx context.Context, state *WireGuardState, wswg bool) (*Tunnel,
 	}
 	wgDev.Up()
 
-	return &Tunnel{
+	t := &Tunnel{
 		dev:    wgDev,
 		tun:    tunDev,
 		net:    gNet,
@@ -102,14 +111,24 @@ func doConnect(ctx context.Context, state *WireGuardState, wswg bool) (*Tunnel,
 		Config: cfg,
 		State:  state,
 
-		resolv: &net.Resolver{
-			PreferGo: true,
-			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-				fmt.Println("resolver.Dial", network, address)
-				return gNet.DialContext(ctx, "tcp", net.JoinHostPort(dnsIP.String(), "53"))
-			},
+		endpoint:  cfg.Endpoint,
+		transport: transport,
+	}
+
+	t.resolv = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			fmt.Println("resolver.Dial", network, address)
+			t.dnsQueries.Add(1)
+			c, err := gNet.DialContext(ctx, "tcp", net.JoinHostPort(dnsIP.String(), "53"))
+			if err != nil {
+				t.dnsErrors.Add(1)
+			}
+			return c, err
 		},
-	}, nil
+	}
+
+	return t, nil
 }
 
 func (t *Tunnel) Close() error {
@@ -190,7 +209,20 @@ func (t *Tunnel) LookupAAAA(ctx context.Context, name string) ([]net.IP, error)
 	return results, nil
 }
 
-func (t *Tunnel) queryDNS(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+// Exchange sends msg to the organization's DNS server through the tunnel
+// and returns its response.
+func (t *Tunnel) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	return t.queryDNS(ctx, msg)
+}
+
+func (t *Tunnel) queryDNS(ctx context.Context, msg *dns.Msg) (r *dns.Msg, err error) {
+	t.dnsQueries.Add(1)
+	defer func() {
+		if err != nil {
+			t.dnsErrors.Add(1)
+		}
+	}()
+
 	client := dns.Client{
 		Net: "tcp",
 		Dialer: &net.Dialer{
@@ -207,6 +239,6 @@ func (t *Tunnel) queryDNS(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
 	conn := &dns.Conn{Conn: c}
 	defer conn.Close()
 
-	r, _, err := client.ExchangeWithConn(msg, conn)
+	r, _, err = client.ExchangeWithConn(msg, conn)
 	return r, err
 }